@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/caarlos0/env/v9"
+
+	"github.com/Nexadis/gophmart/internal/logger"
+	"github.com/Nexadis/gophmart/internal/stub"
+)
+
+type Config struct {
+	RunAddress string `env:"RUN_ADDRESS"`
+	Scenario   string `env:"SCENARIO"`
+}
+
+func main() {
+	c := &Config{}
+	flag.StringVar(&c.RunAddress, "a", ":8081", "Run Address for accrual stub")
+	flag.StringVar(&c.Scenario, "s", "", "Path to YAML scenario, default scenario is used if empty")
+	flag.Parse()
+	if err := env.Parse(c); err != nil {
+		logger.Logger.Errorln(err)
+		return
+	}
+	scenario := stub.DefaultScenario()
+	if c.Scenario != "" {
+		var err error
+		scenario, err = stub.LoadScenario(c.Scenario)
+		if err != nil {
+			logger.Logger.Errorln(err)
+			return
+		}
+	}
+	logger.Logger.Errorln(stub.New(scenario).Run(c.RunAddress))
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"errors"
+	"net/http"
+
 	"github.com/Nexadis/gophmart/internal/logger"
 	"github.com/Nexadis/gophmart/internal/server"
 )
@@ -13,5 +16,8 @@ func main() {
 		logger.Logger.Errorln(err)
 		return
 	}
-	logger.Logger.Errorln(s.Run())
+	err = s.Run()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Logger.Errorln(err)
+	}
 }
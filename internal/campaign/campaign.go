@@ -0,0 +1,136 @@
+// Package campaign evaluates promotional campaigns giving bonuses for PROCESSED orders.
+// Evaluation depends only on campaigns and facts about the order, so it is deterministic.
+package campaign
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Nexadis/gophmart/internal/money"
+	"github.com/Nexadis/gophmart/internal/order"
+)
+
+// SourcePrefix is prefix of source of campaign bonuses, source is "campaign:<id>".
+const SourcePrefix = `campaign:`
+
+var ErrInvalid = errors.New(`invalid campaign`)
+
+// Campaign gives bonus for orders matching all its rules, zero value of a rule disables it.
+type Campaign struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// StartsAt and EndsAt limit time when order is processed, EndsAt is exclusive.
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
+	// FirstOrders limits campaign to the first processed orders of the user.
+	FirstOrders int          `json:"first_orders,omitempty"`
+	MinAccrual  money.Amount `json:"min_accrual,omitempty"`
+	// Segment is tier of users the campaign is for.
+	Segment string `json:"segment,omitempty"`
+	// Multiplier is percent of accrual credited, 200 doubles points.
+	Multiplier int `json:"multiplier,omitempty"`
+	// Fixed is points added to each matching order.
+	Fixed money.Amount `json:"fixed,omitempty"`
+}
+
+// Reward is tier and campaigns the order was rewarded by the first time,
+// revisions of the order recompute amounts of these bonuses only.
+type Reward struct {
+	Number    order.OrderNumber
+	Tier      string
+	Campaigns []*Campaign
+}
+
+// Facts are what is known about the order when it becomes PROCESSED.
+type Facts struct {
+	Number      order.OrderNumber
+	Owner       string
+	Accrual     money.Amount
+	ProcessedAt time.Time
+	// Index is number of the order among processed orders of the user starting from 1.
+	Index   int
+	Segment string
+}
+
+func (c *Campaign) Validate() error {
+	switch {
+	case c.Name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalid)
+	case c.StartsAt != nil && c.EndsAt != nil && !c.EndsAt.After(*c.StartsAt):
+		return fmt.Errorf("%w: ends_at should be after starts_at", ErrInvalid)
+	case c.FirstOrders < 0 || c.MinAccrual < 0 || c.Fixed < 0:
+		return fmt.Errorf("%w: rules can't be negative", ErrInvalid)
+	case c.Multiplier != 0 && c.Multiplier < 100:
+		return fmt.Errorf("%w: multiplier should be at least 100", ErrInvalid)
+	case c.Multiplier <= 100 && c.Fixed == 0:
+		return fmt.Errorf("%w: multiplier or fixed bonus is required", ErrInvalid)
+	}
+	return nil
+}
+
+func (c *Campaign) Source() string {
+	return SourcePrefix + strconv.FormatInt(c.ID, 10)
+}
+
+// Matches reports whether the order satisfies all rules of the campaign.
+func (c *Campaign) Matches(f Facts) bool {
+	switch {
+	case c.StartsAt != nil && f.ProcessedAt.Before(*c.StartsAt):
+		return false
+	case c.EndsAt != nil && !f.ProcessedAt.Before(*c.EndsAt):
+		return false
+	case c.FirstOrders > 0 && (f.Index < 1 || f.Index > c.FirstOrders):
+		return false
+	case f.Accrual < c.MinAccrual:
+		return false
+	case c.Segment != "" && c.Segment != f.Segment:
+		return false
+	}
+	return true
+}
+
+// Bonus returns points added for the order, multiplied part is rounded down to kopecks.
+func (c *Campaign) Bonus(f Facts) money.Amount {
+	if !c.Matches(f) {
+		return 0
+	}
+	bonus := c.Fixed
+	if c.Multiplier > 100 && f.Accrual > 0 {
+		extra := new(big.Int).Mul(big.NewInt(int64(f.Accrual)), big.NewInt(int64(c.Multiplier-100)))
+		extra.Quo(extra, big.NewInt(100))
+		if extra.IsInt64() {
+			sum, err := bonus.Add(money.Amount(extra.Int64()))
+			if err == nil {
+				bonus = sum
+			}
+		}
+	}
+	return bonus
+}
+
+// Evaluate returns bonuses of all campaigns matching the order ordered by campaign ID.
+func Evaluate(campaigns []*Campaign, f Facts) []*order.Bonus {
+	sorted := append([]*Campaign(nil), campaigns...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+	var bonuses []*order.Bonus
+	for _, c := range sorted {
+		amount := c.Bonus(f)
+		if amount <= 0 {
+			continue
+		}
+		bonuses = append(bonuses, &order.Bonus{
+			Number:    f.Number,
+			Owner:     f.Owner,
+			Source:    c.Source(),
+			Amount:    amount,
+			CreatedAt: f.ProcessedAt,
+		})
+	}
+	return bonuses
+}
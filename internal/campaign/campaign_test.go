@@ -0,0 +1,114 @@
+package campaign
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nexadis/gophmart/internal/money"
+	"github.com/Nexadis/gophmart/internal/order"
+)
+
+func at(day int, hour int) *time.Time {
+	t := time.Date(2023, time.June, day, hour, 0, 0, 0, time.UTC)
+	return &t
+}
+
+var (
+	weekend = &Campaign{
+		ID:         2,
+		Name:       "Double points this weekend",
+		StartsAt:   at(10, 0),
+		EndsAt:     at(12, 0),
+		Multiplier: 200,
+	}
+	firstOrder = &Campaign{
+		ID:          1,
+		Name:        "+100 points on first order",
+		FirstOrders: 1,
+		Fixed:       10000,
+	}
+	goldBig = &Campaign{
+		ID:         3,
+		Name:       "Gold big orders",
+		MinAccrual: 50000,
+		Segment:    "Gold",
+		Multiplier: 150,
+		Fixed:      100,
+	}
+	campaigns = []*Campaign{weekend, firstOrder, goldBig}
+)
+
+var evaluateTests = []struct {
+	name    string
+	facts   Facts
+	bonuses map[string]money.Amount
+}{
+	{
+		name:    "Nothing matches",
+		facts:   Facts{Accrual: 1000, ProcessedAt: *at(9, 23), Index: 2},
+		bonuses: map[string]money.Amount{},
+	},
+	{
+		name:    "First order at weekend",
+		facts:   Facts{Accrual: 1000, ProcessedAt: *at(10, 0), Index: 1},
+		bonuses: map[string]money.Amount{"campaign:1": 10000, "campaign:2": 1000},
+	},
+	{
+		name:    "Weekend is over",
+		facts:   Facts{Accrual: 1000, ProcessedAt: *at(12, 0), Index: 3},
+		bonuses: map[string]money.Amount{},
+	},
+	{
+		name:    "Big order of gold user",
+		facts:   Facts{Accrual: 50001, ProcessedAt: *at(20, 0), Index: 5, Segment: "Gold"},
+		bonuses: map[string]money.Amount{"campaign:3": 25100},
+	},
+	{
+		name:    "Big order of silver user",
+		facts:   Facts{Accrual: 50001, ProcessedAt: *at(20, 0), Index: 5, Segment: "Silver"},
+		bonuses: map[string]money.Amount{},
+	},
+	{
+		name:    "Small order of gold user",
+		facts:   Facts{Accrual: 49999, ProcessedAt: *at(20, 0), Index: 5, Segment: "Gold"},
+		bonuses: map[string]money.Amount{},
+	},
+}
+
+func TestEvaluate(t *testing.T) {
+	for _, test := range evaluateTests {
+		t.Run(test.name, func(t *testing.T) {
+			test.facts.Number = "12345678903"
+			test.facts.Owner = "user"
+			bonuses := Evaluate(campaigns, test.facts)
+			got := map[string]money.Amount{}
+			var prev string
+			for _, b := range bonuses {
+				assert.Equal(t, order.OrderNumber("12345678903"), b.Number)
+				assert.Equal(t, "user", b.Owner)
+				assert.Equal(t, test.facts.ProcessedAt, b.CreatedAt)
+				assert.Less(t, prev, b.Source)
+				prev = b.Source
+				got[b.Source] = b.Amount
+			}
+			assert.Equal(t, test.bonuses, got)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, weekend.Validate())
+	assert.NoError(t, firstOrder.Validate())
+	invalid := []*Campaign{
+		{Multiplier: 200},
+		{Name: "No bonus"},
+		{Name: "Less points", Multiplier: 50},
+		{Name: "Negative", Fixed: -1},
+		{Name: "Wrong dates", Fixed: 1, StartsAt: at(12, 0), EndsAt: at(10, 0)},
+	}
+	for _, c := range invalid {
+		assert.ErrorIs(t, c.Validate(), ErrInvalid, c.Name)
+	}
+}
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"github.com/Nexadis/gophmart/internal/money"
 	"github.com/Nexadis/gophmart/internal/order"
 )
 
@@ -14,5 +15,5 @@ const (
 type Accrual struct {
 	Order   order.OrderNumber `json:"order"`
 	Status  string            `json:"status"`
-	Accrual *order.Points     `json:"accrual,omitempty"`
+	Accrual *money.Amount     `json:"accrual,omitempty"`
 }
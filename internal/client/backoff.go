@@ -0,0 +1,35 @@
+package client
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff computes retry delays for an order, growing exponentially with jitter.
+type Backoff struct {
+	base time.Duration
+	max  time.Duration
+}
+
+func NewBackoff(base, max time.Duration) *Backoff {
+	return &Backoff{
+		base: base,
+		max:  max,
+	}
+}
+
+// Delay returns half of the exponential delay plus a random part of the other half.
+func (b *Backoff) Delay(attempt int) time.Duration {
+	if b.base <= 0 {
+		return 0
+	}
+	delay := b.base
+	for i := 1; i < attempt && delay < b.max; i++ {
+		delay *= 2
+	}
+	if b.max > 0 && delay > b.max {
+		delay = b.max
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
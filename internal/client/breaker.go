@@ -0,0 +1,124 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Nexadis/gophmart/internal/logger"
+)
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = `closed`
+	BreakerOpen     BreakerState = `open`
+	BreakerHalfOpen BreakerState = `half-open`
+)
+
+type BreakerStatus struct {
+	State    BreakerState `json:"state"`
+	Failures int          `json:"failures"`
+	OpenedAt *time.Time   `json:"opened_at,omitempty"`
+}
+
+// Breaker stops requests to the accrual system after threshold failures in a row
+// and lets a single probe request through once timeout has passed.
+type Breaker struct {
+	// name is prefix of routed accrual system, it is empty for the default one.
+	name      string
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	threshold int
+	timeout   time.Duration
+}
+
+func NewBreaker(threshold int, timeout time.Duration) *Breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &Breaker{
+		state:     BreakerClosed,
+		threshold: threshold,
+		timeout:   timeout,
+	}
+}
+
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.timeout {
+			return false
+		}
+		b.setState(BreakerHalfOpen)
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// ReopenAt returns time when the breaker may let the next request through.
+func (b *Breaker) ReopenAt() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		return b.openedAt.Add(b.timeout)
+	case BreakerHalfOpen:
+		// result of the probe isn't known yet
+		return time.Now().Add(b.timeout)
+	}
+	return time.Now()
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+	if b.state != BreakerClosed {
+		b.setState(BreakerClosed)
+	}
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.openedAt = time.Now()
+		if b.state != BreakerOpen {
+			b.setState(BreakerOpen)
+		}
+	}
+}
+
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := BreakerStatus{
+		State:    b.state,
+		Failures: b.failures,
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		s.OpenedAt = &openedAt
+	}
+	return s
+}
+
+func (b *Breaker) setState(state BreakerState) {
+	logger.Logger.Infof("Accrual breaker %q: %s -> %s, failures: %d", b.name, b.state, state, b.failures)
+	b.state = state
+}
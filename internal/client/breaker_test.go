@@ -0,0 +1,54 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	b := NewBreaker(2, 10*time.Millisecond)
+	assert.True(t, b.Allow())
+	b.Failure()
+	assert.Equal(t, BreakerClosed, b.Status().State)
+	b.Failure()
+	assert.Equal(t, BreakerOpen, b.Status().State)
+	assert.False(t, b.Allow())
+	assert.Equal(t, b.Status().OpenedAt.Add(10*time.Millisecond), b.ReopenAt())
+
+	time.Sleep(20 * time.Millisecond)
+	assert.True(t, b.Allow())
+	assert.Equal(t, BreakerHalfOpen, b.Status().State)
+	assert.False(t, b.Allow())
+	b.Failure()
+	assert.Equal(t, BreakerOpen, b.Status().State)
+
+	time.Sleep(20 * time.Millisecond)
+	assert.True(t, b.Allow())
+	b.Success()
+	assert.Equal(t, BreakerClosed, b.Status().State)
+	assert.Equal(t, 0, b.Status().Failures)
+}
+
+var backoffTests = []struct {
+	name    string
+	attempt int
+	min     time.Duration
+	max     time.Duration
+}{
+	{"First attempt", 1, 50 * time.Millisecond, 100 * time.Millisecond},
+	{"Third attempt", 3, 200 * time.Millisecond, 400 * time.Millisecond},
+	{"Capped attempt", 10, 500 * time.Millisecond, time.Second},
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := NewBackoff(100*time.Millisecond, time.Second)
+	for _, test := range backoffTests {
+		t.Run(test.name, func(t *testing.T) {
+			delay := b.Delay(test.attempt)
+			assert.GreaterOrEqual(t, delay, test.min)
+			assert.LessOrEqual(t, delay, test.max)
+		})
+	}
+}
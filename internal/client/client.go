@@ -4,123 +4,409 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net/http"
+	"sync"
 	"time"
 
-	"github.com/go-resty/resty/v2"
-
 	"github.com/Nexadis/gophmart/internal/db"
 	"github.com/Nexadis/gophmart/internal/logger"
 	"github.com/Nexadis/gophmart/internal/order"
 )
 
-const APIGetAccrual = `/api/orders/{number}`
+const (
+	notifyBuffer     = 1024
+	defaultBatchSize = 100
+)
 
 var (
 	ErrInternal      = errors.New(`internal error accrual`)
 	ErrNotRegistered = errors.New(`order isn't registered in system`)
+	ErrUnavailable   = errors.New(`accrual system unavailable`)
+	ErrRateLimited   = errors.New(`accrual system rate limit`)
 )
 
+// RateLimitError means that accrual system asks to repeat request after RetryAfter.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrRateLimited, e.RetryAfter)
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// isFailure reports if err means that accrual system can't serve requests now.
+func isFailure(err error) bool {
+	return errors.Is(err, ErrUnavailable) || errors.Is(err, ErrRateLimited)
+}
+
+type RetryConfig struct {
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	BreakerThreshold int
+	BreakerTimeout   time.Duration
+}
+
+// GiveUpConfig limits checks of an order, zero value disables the limit.
+type GiveUpConfig struct {
+	MaxAttempts int
+	MaxAge      time.Duration
+}
+
+type Config struct {
+	Addr string
+	// Routes are accrual systems for orders with prefix, other orders are checked in Addr.
+	Routes    []Route
+	HTTP      HTTPConfig
+	Wait      time.Duration
+	BatchSize int
+	Retry     RetryConfig
+	GiveUp    GiveUpConfig
+	Revision  RevisionConfig
+	// Reporter gets errors of accrual loop in addition to the status endpoint.
+	Reporter Reporter
+	// Rewarder gets orders which become PROCESSED, it may be nil.
+	Rewarder Rewarder
+	// Parser checks order numbers pushed by accrual system.
+	Parser order.NumberParser
+}
+
+type Status struct {
+	// Breaker is breaker of the default accrual system.
+	Breaker BreakerStatus `json:"breaker"`
+	// Routes are breakers of routed accrual systems by prefix.
+	Routes map[string]BreakerStatus `json:"routes,omitempty"`
+	Health HealthStatus             `json:"health"`
+}
+
+// routedProvider passes orders to accrual systems by prefix of order number.
+type routedProvider interface {
+	Prefix(number order.OrderNumber) string
+	Prefixes() []string
+}
+
 type Client struct {
-	client *resty.Client
-	Addr   string
-	db     db.OrdersStore
-	wait   time.Duration
+	provider  AccrualProvider
+	db        db.OrdersStore
+	wait      time.Duration
+	batchSize int
+	backoff   *Backoff
+	// breakers are kept per accrual system, so one system being down doesn't stop others.
+	breakers map[string]*Breaker
+	giveUp   GiveUpConfig
+	revision RevisionConfig
+	health   *Health
+	reporter Reporter
+	rewarder Rewarder
+	parser   order.NumberParser
+	notify   chan order.OrderNumber
+
+	mu       sync.Mutex
+	inFlight map[order.OrderNumber]struct{}
 }
 
-func New(addr string, db db.OrdersStore, wait time.Duration) *Client {
-	return &Client{
-		client: resty.New().SetDebug(true),
-		Addr:   addr,
-		db:     db,
-		wait:   wait,
+func New(config Config, db db.OrdersStore) (*Client, error) {
+	provider, err := NewHTTPProvider(config.Addr, config.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	router := NewRouter(provider)
+	for _, r := range config.Routes {
+		provider, err := NewHTTPProvider(r.Addr, config.HTTP)
+		if err != nil {
+			return nil, err
+		}
+		router.Route(r.Prefix, provider)
 	}
+	return NewWithProvider(config, router, db), nil
 }
 
-func (c *Client) GetAccruals(done chan struct{}, errors chan error) {
-	orders := unprocessedOrders(c, done)
-	for n := range orders {
-		o := &order.Order{
-			Number: n,
+func NewWithProvider(config Config, provider AccrualProvider, db db.OrdersStore) *Client {
+	batchSize := config.BatchSize
+	if batchSize < 1 {
+		batchSize = defaultBatchSize
+	}
+	breakers := map[string]*Breaker{
+		"": NewBreaker(config.Retry.BreakerThreshold, config.Retry.BreakerTimeout),
+	}
+	if router, ok := provider.(routedProvider); ok {
+		for _, prefix := range router.Prefixes() {
+			breakers[prefix] = NewBreaker(config.Retry.BreakerThreshold, config.Retry.BreakerTimeout)
+			breakers[prefix].name = prefix
 		}
-		a, err := c.getOrderStatus(n)
-		switch err {
-		case nil:
-			o.Status = accrualToOrderStatus(a.Status)
-			o.Accrual = a.Accrual
-		case ErrNotRegistered:
-			o.Status = order.StatusInvalid
-		default:
-			logger.Logger.Error(err)
+	}
+	return &Client{
+		provider:  provider,
+		db:        db,
+		wait:      config.Wait,
+		batchSize: batchSize,
+		backoff:   NewBackoff(config.Retry.BaseDelay, config.Retry.MaxDelay),
+		breakers:  breakers,
+		giveUp:    config.GiveUp,
+		revision:  config.Revision,
+		health:    NewHealth(),
+		reporter:  config.Reporter,
+		rewarder:  config.Rewarder,
+		parser:    config.Parser,
+		notify:    make(chan order.OrderNumber, notifyBuffer),
+		inFlight:  make(map[order.OrderNumber]struct{}),
+	}
+}
+
+// Notify asks to check the order without waiting for the next scan.
+// If the queue is full the order is left to the periodic scan.
+func (c *Client) Notify(number order.OrderNumber) {
+	select {
+	case c.notify <- number:
+	default:
+		logger.Logger.Infof("Notify queue is full, order %s waits for scan", number)
+	}
+}
+
+func (c *Client) Status() Status {
+	s := Status{
+		Breaker: c.breakers[""].Status(),
+		Health:  c.health.Status(),
+	}
+	for prefix, b := range c.breakers {
+		if prefix == "" {
 			continue
 		}
-		err = c.db.UpdateOrder(context.Background(), o)
+		if s.Routes == nil {
+			s.Routes = make(map[string]BreakerStatus, len(c.breakers)-1)
+		}
+		s.Routes[prefix] = b.Status()
+	}
+	return s
+}
+
+// breaker returns breaker of accrual system checking the order.
+func (c *Client) breaker(number order.OrderNumber) *Breaker {
+	if router, ok := c.provider.(routedProvider); ok {
+		if b, ok := c.breakers[router.Prefix(number)]; ok {
+			return b
+		}
+	}
+	return c.breakers[""]
+}
+
+// ReportError logs err and passes it to the health status and the reporter.
+func (c *Client) ReportError(stage string, number order.OrderNumber, err error) {
+	logger.Logger.Errorw(err.Error(), "stage", stage, "order", number)
+	c.health.ReportError(stage, number, err)
+	if c.reporter != nil {
+		c.reporter.ReportError(stage, number, err)
+	}
+}
+
+func (c *Client) reportSuccess(stage string) {
+	c.health.ReportSuccess(stage)
+	if c.reporter != nil {
+		c.reporter.ReportSuccess(stage)
+	}
+}
+
+// GetAccruals checks unprocessed orders until ctx is done.
+func (c *Client) GetAccruals(ctx context.Context) {
+	orders := unprocessedOrders(ctx, c)
+	for o := range orders {
+		c.checkOrder(ctx, o)
+		c.release(o.Number)
+	}
+}
+
+func (c *Client) checkOrder(ctx context.Context, o *order.Order) {
+	breaker := c.breaker(o.Number)
+	if !breaker.Allow() {
+		// rejected order isn't due until the breaker reopens, so it doesn't fill batches of other systems
+		err := c.db.ScheduleOrder(ctx, o.Number, o.Attempts, breaker.ReopenAt())
 		if err != nil {
-			logger.Logger.Error(err)
+			c.ReportError(StageUpdate, o.Number, err)
+		}
+		return
+	}
+	o.Attempts++
+	a, err := c.provider.Check(ctx, o.Number)
+	if ctx.Err() != nil {
+		return
+	}
+	c.recordAccrual(ctx, o.Number, a, err)
+	if o.Status == order.StatusProcessed {
+		if isFailure(err) {
+			breaker.Failure()
+		} else {
+			breaker.Success()
 		}
+		c.reviseOrder(ctx, o, a, err)
+		return
 	}
+	switch {
+	case err == nil:
+		breaker.Success()
+		status, ok := accrualToOrderStatus(a.Status)
+		if !ok {
+			logger.Logger.Errorf("Unknown accrual status %q of order %s, status isn't changed", a.Status, o.Number)
+			c.retryOrder(ctx, o, c.wait)
+			return
+		}
+		if o.Status != "" && !o.Status.CanTransit(status) {
+			logger.Logger.Infof("Ignore accrual status %q of order %s: %s", a.Status, o.Number, o.Status.Transit(status))
+			c.retryOrder(ctx, o, c.wait)
+			return
+		}
+		o.Status = status
+		o.Accrual = a.Accrual
+	case errors.Is(err, ErrNotRegistered):
+		breaker.Success()
+		o.Status = order.StatusInvalid
+	default:
+		if isFailure(err) {
+			breaker.Failure()
+		} else {
+			breaker.Success()
+		}
+		c.ReportError(StageCheck, o.Number, err)
+		delay := c.backoff.Delay(o.Attempts)
+		var rateLimit *RateLimitError
+		if errors.As(err, &rateLimit) && rateLimit.RetryAfter > delay {
+			delay = rateLimit.RetryAfter
+		}
+		c.retryOrder(ctx, o, delay)
+		return
+	}
+	if !o.Status.IsFinal() {
+		c.staleOrder(o)
+	}
+	c.updateOrder(ctx, o)
 }
 
-func (c *Client) getOrderStatus(number order.OrderNumber) (*Accrual, error) {
-	endpoint := fmt.Sprintf("%s%s", c.Addr, APIGetAccrual)
-	a := &Accrual{}
-	resp, err := c.client.R().
-		SetPathParam("number", string(number)).
-		SetResult(a).
-		Get(endpoint)
+// retryOrder schedules the next check of the order in delay or gives it up.
+func (c *Client) retryOrder(ctx context.Context, o *order.Order, delay time.Duration) {
+	if c.staleOrder(o) {
+		c.updateOrder(ctx, o)
+		return
+	}
+	logger.Logger.Infof("Retry order %s in %s", o.Number, delay)
+	err := c.db.ScheduleOrder(ctx, o.Number, o.Attempts, time.Now().Add(delay))
 	if err != nil {
-		return nil, err
+		c.ReportError(StageUpdate, o.Number, err)
 	}
+}
 
-	switch resp.StatusCode() {
-	case http.StatusOK:
-		return a, nil
-	case http.StatusTooManyRequests:
-		logger.Logger.Info("Too many requests")
-		time.Sleep(60 * time.Second)
-	case http.StatusNoContent:
-		return nil, ErrNotRegistered
+func (c *Client) updateOrder(ctx context.Context, o *order.Order) {
+	next := time.Now().Add(c.wait)
+	if o.Status == order.StatusProcessed {
+		next = c.nextRevision()
+	}
+	o.NextCheckAt = &next
+	err := c.db.UpdateOrder(ctx, o)
+	if err != nil {
+		c.ReportError(StageUpdate, o.Number, err)
+		return
 	}
-	return nil, fmt.Errorf(`invlaid status code for order: %s`, number)
+	c.reportSuccess(StageCheck)
+	c.reward(ctx, o)
 }
 
-func accrualToOrderStatus(status string) order.Status {
+// staleOrder moves the order to STALE if it exceeds give up limits.
+func (c *Client) staleOrder(o *order.Order) bool {
+	switch {
+	case c.giveUp.MaxAttempts > 0 && o.Attempts >= c.giveUp.MaxAttempts:
+		o.Reason = fmt.Sprintf("no final status after %d attempts", o.Attempts)
+	case c.giveUp.MaxAge > 0 && o.WaitingSince() != nil && time.Since(*o.WaitingSince()) > c.giveUp.MaxAge:
+		o.Reason = fmt.Sprintf("no final status in %s", c.giveUp.MaxAge)
+	default:
+		return false
+	}
+	logger.Logger.Infof("Give up order %s: %s", o.Number, o.Reason)
+	o.Status = order.StatusStale
+	o.Accrual = nil
+	return true
+}
+
+// acquire marks the order as queued, so it isn't queued twice until release.
+func (c *Client) acquire(number order.OrderNumber) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.inFlight[number]; ok {
+		return false
+	}
+	c.inFlight[number] = struct{}{}
+	return true
+}
+
+func (c *Client) release(number order.OrderNumber) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.inFlight, number)
+}
+
+// accrualToOrderStatus maps status of accrual system to order status, it returns false for unknown status.
+func accrualToOrderStatus(status string) (order.Status, bool) {
 	switch status {
 	case StatusRegistered:
-		return order.StatusNew
+		return order.StatusNew, true
 	case StatusProcessing:
-		return order.StatusProcessing
+		return order.StatusProcessing, true
 	case StatusInvalid:
-		return order.StatusInvalid
+		return order.StatusInvalid, true
 	case StatusProcessed:
-		return order.StatusProcessed
+		return order.StatusProcessed, true
 	}
-	return order.StatusInvalid
+	return "", false
 }
 
-func unprocessedOrders(c *Client, done <-chan struct{}) <-chan order.OrderNumber {
-	orders := make(chan order.OrderNumber)
+func unprocessedOrders(ctx context.Context, c *Client) <-chan *order.Order {
+	orders := make(chan *order.Order)
 	go func() {
+		defer close(orders)
 		t := time.NewTicker(c.wait)
+		defer t.Stop()
+		send := func(o *order.Order) bool {
+			if !c.acquire(o.Number) {
+				return true
+			}
+			select {
+			case <-ctx.Done():
+				return false
+			case orders <- o:
+				return true
+			}
+		}
 		for {
 			select {
-			case <-done:
-				close(orders)
+			case <-ctx.Done():
 				return
-			case <-t.C:
-				processingOrders, err := c.db.GetWithStatus(context.Background(), order.StatusProcessing)
+			case number := <-c.notify:
+				// checks depend on attempts, upload time and status of the saved order
+				o, err := c.db.GetOrder(ctx, number)
 				if err != nil {
-					logger.Logger.Error(err)
+					if ctx.Err() == nil {
+						c.ReportError(StageFetch, number, err)
+					}
+					continue
+				}
+				if o.Status.IsFinal() || o.Status == order.StatusStale {
 					continue
 				}
-				newOrders, err := c.db.GetWithStatus(context.Background(), order.StatusNew)
+				if !send(o) {
+					return
+				}
+			case <-t.C:
+				c.rewardPending(ctx)
+				dueOrders, err := c.db.GetDueOrders(ctx, c.batchSize, c.revision.Window)
 				if err != nil {
-					logger.Logger.Error(err)
+					if ctx.Err() == nil {
+						c.ReportError(StageFetch, "", err)
+					}
 					continue
 				}
-				ordersToHandle := append(processingOrders, newOrders...)
-				for _, number := range ordersToHandle {
-					orders <- number
+				for _, o := range dueOrders {
+					if !send(o) {
+						return
+					}
 				}
 			}
 		}
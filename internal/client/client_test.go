@@ -0,0 +1,270 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nexadis/gophmart/internal/money"
+	"github.com/Nexadis/gophmart/internal/order"
+	"github.com/Nexadis/gophmart/mocks"
+)
+
+func TestNotify(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockOrdersStore(ctrl)
+	uploaded := time.Now().Add(-time.Hour)
+	mockdb.EXPECT().GetOrder(gomock.Any(), order.OrderNumber("12345678903")).Return(&order.Order{
+		Number:     "12345678903",
+		Status:     order.StatusProcessing,
+		Attempts:   3,
+		UploadedAt: &uploaded,
+	}, nil).Times(2)
+	mockdb.EXPECT().GetOrder(gomock.Any(), order.OrderNumber("25461716")).Return(&order.Order{
+		Number: "25461716",
+		Status: order.StatusInvalid,
+	}, nil)
+	c := NewWithProvider(Config{Wait: time.Hour}, nil, mockdb)
+	ctx, cancel := context.WithCancel(context.Background())
+	orders := unprocessedOrders(ctx, c)
+	c.Notify("25461716")
+	c.Notify("12345678903")
+	select {
+	case o := <-orders:
+		assert.Equal(t, order.OrderNumber("12345678903"), o.Number)
+		assert.Equal(t, order.StatusProcessing, o.Status)
+		assert.Equal(t, 3, o.Attempts, "notified order is loaded from db")
+		assert.Equal(t, &uploaded, o.UploadedAt)
+	case <-time.After(time.Second):
+		t.Fatal("notified order wasn't queued")
+	}
+	c.Notify("12345678903")
+	select {
+	case <-orders:
+		t.Fatal("order in flight was queued twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+	cancel()
+	_, ok := <-orders
+	assert.False(t, ok)
+}
+
+var staleTests = []struct {
+	name     string
+	giveUp   GiveUpConfig
+	attempts int
+	age      time.Duration
+	requeued time.Duration
+	stale    bool
+}{
+	{"No limits", GiveUpConfig{}, 100, 100 * time.Hour, 0, false},
+	{"Attempts exceeded", GiveUpConfig{MaxAttempts: 10}, 10, time.Minute, 0, true},
+	{"Attempts left", GiveUpConfig{MaxAttempts: 10}, 9, time.Minute, 0, false},
+	{"Too old", GiveUpConfig{MaxAge: time.Hour}, 1, 2 * time.Hour, 0, true},
+	{"Requeued old order", GiveUpConfig{MaxAge: time.Hour}, 1, 2 * time.Hour, time.Minute, false},
+	{"Requeued long ago", GiveUpConfig{MaxAge: time.Hour}, 1, 3 * time.Hour, 2 * time.Hour, true},
+}
+
+func TestStaleOrder(t *testing.T) {
+	for _, test := range staleTests {
+		t.Run(test.name, func(t *testing.T) {
+			c := NewWithProvider(Config{GiveUp: test.giveUp}, nil, nil)
+			uploaded := time.Now().Add(-test.age)
+			o := &order.Order{
+				Number:     "12345678903",
+				Status:     order.StatusProcessing,
+				Attempts:   test.attempts,
+				UploadedAt: &uploaded,
+			}
+			if test.requeued > 0 {
+				requeued := time.Now().Add(-test.requeued)
+				o.RequeuedAt = &requeued
+			}
+			assert.Equal(t, test.stale, c.staleOrder(o))
+			if test.stale {
+				assert.Equal(t, order.StatusStale, o.Status)
+				assert.NotEmpty(t, o.Reason)
+			}
+		})
+	}
+}
+
+type testRewarder struct {
+	rewarded []order.OrderNumber
+	failed   map[order.OrderNumber]error
+}
+
+func (r *testRewarder) Reward(ctx context.Context, o *order.Order) error {
+	if err := r.failed[o.Number]; err != nil {
+		return err
+	}
+	r.rewarded = append(r.rewarded, o.Number)
+	return nil
+}
+
+func TestCheckOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockOrdersStore(ctrl)
+	points := money.Amount(12312)
+	provider := &StaticProvider{
+		Rules: []StaticRule{
+			{Prefix: "1", Status: StatusProcessed, Accrual: &points},
+			{Prefix: "2", Err: ErrUnavailable},
+			{Prefix: "4", Status: "UNKNOWN"},
+			{Prefix: "5", Status: StatusRegistered},
+		},
+	}
+	rewarder := &testRewarder{}
+	c := NewWithProvider(Config{Wait: time.Second, Rewarder: rewarder}, provider, mockdb)
+	gomock.InOrder(
+		mockdb.EXPECT().AddAccrualRecord(gomock.Any(), gomock.Any()),
+		mockdb.EXPECT().UpdateOrder(gomock.Any(), gomock.Any()).Do(
+			func(ctx context.Context, o *order.Order) {
+				assert.Equal(t, order.StatusProcessed, o.Status)
+				assert.Equal(t, &points, o.Accrual)
+				assert.Equal(t, 1, o.Attempts)
+			}),
+		mockdb.EXPECT().SetRewarded(gomock.Any(), order.OrderNumber("12345678903"), &points),
+		mockdb.EXPECT().AddAccrualRecord(gomock.Any(), gomock.Any()).Do(
+			func(ctx context.Context, r *order.AccrualRecord) {
+				assert.Equal(t, order.OrderNumber("25461716"), r.Number)
+				assert.Contains(t, r.Error, ErrUnavailable.Error())
+			}),
+		mockdb.EXPECT().ScheduleOrder(gomock.Any(), order.OrderNumber("25461716"), 1, gomock.Any()),
+		mockdb.EXPECT().AddAccrualRecord(gomock.Any(), gomock.Any()).Do(
+			func(ctx context.Context, r *order.AccrualRecord) {
+				assert.Equal(t, ErrNotRegistered.Error(), r.Error)
+			}),
+		mockdb.EXPECT().UpdateOrder(gomock.Any(), gomock.Any()).Do(
+			func(ctx context.Context, o *order.Order) {
+				assert.Equal(t, order.StatusInvalid, o.Status)
+			}),
+		mockdb.EXPECT().AddAccrualRecord(gomock.Any(), gomock.Any()),
+		mockdb.EXPECT().ScheduleOrder(gomock.Any(), order.OrderNumber("4111111111111111"), 1, gomock.Any()),
+		mockdb.EXPECT().AddAccrualRecord(gomock.Any(), gomock.Any()),
+		mockdb.EXPECT().ScheduleOrder(gomock.Any(), order.OrderNumber("5555555555554444"), 1, gomock.Any()),
+	)
+	ctx := context.Background()
+	c.checkOrder(ctx, &order.Order{Number: "12345678903"})
+	c.checkOrder(ctx, &order.Order{Number: "25461716"})
+	c.checkOrder(ctx, &order.Order{Number: "3"})
+	c.checkOrder(ctx, &order.Order{Number: "4111111111111111", Status: order.StatusNew})
+	c.checkOrder(ctx, &order.Order{Number: "5555555555554444", Status: order.StatusProcessing})
+	assert.Equal(t, []order.OrderNumber{"12345678903"}, rewarder.rewarded)
+	status := c.Status()
+	assert.Equal(t, int64(1), status.Health.Errors[StageCheck])
+	assert.NotNil(t, status.Health.LastSuccessAt)
+}
+
+func TestReviseOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockOrdersStore(ctrl)
+	prev := money.Amount(10000)
+	revised := money.Amount(12000)
+	provider := &StaticProvider{
+		Rules: []StaticRule{
+			{Prefix: "1", Status: StatusProcessed, Accrual: &revised},
+			{Prefix: "2", Status: StatusProcessed, Accrual: &prev},
+		},
+	}
+	rewarder := &testRewarder{}
+	c := NewWithProvider(Config{
+		Revision: RevisionConfig{Window: 24 * time.Hour, Interval: time.Hour},
+		Rewarder: rewarder,
+	}, provider, mockdb)
+	gomock.InOrder(
+		mockdb.EXPECT().AddAccrualRecord(gomock.Any(), gomock.Any()),
+		mockdb.EXPECT().ReviseAccrual(gomock.Any(), order.OrderNumber("12345678903"), &revised, gomock.Any(), gomock.Any()).Return(
+			&order.Adjustment{Number: "12345678903", Delta: 2000}, nil),
+		mockdb.EXPECT().SetRewarded(gomock.Any(), order.OrderNumber("12345678903"), &revised),
+		mockdb.EXPECT().AddAccrualRecord(gomock.Any(), gomock.Any()),
+		mockdb.EXPECT().ScheduleOrder(gomock.Any(), order.OrderNumber("25461716"), 1, gomock.Any()),
+	)
+	ctx := context.Background()
+	c.checkOrder(ctx, &order.Order{Number: "12345678903", Status: order.StatusProcessed, Accrual: &prev})
+	c.checkOrder(ctx, &order.Order{Number: "25461716", Status: order.StatusProcessed, Accrual: &prev})
+	assert.Equal(t, []order.OrderNumber{"12345678903"}, rewarder.rewarded, "bonuses are recomputed for revised accrual")
+}
+
+func TestRewardPending(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockOrdersStore(ctrl)
+	points := money.Amount(1000)
+	rewarder := &testRewarder{failed: map[order.OrderNumber]error{"25461716": errors.New("no campaigns")}}
+	c := NewWithProvider(Config{Rewarder: rewarder}, &StaticProvider{}, mockdb)
+	mockdb.EXPECT().GetRewardPending(gomock.Any(), gomock.Any()).Return([]*order.Order{
+		{Number: "12345678903", Status: order.StatusProcessed, Accrual: &points},
+		{Number: "25461716", Status: order.StatusProcessed, Accrual: &points},
+	}, nil)
+	// failed reward stays pending for the next tick
+	mockdb.EXPECT().SetRewarded(gomock.Any(), order.OrderNumber("12345678903"), &points)
+	c.rewardPending(context.Background())
+	assert.Equal(t, []order.OrderNumber{"12345678903"}, rewarder.rewarded)
+	assert.Equal(t, int64(1), c.Status().Health.Errors[StageReward])
+}
+
+func TestCheckOrderRateLimited(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockOrdersStore(ctrl)
+	provider := &StaticProvider{
+		Rules: []StaticRule{
+			{Err: &RateLimitError{RetryAfter: time.Hour}},
+		},
+	}
+	c := NewWithProvider(Config{
+		Retry: RetryConfig{BaseDelay: time.Millisecond, MaxDelay: time.Second, BreakerThreshold: 2},
+	}, provider, mockdb)
+	mockdb.EXPECT().AddAccrualRecord(gomock.Any(), gomock.Any())
+	mockdb.EXPECT().ScheduleOrder(gomock.Any(), order.OrderNumber("12345678903"), 1, gomock.Any()).Do(
+		func(ctx context.Context, number order.OrderNumber, attempts int, next time.Time) {
+			assert.WithinDuration(t, time.Now().Add(time.Hour), next, time.Minute)
+		})
+	c.checkOrder(context.Background(), &order.Order{Number: "12345678903", Status: order.StatusNew})
+	assert.Equal(t, 1, c.Status().Breaker.Failures)
+}
+
+func TestBreakerPerRoute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockOrdersStore(ctrl)
+	router := NewRouter(&StaticProvider{
+		Rules: []StaticRule{
+			{Status: StatusProcessing},
+		},
+	})
+	router.Route("4", &StaticProvider{
+		Rules: []StaticRule{
+			{Err: ErrUnavailable},
+		},
+	})
+	c := NewWithProvider(Config{
+		Wait:  time.Second,
+		Retry: RetryConfig{BaseDelay: time.Millisecond, MaxDelay: time.Second, BreakerThreshold: 1, BreakerTimeout: time.Hour},
+	}, router, mockdb)
+	mockdb.EXPECT().ScheduleOrder(gomock.Any(), order.OrderNumber("4111111111111111"), 1, gomock.Any())
+	mockdb.EXPECT().AddAccrualRecord(gomock.Any(), gomock.Any()).AnyTimes()
+	mockdb.EXPECT().UpdateOrder(gomock.Any(), gomock.Any()).Times(2)
+	// breaker of partner is open, its orders aren't checked until it reopens
+	mockdb.EXPECT().ScheduleOrder(gomock.Any(), order.OrderNumber("4561261212345467"), 0, gomock.Any()).Do(
+		func(ctx context.Context, number order.OrderNumber, attempts int, next time.Time) {
+			assert.WithinDuration(t, time.Now().Add(time.Hour), next, time.Minute)
+		})
+	ctx := context.Background()
+	c.checkOrder(ctx, &order.Order{Number: "4111111111111111", Status: order.StatusNew})
+	c.checkOrder(ctx, &order.Order{Number: "4561261212345467", Status: order.StatusNew})
+	c.checkOrder(ctx, &order.Order{Number: "12345678903", Status: order.StatusNew})
+	c.checkOrder(ctx, &order.Order{Number: "25461716", Status: order.StatusNew})
+
+	status := c.Status()
+	assert.Equal(t, BreakerClosed, status.Breaker.State)
+	assert.Equal(t, BreakerOpen, status.Routes["4"].State)
+}
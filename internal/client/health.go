@@ -0,0 +1,85 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Nexadis/gophmart/internal/order"
+)
+
+// Stages of the accrual loop reported to Reporter.
+const (
+	StageFetch  = `fetch`
+	StageCheck  = `check`
+	StageUpdate = `update`
+	StageListen = `listen`
+	StageReward = `reward`
+)
+
+// Reporter receives errors of the accrual loop, e.g. to export them as metrics.
+type Reporter interface {
+	ReportError(stage string, number order.OrderNumber, err error)
+	ReportSuccess(stage string)
+}
+
+type HealthStatus struct {
+	Errors        map[string]int64 `json:"errors"`
+	LastError     string           `json:"last_error,omitempty"`
+	LastErrorAt   *time.Time       `json:"last_error_at,omitempty"`
+	LastSuccessAt *time.Time       `json:"last_success_at,omitempty"`
+}
+
+// Health counts errors of the accrual loop by stages for the status endpoint.
+type Health struct {
+	mu            sync.Mutex
+	errors        map[string]int64
+	lastError     string
+	lastErrorAt   time.Time
+	lastSuccessAt time.Time
+}
+
+var _ Reporter = &Health{}
+
+func NewHealth() *Health {
+	return &Health{
+		errors: make(map[string]int64),
+	}
+}
+
+func (h *Health) ReportError(stage string, number order.OrderNumber, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.errors[stage]++
+	h.lastError = err.Error()
+	if number != "" {
+		h.lastError = string(number) + ": " + h.lastError
+	}
+	h.lastErrorAt = time.Now()
+}
+
+func (h *Health) ReportSuccess(stage string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastSuccessAt = time.Now()
+}
+
+func (h *Health) Status() HealthStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := HealthStatus{
+		Errors:    make(map[string]int64, len(h.errors)),
+		LastError: h.lastError,
+	}
+	for stage, count := range h.errors {
+		s.Errors[stage] = count
+	}
+	if !h.lastErrorAt.IsZero() {
+		lastErrorAt := h.lastErrorAt
+		s.LastErrorAt = &lastErrorAt
+	}
+	if !h.lastSuccessAt.IsZero() {
+		lastSuccessAt := h.lastSuccessAt
+		s.LastSuccessAt = &lastSuccessAt
+	}
+	return s
+}
@@ -0,0 +1,166 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/Nexadis/gophmart/internal/logger"
+	"github.com/Nexadis/gophmart/internal/order"
+)
+
+const (
+	DefaultBasePath  = `/api`
+	DefaultUserAgent = `gophermart`
+	PathGetAccrual   = `/orders/{number}`
+	APIGetAccrual    = DefaultBasePath + PathGetAccrual
+)
+
+// DefaultRetryAfter is delay after 429 response without valid Retry-After.
+const DefaultRetryAfter = 60 * time.Second
+
+var (
+	ErrInvalidCA     = errors.New(`no certificates in CA bundle`)
+	ErrInvalidStatus = errors.New(`invalid status code`)
+)
+
+// StatusError is a failed response of accrual system with status Code.
+type StatusError struct {
+	Code int
+	Err  error
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: status code %d", e.Err, e.Code)
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPConfig sets up connection to accrual system, zero values are defaults.
+type HTTPConfig struct {
+	Timeout time.Duration
+	// CAFile is PEM bundle to verify accrual system, system pool is used if empty.
+	CAFile string
+	// CertFile and KeyFile are client certificate for mTLS.
+	CertFile  string
+	KeyFile   string
+	BasePath  string
+	UserAgent string
+	Debug     bool
+}
+
+// HTTPProvider checks orders in accrual system by its HTTP API.
+type HTTPProvider struct {
+	client   *resty.Client
+	Addr     string
+	basePath string
+}
+
+var _ AccrualProvider = &HTTPProvider{}
+
+func NewHTTPProvider(addr string, config HTTPConfig) (*HTTPProvider, error) {
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	userAgent := config.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	basePath := config.BasePath
+	if basePath == "" {
+		basePath = DefaultBasePath
+	}
+	client := resty.New().
+		SetDebug(config.Debug).
+		SetTimeout(config.Timeout).
+		SetHeader("User-Agent", userAgent)
+	if tlsConfig != nil {
+		client.SetTLSClientConfig(tlsConfig)
+	}
+	return &HTTPProvider{
+		client:   client,
+		Addr:     addr,
+		basePath: basePath,
+	}, nil
+}
+
+func newTLSConfig(config HTTPConfig) (*tls.Config, error) {
+	if config.CAFile == "" && config.CertFile == "" {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if config.CAFile != "" {
+		ca, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidCA, config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+func (p *HTTPProvider) Check(ctx context.Context, number order.OrderNumber) (Accrual, error) {
+	endpoint := fmt.Sprintf("%s%s%s", p.Addr, p.basePath, PathGetAccrual)
+	a := Accrual{}
+	resp, err := p.client.R().
+		SetContext(ctx).
+		SetPathParam("number", string(number)).
+		SetResult(&a).
+		Get(endpoint)
+	if err != nil {
+		return Accrual{}, fmt.Errorf("%w: %s", ErrUnavailable, err)
+	}
+
+	switch code := resp.StatusCode(); {
+	case code == http.StatusOK:
+		return a, nil
+	case code == http.StatusTooManyRequests:
+		retryAfter := parseRetryAfter(resp.Header().Get("Retry-After"), time.Now())
+		logger.Logger.Infof("Too many requests, retry after %s", retryAfter)
+		return Accrual{}, &StatusError{Code: code, Err: &RateLimitError{RetryAfter: retryAfter}}
+	case code == http.StatusNoContent:
+		return Accrual{}, &StatusError{Code: code, Err: ErrNotRegistered}
+	case code >= http.StatusInternalServerError:
+		return Accrual{}, &StatusError{Code: code, Err: ErrUnavailable}
+	default:
+		return Accrual{}, &StatusError{Code: code, Err: ErrInvalidStatus}
+	}
+}
+
+// parseRetryAfter returns delay from Retry-After in seconds or HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if delay := at.Sub(now); delay > 0 {
+			return delay
+		}
+		return 0
+	}
+	return DefaultRetryAfter
+}
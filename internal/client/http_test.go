@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/gophmart/internal/money"
+)
+
+func TestHTTPProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/partner/orders/12345678903":
+			assert.Equal(t, "gophermart-test", r.UserAgent())
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"order":"12345678903","status":"PROCESSED","accrual":500}`))
+		case "/partner/orders/25461716":
+			time.Sleep(100 * time.Millisecond)
+		case "/partner/orders/4111111111111111":
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+	p, err := NewHTTPProvider(srv.URL, HTTPConfig{
+		Timeout:   50 * time.Millisecond,
+		BasePath:  "/partner",
+		UserAgent: "gophermart-test",
+	})
+	require.NoError(t, err)
+
+	a, err := p.Check(context.Background(), "12345678903")
+	if assert.NoError(t, err) && assert.NotNil(t, a.Accrual) {
+		assert.Equal(t, StatusProcessed, a.Status)
+		assert.Equal(t, money.Amount(50000), *a.Accrual)
+	}
+	_, err = p.Check(context.Background(), "25461716")
+	assert.ErrorIs(t, err, ErrUnavailable)
+	_, err = p.Check(context.Background(), "18")
+	assert.ErrorIs(t, err, ErrNotRegistered)
+	_, err = p.Check(context.Background(), "4111111111111111")
+	var rateLimit *RateLimitError
+	if assert.ErrorAs(t, err, &rateLimit) {
+		assert.Equal(t, 2*time.Minute, rateLimit.RetryAfter)
+	}
+	var statusErr *StatusError
+	if assert.ErrorAs(t, err, &statusErr) {
+		assert.Equal(t, http.StatusTooManyRequests, statusErr.Code)
+	}
+
+	_, err = NewHTTPProvider(srv.URL, HTTPConfig{CAFile: "/nonexistent/ca.pem"})
+	assert.Error(t, err)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, time.June, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value string
+		delay time.Duration
+	}{
+		{"Seconds", "30", 30 * time.Second},
+		{"HTTP date", now.Add(time.Minute).Format(http.TimeFormat), time.Minute},
+		{"Past date", now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"Missing", "", DefaultRetryAfter},
+		{"Invalid", "soon", DefaultRetryAfter},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.delay, parseRetryAfter(test.value, now))
+		})
+	}
+}
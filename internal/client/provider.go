@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Nexadis/gophmart/internal/order"
+)
+
+// AccrualProvider returns accrual for order from an accrual system.
+// It returns ErrNotRegistered for unknown orders and ErrUnavailable if the system is down.
+type AccrualProvider interface {
+	Check(ctx context.Context, number order.OrderNumber) (Accrual, error)
+}
+
+type Route struct {
+	Prefix string
+	Addr   string
+}
+
+type route struct {
+	prefix   string
+	provider AccrualProvider
+}
+
+// Router passes order to provider with the longest matching prefix of order number.
+type Router struct {
+	routes   []route
+	fallback AccrualProvider
+}
+
+var _ AccrualProvider = &Router{}
+
+func NewRouter(fallback AccrualProvider) *Router {
+	return &Router{
+		fallback: fallback,
+	}
+}
+
+func (r *Router) Route(prefix string, provider AccrualProvider) {
+	r.routes = append(r.routes, route{
+		prefix:   prefix,
+		provider: provider,
+	})
+}
+
+func (r *Router) Provider(number order.OrderNumber) AccrualProvider {
+	route := r.match(number)
+	if route == nil {
+		return r.fallback
+	}
+	return route.provider
+}
+
+// Prefix returns prefix of the route for the order, it is empty for fallback provider.
+func (r *Router) Prefix(number order.OrderNumber) string {
+	route := r.match(number)
+	if route == nil {
+		return ""
+	}
+	return route.prefix
+}
+
+// Prefixes returns prefixes of all routes.
+func (r *Router) Prefixes() []string {
+	prefixes := make([]string, 0, len(r.routes))
+	for _, route := range r.routes {
+		prefixes = append(prefixes, route.prefix)
+	}
+	return prefixes
+}
+
+// match returns route with the longest matching prefix or nil.
+func (r *Router) match(number order.OrderNumber) *route {
+	var matched *route
+	for i := range r.routes {
+		route := &r.routes[i]
+		if (matched == nil || len(route.prefix) > len(matched.prefix)) && strings.HasPrefix(string(number), route.prefix) {
+			matched = route
+		}
+	}
+	return matched
+}
+
+func (r *Router) Check(ctx context.Context, number order.OrderNumber) (Accrual, error) {
+	provider := r.Provider(number)
+	if provider == nil {
+		return Accrual{}, ErrNotRegistered
+	}
+	return provider.Check(ctx, number)
+}
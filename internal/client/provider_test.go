@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nexadis/gophmart/internal/money"
+	"github.com/Nexadis/gophmart/internal/order"
+)
+
+var (
+	mainPoints    = money.Amount(10000)
+	partnerPoints = money.Amount(500)
+	routerTests   = []struct {
+		name    string
+		number  order.OrderNumber
+		status  string
+		accrual *money.Amount
+		err     error
+	}{
+		{"Main provider", "12345678903", StatusProcessed, &mainPoints, nil},
+		{"Partner provider", "4561261212345467", StatusProcessed, &partnerPoints, nil},
+		{"Longest prefix", "4577", StatusProcessing, nil, nil},
+		{"Unknown order", "9", "", nil, ErrNotRegistered},
+	}
+)
+
+func TestRouter(t *testing.T) {
+	router := NewRouter(&StaticProvider{
+		Rules: []StaticRule{
+			{Prefix: "1", Status: StatusProcessed, Accrual: &mainPoints},
+		},
+	})
+	router.Route("4", &StaticProvider{
+		Rules: []StaticRule{
+			{Status: StatusProcessed, Accrual: &partnerPoints},
+		},
+	})
+	router.Route("457", &StaticProvider{
+		Rules: []StaticRule{
+			{Status: StatusProcessing},
+		},
+	})
+	for _, test := range routerTests {
+		t.Run(test.name, func(t *testing.T) {
+			a, err := router.Check(context.Background(), test.number)
+			if test.err != nil {
+				assert.ErrorIs(t, err, test.err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.number, a.Order)
+				assert.Equal(t, test.status, a.Status)
+				assert.Equal(t, test.accrual, a.Accrual)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Nexadis/gophmart/internal/logger"
+	"github.com/Nexadis/gophmart/internal/order"
+)
+
+// RevisionConfig sets how long PROCESSED orders are re-checked and how often,
+// zero Window disables revisions.
+type RevisionConfig struct {
+	Window   time.Duration
+	Interval time.Duration
+}
+
+// recordAccrual saves response of accrual system or error of request to the history.
+func (c *Client) recordAccrual(ctx context.Context, number order.OrderNumber, a Accrual, checkErr error) {
+	r := &order.AccrualRecord{
+		Number:     number,
+		Status:     a.Status,
+		Accrual:    a.Accrual,
+		ReceivedAt: time.Now(),
+	}
+	if checkErr != nil {
+		r.Error = checkErr.Error()
+		var statusErr *StatusError
+		if errors.As(checkErr, &statusErr) {
+			r.Code = statusErr.Code
+		}
+	}
+	err := c.db.AddAccrualRecord(ctx, r)
+	if err != nil {
+		c.ReportError(StageUpdate, number, err)
+	}
+}
+
+// nextRevision returns time of the next check of PROCESSED order.
+func (c *Client) nextRevision() time.Time {
+	interval := c.revision.Interval
+	if interval <= 0 || c.revision.Window <= 0 {
+		interval = c.revision.Window
+	}
+	return time.Now().Add(interval)
+}
+
+// reviseOrder updates accrual of PROCESSED order if accrual system changed it.
+func (c *Client) reviseOrder(ctx context.Context, o *order.Order, a Accrual, err error) {
+	if err != nil && !errors.Is(err, ErrNotRegistered) {
+		c.ReportError(StageCheck, o.Number, err)
+	}
+	if err != nil || a.Status != StatusProcessed || equalPoints(o.Accrual, a.Accrual) {
+		err = c.db.ScheduleOrder(ctx, o.Number, o.Attempts, c.nextRevision())
+		if err != nil {
+			c.ReportError(StageUpdate, o.Number, err)
+		}
+		return
+	}
+	adj, err := c.db.ReviseAccrual(ctx, o.Number, a.Accrual, time.Now(), c.nextRevision())
+	if err != nil {
+		c.ReportError(StageUpdate, o.Number, err)
+		return
+	}
+	logger.Logger.Infof("Accrual of order %s revised by %d", o.Number, adj.Delta)
+	c.reportSuccess(StageCheck)
+	o.Accrual = a.Accrual
+	c.reward(ctx, o)
+}
@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+
+	"github.com/Nexadis/gophmart/internal/order"
+)
+
+// Rewarder credits bonuses on top of accrual of PROCESSED orders.
+type Rewarder interface {
+	Reward(ctx context.Context, o *order.Order) error
+}
+
+// reward passes the order to Rewarder once it becomes PROCESSED or its accrual is revised.
+// Pending reward is cleared only on success, so failed rewards are retried by rewardPending.
+func (c *Client) reward(ctx context.Context, o *order.Order) {
+	if c.rewarder == nil || o.Status != order.StatusProcessed {
+		return
+	}
+	err := c.rewarder.Reward(ctx, o)
+	if err != nil {
+		c.ReportError(StageReward, o.Number, err)
+		return
+	}
+	err = c.db.SetRewarded(ctx, o.Number, o.Accrual)
+	if err != nil {
+		c.ReportError(StageReward, o.Number, err)
+	}
+}
+
+// rewardPending retries rewards of PROCESSED orders which failed or were revised.
+func (c *Client) rewardPending(ctx context.Context) {
+	if c.rewarder == nil {
+		return
+	}
+	orders, err := c.db.GetRewardPending(ctx, c.batchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			c.ReportError(StageReward, "", err)
+		}
+		return
+	}
+	for _, o := range orders {
+		c.reward(ctx, o)
+	}
+}
@@ -0,0 +1,41 @@
+package client
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Nexadis/gophmart/internal/money"
+	"github.com/Nexadis/gophmart/internal/order"
+)
+
+// StaticRule answers Status and Accrual for orders with Prefix, Err is returned if it is set.
+type StaticRule struct {
+	Prefix  string
+	Status  string
+	Accrual *money.Amount
+	Err     error
+}
+
+// StaticProvider answers by the first matching rule, it is used instead of accrual system in tests.
+type StaticProvider struct {
+	Rules []StaticRule
+}
+
+var _ AccrualProvider = &StaticProvider{}
+
+func (p *StaticProvider) Check(ctx context.Context, number order.OrderNumber) (Accrual, error) {
+	for _, rule := range p.Rules {
+		if !strings.HasPrefix(string(number), rule.Prefix) {
+			continue
+		}
+		if rule.Err != nil {
+			return Accrual{}, rule.Err
+		}
+		return Accrual{
+			Order:   number,
+			Status:  rule.Status,
+			Accrual: rule.Accrual,
+		}, nil
+	}
+	return Accrual{}, ErrNotRegistered
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Nexadis/gophmart/internal/logger"
+	"github.com/Nexadis/gophmart/internal/money"
+	"github.com/Nexadis/gophmart/internal/order"
+)
+
+var (
+	ErrOrderFinal    = errors.New(`order already has final status`)
+	ErrUnknownStatus = errors.New(`unknown accrual status`)
+	// ErrStaleTimestamp is returned for missing, invalid or too old timestamp of pushed accruals.
+	ErrStaleTimestamp = errors.New(`stale timestamp`)
+)
+
+// Sign returns hex HMAC-SHA256 of timestamp and body, accrual system signs pushed accruals with it.
+// Timestamp is unix seconds when accruals were sent, it makes replayed requests stale.
+func Sign(timestamp string, body, secret []byte) string {
+	return hex.EncodeToString(signature(timestamp, body, secret))
+}
+
+func IsValidSign(timestamp string, body, secret []byte, sign string) bool {
+	expected, err := hex.DecodeString(sign)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(expected, signature(timestamp, body, secret))
+}
+
+func signature(timestamp string, body, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// ParseTimestamp returns time of signed timestamp, it returns ErrStaleTimestamp
+// if the time differs from now more than tolerance.
+func ParseTimestamp(timestamp string, now time.Time, tolerance time.Duration) (time.Time, error) {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q", ErrStaleTimestamp, timestamp)
+	}
+	sentAt := time.Unix(seconds, 0)
+	if diff := now.Sub(sentAt); diff > tolerance || diff < -tolerance {
+		return time.Time{}, fmt.Errorf("%w: sent at %s", ErrStaleTimestamp, sentAt)
+	}
+	return sentAt, nil
+}
+
+// Apply saves accrual pushed by accrual system and reports if the order was changed.
+// Malformed order number is returned as order.ErrInvalidNum.
+// Repeated accruals don't change the order, orders with final status aren't changed.
+// Revision sent before the latest applied one is returned as db.ErrStaleRevision.
+func (c *Client) Apply(ctx context.Context, a Accrual, sentAt time.Time) (bool, error) {
+	var err error
+	a.Order, err = c.parser.Parse(string(a.Order))
+	if err != nil {
+		return false, err
+	}
+	o, err := c.db.GetOrder(ctx, a.Order)
+	if err != nil {
+		return false, err
+	}
+	status, ok := accrualToOrderStatus(a.Status)
+	if !ok {
+		logger.Logger.Errorf("Unknown accrual status %q of order %s, status isn't changed", a.Status, a.Order)
+		return false, ErrUnknownStatus
+	}
+	if o.Status == status && equalPoints(o.Accrual, a.Accrual) {
+		return false, nil
+	}
+	c.recordAccrual(ctx, a.Order, a, nil)
+	if o.Status == order.StatusProcessed && status == order.StatusProcessed {
+		_, err = c.db.ReviseAccrual(ctx, o.Number, a.Accrual, sentAt, c.nextRevision())
+		if err != nil {
+			return false, err
+		}
+		o.Accrual = a.Accrual
+		c.reward(ctx, o)
+		return true, nil
+	}
+	if o.Status.IsFinal() {
+		return false, ErrOrderFinal
+	}
+	if !o.Status.CanTransit(status) {
+		return false, o.Status.Transit(status)
+	}
+	o.Status = status
+	o.Accrual = a.Accrual
+	o.Reason = ""
+	next := time.Now().Add(c.wait)
+	if status == order.StatusProcessed {
+		next = c.nextRevision()
+	}
+	o.NextCheckAt = &next
+	err = c.db.UpdateOrder(ctx, o)
+	if err != nil {
+		return false, err
+	}
+	c.reward(ctx, o)
+	return true, nil
+}
+
+func equalPoints(a, b *money.Amount) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,30 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{"order":"12345678903","status":"PROCESSED","accrual":500}`)
+	sign := Sign("1700000000", body, secret)
+	assert.True(t, IsValidSign("1700000000", body, secret, sign))
+	assert.False(t, IsValidSign("1700000001", body, secret, sign), "timestamp is signed")
+	assert.False(t, IsValidSign("1700000000", body, []byte("other"), sign))
+	assert.False(t, IsValidSign("1700000000", body, secret, "not hex"))
+}
+
+func TestParseTimestamp(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	sentAt, err := ParseTimestamp("1699999900", now, 5*time.Minute)
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Unix(1699999900, 0), sentAt)
+	}
+	for _, timestamp := range []string{"", "abc", "1699999000", "1700001000"} {
+		_, err = ParseTimestamp(timestamp, now, 5*time.Minute)
+		assert.ErrorIs(t, err, ErrStaleTimestamp, timestamp)
+	}
+}
@@ -3,45 +3,197 @@ package db
 import (
 	"context"
 	"errors"
+	"time"
 
+	"github.com/Nexadis/gophmart/internal/campaign"
+	"github.com/Nexadis/gophmart/internal/expiry"
+	"github.com/Nexadis/gophmart/internal/idempotency"
+	"github.com/Nexadis/gophmart/internal/money"
 	"github.com/Nexadis/gophmart/internal/order"
+	"github.com/Nexadis/gophmart/internal/policy"
+	"github.com/Nexadis/gophmart/internal/promo"
+	"github.com/Nexadis/gophmart/internal/referral"
 	"github.com/Nexadis/gophmart/internal/user"
 )
 
 var (
-	ErrUserIsExist    = errors.New(`user is exist`)
-	ErrUserNotFound   = errors.New(`user not found`)
-	ErrOrderNotFound  = errors.New(`order not found`)
-	ErrOrderAdded     = errors.New(`order was added`)
-	ErrOtherUserOrder = errors.New(`order was added by other user`)
-	ErrWithdrawAdded  = errors.New(`order was payed`)
-	ErrSomeWrong      = errors.New(`some wrong`)
+	ErrUserIsExist      = errors.New(`user is exist`)
+	ErrUserNotFound     = errors.New(`user not found`)
+	ErrOrderNotFound    = errors.New(`order not found`)
+	ErrOrderAdded       = errors.New(`order was added`)
+	ErrOtherUserOrder   = errors.New(`order was added by other user`)
+	ErrWithdrawAdded    = errors.New(`order was payed`)
+	ErrWithdrawNotFound = errors.New(`withdrawal not found`)
+	ErrHoldAdded        = errors.New(`order was held`)
+	ErrHoldNotFound     = errors.New(`hold not found`)
+	ErrCampaignNotFound = errors.New(`campaign not found`)
+	ErrRewardNotFound   = errors.New(`order wasn't rewarded`)
+	ErrPromoAdded       = errors.New(`promo code exists`)
+	ErrPromoNotFound    = errors.New(`promo code not found`)
+	ErrPromoRedeemed    = errors.New(`promo code was redeemed by user`)
+	ErrReferralNotFound = errors.New(`referral not found`)
+	ErrReferralAdded    = errors.New(`user was already referred`)
+	ErrOrderNotStale    = errors.New(`order isn't stale`)
+	ErrKeyReused        = errors.New(`idempotency key was used for other request`)
+	ErrNotEnoughBalance = errors.New(`not enough balance`)
+	ErrStaleRevision    = errors.New(`accrual was revised later`)
+	ErrSomeWrong        = errors.New(`some wrong`)
 )
 
 type UserStore interface {
 	AddUser(ctx context.Context, user *user.User) error
 	GetUser(ctx context.Context, login string) (*user.User, error)
+	GetLogins(ctx context.Context) ([]string, error)
+	GetTier(ctx context.Context, login string) (string, error)
+	SetTier(ctx context.Context, login, tier string) error
 }
 
 type OrdersStore interface {
 	AddOrder(ctx context.Context, o *order.Order) error
+	// AddOrders adds orders in one transaction, returned errors are nil, ErrOrderAdded or ErrOtherUserOrder for each order.
+	AddOrders(ctx context.Context, orders []*order.Order) ([]error, error)
 	GetOrder(ctx context.Context, number order.OrderNumber) (*order.Order, error)
 	GetOrders(ctx context.Context, owner string) ([]*order.Order, error)
-	GetAccruals(ctx context.Context, owner string) (int64, error)
+	GetStatusHistory(ctx context.Context, number order.OrderNumber) ([]*order.StatusChange, error)
+	GetAccruals(ctx context.Context, owner string) (money.Amount, error)
+	// CountProcessed returns number of PROCESSED orders of the owner processed until the time.
+	CountProcessed(ctx context.Context, owner string, until time.Time) (int, error)
+	// GetAccruedSince returns sum of accruals of orders processed since the time.
+	GetAccruedSince(ctx context.Context, owner string, since time.Time) (money.Amount, error)
 	UpdateOrder(ctx context.Context, o *order.Order) error
 	GetWithStatus(ctx context.Context, s order.Status) ([]order.OrderNumber, error)
+	// GetDueOrders returns unprocessed orders and orders processed within revision window to check.
+	GetDueOrders(ctx context.Context, limit int, revision time.Duration) ([]*order.Order, error)
+	ScheduleOrder(ctx context.Context, number order.OrderNumber, attempts int, next time.Time) error
+	RequeueOrder(ctx context.Context, number order.OrderNumber) error
+	AddAccrualRecord(ctx context.Context, r *order.AccrualRecord) error
+	// ReviseAccrual sets accrual of PROCESSED order known at asOf and records adjustment of balance,
+	// it returns ErrStaleRevision if the order was processed or revised later than asOf.
+	ReviseAccrual(ctx context.Context, number order.OrderNumber, accrual *money.Amount, asOf, next time.Time) (*order.Adjustment, error)
+	// GetRewardPending returns PROCESSED orders whose bonuses aren't credited for the current accrual.
+	GetRewardPending(ctx context.Context, limit int) ([]*order.Order, error)
+	// SetRewarded clears pending reward of the order if its accrual is still the same.
+	SetRewarded(ctx context.Context, number order.OrderNumber, accrual *money.Amount) error
+}
+
+type OrdersNotifier interface {
+	ListenOrders(ctx context.Context, handle func(order.OrderNumber)) error
 }
 
 type WithdrawalsStore interface {
-	AddWithdrawal(ctx context.Context, wd *order.Withdraw) error
+	// AddWithdrawal saves withdrawal if it passes the policy and balance of the owner is enough,
+	// it returns policy.Violation or ErrNotEnoughBalance otherwise.
+	AddWithdrawal(ctx context.Context, wd *order.Withdraw, p policy.Withdraw) error
 	GetWithdrawals(ctx context.Context, owner string) ([]*order.Withdraw, error)
-	GetWithdrawn(ctx context.Context, owner string) (int64, error)
+	// GetWithdrawn returns sum of withdrawals without refunds.
+	GetWithdrawn(ctx context.Context, owner string) (money.Amount, error)
+	// GetWithdrawnSince returns sum of withdrawals without refunds processed since the time.
+	GetWithdrawnSince(ctx context.Context, owner string, since time.Time) (money.Amount, error)
+	// RefundWithdrawal refunds sum of withdrawal for the order, nil sum refunds the rest.
+	RefundWithdrawal(ctx context.Context, number order.OrderNumber, sum *money.Amount, cancel bool) (*order.Withdraw, error)
+}
+
+type BonusesStore interface {
+	// SetBonuses saves the reward of the order if it is the first one and replaces tier and campaign bonuses,
+	// referral bonuses are kept.
+	SetBonuses(ctx context.Context, r *campaign.Reward, bonuses []*order.Bonus) error
+	GetBonuses(ctx context.Context, owner string) (money.Amount, error)
+}
+
+type CampaignsStore interface {
+	// AddCampaign saves campaign and sets its ID.
+	AddCampaign(ctx context.Context, c *campaign.Campaign) error
+	GetCampaigns(ctx context.Context) ([]*campaign.Campaign, error)
+	// GetReward returns tier and campaigns of the first reward of the order including deleted campaigns.
+	GetReward(ctx context.Context, number order.OrderNumber) (*campaign.Reward, error)
+	DeleteCampaign(ctx context.Context, id int64) error
+}
+
+type PromoStore interface {
+	// AddPromoCodes saves all codes or none if any code exists.
+	AddPromoCodes(ctx context.Context, codes []*promo.Code) error
+	// RedeemPromo credits the code to the owner once.
+	RedeemPromo(ctx context.Context, code, owner string, now time.Time) (*promo.Redemption, error)
+	GetRedeemed(ctx context.Context, owner string) (money.Amount, error)
+}
+
+type ReferralsStore interface {
+	// SetReferralCode saves the code if the user has no code and returns the user's code.
+	SetReferralCode(ctx context.Context, login, code string) (string, error)
+	// GetReferrer returns login of the code owner.
+	GetReferrer(ctx context.Context, code string) (string, error)
+	// AddReferral saves referral if referrer has less than max referrals, max 0 is unlimited.
+	AddReferral(ctx context.Context, r *referral.Referral, max int) error
+	CountReferrals(ctx context.Context, referrer string) (int, error)
+	// GetReferrals returns referrals invited by the referrer, the newest first.
+	GetReferrals(ctx context.Context, referrer string) ([]*referral.Referral, error)
+	GetReferral(ctx context.Context, referee string) (*referral.Referral, error)
+	// RewardReferral marks pending referral rewarded and credits bonuses once.
+	RewardReferral(ctx context.Context, r *referral.Referral, bonuses []*order.Bonus) error
+}
+
+type TransfersStore interface {
+	// AddTransfer saves transfer and sets its ID if it passes the policy and balance of the sender is enough,
+	// it returns policy.Violation or ErrNotEnoughBalance otherwise.
+	AddTransfer(ctx context.Context, t *user.Transfer, p policy.Withdraw) error
+	// GetTransfers returns incoming and outgoing transfers of the user, the newest first.
+	GetTransfers(ctx context.Context, login string) ([]*user.Transfer, error)
+	// GetTransferred returns sums of incoming and outgoing transfers of the user.
+	GetTransferred(ctx context.Context, login string) (in, out money.Amount, err error)
+	// GetTransferredSince returns sum of outgoing transfers since the time.
+	GetTransferredSince(ctx context.Context, login string, since time.Time) (money.Amount, error)
+}
+
+type HoldsStore interface {
+	// AddHold saves hold if it passes the policy and balance of the owner is enough,
+	// active holds are counted as spent points.
+	AddHold(ctx context.Context, h *order.Hold, p policy.Withdraw) error
+	// GetHeld returns sum of holds of the owner active at the time.
+	GetHeld(ctx context.Context, owner string, now time.Time) (money.Amount, error)
+	// CaptureHold turns active hold of the owner into withdrawal if it still passes the policy,
+	// it returns ErrNotEnoughBalance if held points aren't available anymore.
+	CaptureHold(ctx context.Context, owner string, number order.OrderNumber, now time.Time, p policy.Withdraw) (*order.Withdraw, error)
+	VoidHold(ctx context.Context, owner string, number order.OrderNumber, now time.Time) (*order.Hold, error)
+}
+
+type ExpirationsStore interface {
+	// GetLots returns accruals and bonuses of PROCESSED orders of the owner,
+	// bonuses of the owner for orders of other users are separate lots.
+	GetLots(ctx context.Context, owner string) ([]expiry.Lot, error)
+	// GetDebits returns points spent or held by the owner at the time, the oldest first.
+	GetDebits(ctx context.Context, owner string, now time.Time) ([]expiry.Debit, error)
+	GetExpirations(ctx context.Context, owner string) ([]*expiry.Expiration, error)
+	// GetExpired returns sum of recorded expirations of the owner.
+	GetExpired(ctx context.Context, owner string) (money.Amount, error)
+	// ExpirePoints plans due expirations of the owner by the policy and records them in one transaction
+	// with the owner locked, it returns recorded expirations.
+	ExpirePoints(ctx context.Context, owner string, p expiry.Policy, now time.Time) ([]*expiry.Expiration, error)
+	// GetExpiringOwners returns owners of orders processed before the time without recorded expiration.
+	GetExpiringOwners(ctx context.Context, processedBefore time.Time) ([]string, error)
+}
+
+type IdempotencyStore interface {
+	// BeginIdempotent reserves key for the request until r.LockedUntil, it returns saved record if key was reserved
+	// earlier than ttl ago. Key of unfinished request is reserved again after its lock expires.
+	// ErrKeyReused is returned if the key was reserved for request with other hash.
+	BeginIdempotent(ctx context.Context, r *idempotency.Record, ttl time.Duration) (*idempotency.Record, error)
+	SaveIdempotent(ctx context.Context, r *idempotency.Record) error
+	DeleteIdempotent(ctx context.Context, owner, key string) error
 }
 
 type Database interface {
 	Open(Addr string) error
 	UserStore
 	OrdersStore
+	OrdersNotifier
 	WithdrawalsStore
+	BonusesStore
+	CampaignsStore
+	PromoStore
+	TransfersStore
+	ReferralsStore
+	HoldsStore
+	ExpirationsStore
+	IdempotencyStore
 	Close() error
 }
@@ -2,17 +2,29 @@ package pg
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib"
 
+	"github.com/Nexadis/gophmart/internal/campaign"
 	"github.com/Nexadis/gophmart/internal/db"
+	"github.com/Nexadis/gophmart/internal/expiry"
+	"github.com/Nexadis/gophmart/internal/idempotency"
 	"github.com/Nexadis/gophmart/internal/logger"
+	"github.com/Nexadis/gophmart/internal/money"
 	"github.com/Nexadis/gophmart/internal/order"
+	"github.com/Nexadis/gophmart/internal/policy"
+	"github.com/Nexadis/gophmart/internal/promo"
+	"github.com/Nexadis/gophmart/internal/referral"
 	"github.com/Nexadis/gophmart/internal/user"
 )
 
@@ -26,26 +38,243 @@ const SchemaOrders = `CREATE TABLE Orders(
 	"number" VARCHAR(256) PRIMARY KEY,
 	"owner" VARCHAR(256) NOT NULL,
 	"status" VARCHAR(256) NOT NULL,
-	"accrual" INT,
-	"uploaded_at" TIMESTAMP NOT NULL);`
+	"accrual" BIGINT,
+	"uploaded_at" TIMESTAMP NOT NULL,
+	"attempts" INT NOT NULL DEFAULT 0,
+	"next_check_at" TIMESTAMP NOT NULL DEFAULT now(),
+	"reason" TEXT,
+	"processed_at" TIMESTAMP,
+	"requeued_at" TIMESTAMP,
+	"reward_pending" BOOLEAN NOT NULL DEFAULT false,
+	"revised_at" TIMESTAMP);`
+
+const SchemaAccrualHistory = `CREATE TABLE IF NOT EXISTS order_accrual_history(
+	"id" SERIAL PRIMARY KEY,
+	"number" VARCHAR(256) NOT NULL,
+	"status" VARCHAR(256) NOT NULL,
+	"accrual" BIGINT,
+	"code" INT NOT NULL DEFAULT 0,
+	"error" TEXT,
+	"received_at" TIMESTAMP NOT NULL
+);
+`
+
+const SchemaStatusHistory = `CREATE TABLE IF NOT EXISTS order_status_history(
+	"id" SERIAL PRIMARY KEY,
+	"number" VARCHAR(256) NOT NULL,
+	"status" VARCHAR(256) NOT NULL,
+	"accrual" BIGINT,
+	"changed_at" TIMESTAMP NOT NULL
+);
+`
+
+const SchemaAdjustments = `CREATE TABLE IF NOT EXISTS accrual_adjustments(
+	"id" SERIAL PRIMARY KEY,
+	"number" VARCHAR(256) NOT NULL,
+	"owner" VARCHAR(256) NOT NULL,
+	"delta" BIGINT NOT NULL,
+	"created_at" TIMESTAMP NOT NULL
+);
+`
 
 const SchemaWithdrawals = `CREATE TABLE withdrawals(
 	"order" VARCHAR(256) PRIMARY KEY,
 	"owner" VARCHAR(256) NOT NULL,
-	"sum" INT NOT NULL,
+	"sum" BIGINT NOT NULL,
 	"processed_at" TIMESTAMP NOT NULL
 );
 `
 
+const SchemaRefunds = `CREATE TABLE IF NOT EXISTS withdrawal_refunds(
+	"id" SERIAL PRIMARY KEY,
+	"order" VARCHAR(256) NOT NULL,
+	"owner" VARCHAR(256) NOT NULL,
+	"sum" BIGINT NOT NULL,
+	"status" VARCHAR(32) NOT NULL,
+	"created_at" TIMESTAMP NOT NULL
+);
+`
+
+const SchemaBonuses = `CREATE TABLE IF NOT EXISTS bonuses(
+	"number" VARCHAR(256) NOT NULL,
+	"owner" VARCHAR(256) NOT NULL,
+	"source" VARCHAR(256) NOT NULL,
+	"amount" BIGINT NOT NULL,
+	"created_at" TIMESTAMP NOT NULL,
+	PRIMARY KEY ("number", "source")
+);
+`
+
+const SchemaCampaigns = `CREATE TABLE IF NOT EXISTS campaigns(
+	"id" SERIAL PRIMARY KEY,
+	"name" VARCHAR(256) NOT NULL,
+	"starts_at" TIMESTAMP,
+	"ends_at" TIMESTAMP,
+	"first_orders" INT NOT NULL DEFAULT 0,
+	"min_accrual" BIGINT NOT NULL DEFAULT 0,
+	"segment" VARCHAR(256) NOT NULL DEFAULT '',
+	"multiplier" INT NOT NULL DEFAULT 0,
+	"fixed" BIGINT NOT NULL DEFAULT 0,
+	"deleted_at" TIMESTAMP
+);
+`
+
+// SchemaRewards keeps tier and campaigns used by the first reward of the order.
+const SchemaRewards = `CREATE TABLE IF NOT EXISTS rewards(
+	"number" VARCHAR(256) PRIMARY KEY,
+	"tier" VARCHAR(256) NOT NULL DEFAULT ''
+);
+`
+
+const SchemaRewardCampaigns = `CREATE TABLE IF NOT EXISTS reward_campaigns(
+	"number" VARCHAR(256) NOT NULL,
+	"campaign_id" BIGINT NOT NULL,
+	PRIMARY KEY ("number", "campaign_id")
+);
+`
+
+const SchemaPromoCodes = `CREATE TABLE IF NOT EXISTS promo_codes(
+	"code" VARCHAR(256) PRIMARY KEY,
+	"amount" BIGINT NOT NULL,
+	"max_uses" INT NOT NULL DEFAULT 0,
+	"used" INT NOT NULL DEFAULT 0,
+	"expires_at" TIMESTAMP,
+	"created_at" TIMESTAMP NOT NULL
+);
+`
+
+const SchemaPromoRedemptions = `CREATE TABLE IF NOT EXISTS promo_redemptions(
+	"code" VARCHAR(256) NOT NULL,
+	"owner" VARCHAR(256) NOT NULL,
+	"amount" BIGINT NOT NULL,
+	"redeemed_at" TIMESTAMP NOT NULL,
+	PRIMARY KEY ("code", "owner")
+);
+`
+
+const SchemaTransfers = `CREATE TABLE IF NOT EXISTS transfers(
+	"id" SERIAL PRIMARY KEY,
+	"from" VARCHAR(256) NOT NULL,
+	"to" VARCHAR(256) NOT NULL,
+	"sum" BIGINT NOT NULL,
+	"created_at" TIMESTAMP NOT NULL
+);
+`
+
+const SchemaReferrals = `CREATE TABLE IF NOT EXISTS referrals(
+	"referee" VARCHAR(256) PRIMARY KEY,
+	"referrer" VARCHAR(256) NOT NULL,
+	"status" VARCHAR(32) NOT NULL,
+	"order" VARCHAR(256) NOT NULL DEFAULT '',
+	"created_at" TIMESTAMP NOT NULL,
+	"rewarded_at" TIMESTAMP
+);
+`
+
+const SchemaHolds = `CREATE TABLE IF NOT EXISTS holds(
+	"order" VARCHAR(256) PRIMARY KEY,
+	"owner" VARCHAR(256) NOT NULL,
+	"sum" BIGINT NOT NULL,
+	"status" VARCHAR(32) NOT NULL,
+	"created_at" TIMESTAMP NOT NULL,
+	"expires_at" TIMESTAMP NOT NULL
+);
+`
+
+const SchemaExpirations = `CREATE TABLE IF NOT EXISTS points_expirations(
+	"number" VARCHAR(256) NOT NULL,
+	"owner" VARCHAR(256) NOT NULL,
+	"amount" BIGINT NOT NULL,
+	"expired_at" TIMESTAMP NOT NULL,
+	PRIMARY KEY ("number", "owner")
+);
+`
+
+const SchemaIdempotency = `CREATE TABLE IF NOT EXISTS idempotency_keys(
+	"owner" VARCHAR(256) NOT NULL,
+	"key" VARCHAR(256) NOT NULL,
+	"hash" VARCHAR(64) NOT NULL,
+	"status" INT NOT NULL DEFAULT 0,
+	"content_type" VARCHAR(256) NOT NULL DEFAULT '',
+	"body" BYTEA,
+	"created_at" TIMESTAMP NOT NULL,
+	"locked_until" TIMESTAMP NOT NULL DEFAULT now(),
+	PRIMARY KEY ("owner", "key")
+);
+`
+
+// ChannelNewOrders is the channel for NOTIFY about uploaded orders.
+// Payload is "<instance id>:<order number>".
+const ChannelNewOrders = `orders_new`
+
+// Migrations update tables created by older versions of schemas.
+var Migrations = []string{
+	`ALTER TABLE Orders ADD COLUMN IF NOT EXISTS "attempts" INT NOT NULL DEFAULT 0`,
+	`ALTER TABLE Orders ADD COLUMN IF NOT EXISTS "next_check_at" TIMESTAMP NOT NULL DEFAULT now()`,
+	`ALTER TABLE Orders ADD COLUMN IF NOT EXISTS "reason" TEXT`,
+	`ALTER TABLE Orders ADD COLUMN IF NOT EXISTS "processed_at" TIMESTAMP`,
+	`ALTER TABLE Orders ADD COLUMN IF NOT EXISTS "requeued_at" TIMESTAMP`,
+	`ALTER TABLE Orders ADD COLUMN IF NOT EXISTS "reward_pending" BOOLEAN NOT NULL DEFAULT false`,
+	`ALTER TABLE Orders ADD COLUMN IF NOT EXISTS "revised_at" TIMESTAMP`,
+	`ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS "status" VARCHAR(32) NOT NULL DEFAULT 'COMPLETED'`,
+	`ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS "refunded" BIGINT NOT NULL DEFAULT 0`,
+	`ALTER TABLE order_accrual_history ADD COLUMN IF NOT EXISTS "code" INT NOT NULL DEFAULT 0`,
+	`ALTER TABLE order_accrual_history ADD COLUMN IF NOT EXISTS "error" TEXT`,
+	`CREATE INDEX IF NOT EXISTS order_accrual_history_number ON order_accrual_history ("number")`,
+	`CREATE INDEX IF NOT EXISTS order_status_history_number ON order_status_history ("number")`,
+	`ALTER TABLE Users ADD COLUMN IF NOT EXISTS "tier" VARCHAR(256) NOT NULL DEFAULT ''`,
+	`ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS "deleted_at" TIMESTAMP`,
+	`CREATE INDEX IF NOT EXISTS bonuses_owner ON bonuses ("owner")`,
+	`CREATE INDEX IF NOT EXISTS promo_redemptions_owner ON promo_redemptions ("owner")`,
+	`CREATE INDEX IF NOT EXISTS transfers_from ON transfers ("from")`,
+	`CREATE INDEX IF NOT EXISTS transfers_to ON transfers ("to")`,
+	`ALTER TABLE Users ADD COLUMN IF NOT EXISTS "referral_code" VARCHAR(256) UNIQUE`,
+	`CREATE INDEX IF NOT EXISTS referrals_referrer ON referrals ("referrer")`,
+	`CREATE INDEX IF NOT EXISTS holds_owner ON holds ("owner") WHERE "status"='ACTIVE'`,
+	`ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS "locked_until" TIMESTAMP NOT NULL DEFAULT now()`,
+	`CREATE INDEX IF NOT EXISTS points_expirations_owner ON points_expirations ("owner")`,
+	// bonuses of other users for the order, e.g. of referrer, expire apart from the order owner's points
+	`DO $$ BEGIN
+	IF (SELECT COUNT(*) FROM information_schema.key_column_usage WHERE "table_name"='points_expirations' AND "constraint_name"='points_expirations_pkey')=1 THEN
+		ALTER TABLE points_expirations DROP CONSTRAINT points_expirations_pkey;
+		ALTER TABLE points_expirations ADD PRIMARY KEY ("number", "owner");
+	END IF;
+	END $$`,
+	`CREATE INDEX IF NOT EXISTS orders_next_check_at ON Orders ("next_check_at") WHERE "status" IN ('NEW', 'PROCESSING')`,
+	`CREATE INDEX IF NOT EXISTS orders_revision_next_check_at ON Orders ("next_check_at", "processed_at") WHERE "status"='PROCESSED'`,
+	`CREATE INDEX IF NOT EXISTS orders_reward_pending ON Orders ("processed_at") WHERE "reward_pending"`,
+	// amounts are int64 points
+	`ALTER TABLE Orders ALTER COLUMN "accrual" TYPE BIGINT`,
+	`ALTER TABLE order_accrual_history ALTER COLUMN "accrual" TYPE BIGINT`,
+	`ALTER TABLE order_status_history ALTER COLUMN "accrual" TYPE BIGINT`,
+	`ALTER TABLE accrual_adjustments ALTER COLUMN "delta" TYPE BIGINT`,
+	`ALTER TABLE withdrawals ALTER COLUMN "sum" TYPE BIGINT`,
+	`ALTER TABLE withdrawal_refunds ALTER COLUMN "sum" TYPE BIGINT`,
+	`ALTER TABLE bonuses ALTER COLUMN "amount" TYPE BIGINT`,
+	`ALTER TABLE campaigns ALTER COLUMN "min_accrual" TYPE BIGINT`,
+	`ALTER TABLE campaigns ALTER COLUMN "fixed" TYPE BIGINT`,
+	`ALTER TABLE promo_codes ALTER COLUMN "amount" TYPE BIGINT`,
+	`ALTER TABLE promo_redemptions ALTER COLUMN "amount" TYPE BIGINT`,
+	`ALTER TABLE transfers ALTER COLUMN "sum" TYPE BIGINT`,
+	`ALTER TABLE holds ALTER COLUMN "sum" TYPE BIGINT`,
+	`ALTER TABLE points_expirations ALTER COLUMN "amount" TYPE BIGINT`,
+	`ALTER TABLE withdrawals ALTER COLUMN "refunded" TYPE BIGINT`,
+}
+
 var _ db.Database = &PG{}
 
 type PG struct {
-	db *sql.DB
+	db   *sql.DB
+	addr string
+	id   string
 }
 
 func New() db.Database {
+	id := make([]byte, 8)
+	rand.Read(id)
 	db := &PG{
 		db: &sql.DB{},
+		id: hex.EncodeToString(id),
 	}
 	return db
 }
@@ -58,9 +287,11 @@ func (pg *PG) Open(Addr string) error {
 	err = pgx.Ping()
 	if err != nil {
 		logger.Logger.Errorln("Can't connect to db")
+		pgx.Close()
 		return err
 	}
 	pg.db = pgx
+	pg.addr = Addr
 	_, err = pgx.Exec(SchemaUsers)
 	if err != nil {
 		logger.Logger.Errorln(err)
@@ -73,6 +304,36 @@ func (pg *PG) Open(Addr string) error {
 	if err != nil {
 		logger.Logger.Errorln(err)
 	}
+	for _, schema := range []string{SchemaAccrualHistory, SchemaStatusHistory, SchemaAdjustments, SchemaRefunds, SchemaBonuses, SchemaCampaigns, SchemaRewards, SchemaRewardCampaigns, SchemaPromoCodes, SchemaPromoRedemptions, SchemaTransfers, SchemaReferrals, SchemaHolds, SchemaExpirations, SchemaIdempotency} {
+		_, err = pgx.Exec(schema)
+		if err != nil {
+			logger.Logger.Errorln(err)
+		}
+	}
+	for _, m := range Migrations {
+		_, err = pgx.Exec(m)
+		if err != nil {
+			logger.Logger.Errorln(err)
+		}
+	}
+	return nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// addStatusHistory records status and accrual of the order if they differ from the last record.
+func addStatusHistory(ctx context.Context, e execer, number order.OrderNumber, status order.Status, accrual *money.Amount) error {
+	_, err := e.ExecContext(ctx, `INSERT INTO order_status_history("number", "status", "accrual", "changed_at")
+	SELECT $1, $2::VARCHAR, $3::BIGINT, now() WHERE NOT EXISTS (
+		SELECT 1 FROM (
+			SELECT "status", "accrual" FROM order_status_history WHERE "number"=$1 ORDER BY "id" DESC LIMIT 1
+		) AS last WHERE last."status"=$2 AND last."accrual" IS NOT DISTINCT FROM $3
+	)`, number, status, accrual)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
 	return nil
 }
 
@@ -122,8 +383,49 @@ func (pg *PG) GetUser(ctx context.Context, login string) (*user.User, error) {
 	return u, nil
 }
 
+func (pg *PG) GetLogins(ctx context.Context) ([]string, error) {
+	rows, err := pg.db.QueryContext(ctx, "SELECT \"login\" FROM Users ORDER BY \"login\"")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	defer rows.Close()
+	logins := make([]string, 0)
+	for rows.Next() {
+		var login string
+		err = rows.Scan(&login)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+		}
+		logins = append(logins, login)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return logins, nil
+}
+
+func (pg *PG) GetTier(ctx context.Context, login string) (string, error) {
+	var tier string
+	err := pg.db.QueryRowContext(ctx, "SELECT \"tier\" FROM Users WHERE \"login\"=$1", login).Scan(&tier)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", db.ErrUserNotFound
+		}
+		return "", fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return tier, nil
+}
+
+func (pg *PG) SetTier(ctx context.Context, login, tier string) error {
+	_, err := pg.db.ExecContext(ctx, "UPDATE Users SET \"tier\"=$1 WHERE \"login\"=$2", tier, login)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return nil
+}
+
 func (pg *PG) AddOrder(ctx context.Context, o *order.Order) error {
-	stmt, err := pg.db.Prepare("INSERT INTO Orders(\"number\", \"owner\", \"status\", \"accrual\", \"uploaded_at\") values($1,$2,$3,$4,$5)")
+	stmt, err := pg.db.Prepare("INSERT INTO Orders(\"number\", \"owner\", \"status\", \"accrual\", \"uploaded_at\", \"next_check_at\") values($1,$2,$3,$4,$5,COALESCE($6, now()))")
 	if err != nil {
 		return err
 	}
@@ -133,6 +435,7 @@ func (pg *PG) AddOrder(ctx context.Context, o *order.Order) error {
 		o.Status,
 		o.Accrual,
 		o.UploadedAt,
+		o.NextCheckAt,
 	)
 	if err != nil {
 		logger.Logger.Error(err)
@@ -150,165 +453,240 @@ func (pg *PG) AddOrder(ctx context.Context, o *order.Order) error {
 		}
 		return fmt.Errorf("%s: %s", db.ErrSomeWrong, pgErr.Code)
 	}
-	return nil
-}
-
-func (pg *PG) UpdateOrder(ctx context.Context, o *order.Order) error {
-	stmt, err := pg.db.Prepare("UPDATE Orders SET \"status\"=$1, \"accrual\"=$2 WHERE number=$3")
+	err = addStatusHistory(ctx, pg.db, o.Number, o.Status, o.Accrual)
 	if err != nil {
-		return err
+		logger.Logger.Error(err)
 	}
-	_, err = stmt.ExecContext(ctx,
-		o.Status,
-		o.Accrual,
-		o.Number,
-	)
+	_, err = pg.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", ChannelNewOrders, pg.id+":"+string(o.Number))
 	if err != nil {
-		logger.Logger.Error(err)
-		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+		logger.Logger.Errorf("Can't notify about order %s: %s", o.Number, err)
 	}
 	return nil
 }
 
-func (pg *PG) GetWithStatus(ctx context.Context, s order.Status) ([]order.OrderNumber, error) {
-	stmt, err := pg.db.Prepare("SELECT \"number\" FROM Orders WHERE status=$1 ORDER BY uploaded_at")
-	if err != nil {
-		return nil, err
-	}
-
-	rows, err := stmt.QueryContext(ctx, &s)
+// ListenOrders calls handle for orders uploaded through other instances until ctx is done.
+// Orders added through this instance are skipped, they are handled in-process.
+func (pg *PG) ListenOrders(ctx context.Context, handle func(order.OrderNumber)) error {
+	conn, err := pgx.Connect(ctx, pg.addr)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
 	}
-	defer rows.Close()
-
-	columns, err := rows.Columns()
+	defer conn.Close(context.Background())
+	_, err = conn.Exec(ctx, "LISTEN "+ChannelNewOrders)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
 	}
-
-	orders := make([]order.OrderNumber, 0, len(columns))
-
-	for rows.Next() {
-		var o order.Order
-		err = rows.Scan(&o.Number)
+	for {
+		n, err := conn.WaitForNotification(ctx)
 		if err != nil {
-			return nil, err
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
 		}
-		orders = append(orders, o.Number)
+		id, number, ok := strings.Cut(n.Payload, ":")
+		if !ok || id == pg.id {
+			continue
+		}
+		handle(order.OrderNumber(number))
 	}
+}
 
-	err = rows.Err()
+func (pg *PG) AddOrders(ctx context.Context, orders []*order.Order) ([]error, error) {
+	tx, err := pg.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
 	}
-
-	return orders, nil
-}
-
-func (pg *PG) GetOrder(ctx context.Context, number order.OrderNumber) (*order.Order, error) {
-	stmt, err := pg.db.Prepare("SELECT \"number\", \"owner\", \"status\", \"accrual\", \"uploaded_at\" FROM Orders WHERE \"number\"=$1 ORDER BY \"uploaded_at\"")
+	defer tx.Rollback()
+	insert, err := tx.PrepareContext(ctx, "INSERT INTO Orders(\"number\", \"owner\", \"status\", \"accrual\", \"uploaded_at\", \"next_check_at\") values($1,$2,$3,$4,$5,COALESCE($6, now())) ON CONFLICT (\"number\") DO NOTHING")
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
 	}
-	o := &order.Order{}
-	row := stmt.QueryRowContext(ctx, number)
-	err = row.Scan(&o.Number, &o.Owner, &o.Status, &o.Accrual, &o.UploadedAt)
+	owner, err := tx.PrepareContext(ctx, "SELECT \"owner\" FROM Orders WHERE \"number\"=$1")
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, db.ErrOrderNotFound
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	results := make([]error, len(orders))
+	for i, o := range orders {
+		res, err := insert.ExecContext(ctx,
+			o.Number,
+			o.Owner,
+			o.Status,
+			o.Accrual,
+			o.UploadedAt,
+			o.NextCheckAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+		}
+		inserted, err := res.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+		}
+		if inserted == 1 {
+			err = addStatusHistory(ctx, tx, o.Number, o.Status, o.Accrual)
+			if err != nil {
+				return nil, err
+			}
+			continue
 		}
+		var existOwner string
+		err = owner.QueryRowContext(ctx, o.Number).Scan(&existOwner)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+		}
+		results[i] = db.ErrOtherUserOrder
+		if existOwner == o.Owner {
+			results[i] = db.ErrOrderAdded
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
 		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
 	}
-	return o, nil
+	for i, o := range orders {
+		if results[i] != nil {
+			continue
+		}
+		_, err = pg.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", ChannelNewOrders, pg.id+":"+string(o.Number))
+		if err != nil {
+			logger.Logger.Errorf("Can't notify about order %s: %s", o.Number, err)
+		}
+	}
+	return results, nil
 }
 
-func (pg *PG) GetOrders(ctx context.Context, owner string) ([]*order.Order, error) {
-	stmt, err := pg.db.Prepare("SELECT \"number\", \"owner\", \"status\", \"accrual\", \"uploaded_at\" FROM Orders WHERE owner=$1 ORDER BY uploaded_at DESC")
+func (pg *PG) UpdateOrder(ctx context.Context, o *order.Order) error {
+	tx, err := pg.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
 	}
-
-	rows, err := stmt.QueryContext(ctx, owner)
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, "UPDATE Orders SET \"status\"=$1, \"accrual\"=$2, \"attempts\"=$3, \"next_check_at\"=COALESCE($4, \"next_check_at\"), \"reason\"=NULLIF($5, ''), \"processed_at\"=CASE WHEN $1=$7 THEN COALESCE(\"processed_at\", now()) ELSE \"processed_at\" END, \"reward_pending\"=CASE WHEN $1=$7 THEN true ELSE \"reward_pending\" END WHERE number=$6 AND status=ANY($8)")
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
-
-	columns, err := rows.Columns()
+	from := order.AllowedFrom(o.Status)
+	allowed := make([]string, 0, len(from))
+	for _, s := range from {
+		allowed = append(allowed, string(s))
+	}
+	res, err := stmt.ExecContext(ctx,
+		o.Status,
+		o.Accrual,
+		o.Attempts,
+		o.NextCheckAt,
+		o.Reason,
+		o.Number,
+		order.StatusProcessed,
+		allowed,
+	)
 	if err != nil {
 		logger.Logger.Error(err)
-		return nil, err
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
 	}
-
-	orders := make([]*order.Order, 0, len(columns))
-
-	for rows.Next() {
-		o := &order.Order{}
-		err = rows.Scan(&o.Number, &o.Owner, &o.Status, &o.Accrual, &o.UploadedAt)
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	if updated == 0 {
+		existOrder, err := pg.GetOrder(ctx, o.Number)
 		if err != nil {
-			return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+			return err
 		}
-		orders = append(orders, o)
+		err = existOrder.Status.Transit(o.Status)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%s: order %s was changed concurrently", db.ErrSomeWrong, o.Number)
 	}
-
-	err = rows.Err()
+	err = addStatusHistory(ctx, tx, o.Number, o.Status, o.Accrual)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	return orders, nil
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return nil
 }
 
-func (pg *PG) AddWithdrawal(ctx context.Context, wd *order.Withdraw) error {
-	stmt, err := pg.db.Prepare("INSERT INTO Withdrawals(\"order\", \"owner\", \"sum\", \"processed_at\") values($1,$2,$3,$4)")
+func (pg *PG) ScheduleOrder(ctx context.Context, number order.OrderNumber, attempts int, next time.Time) error {
+	stmt, err := pg.db.Prepare("UPDATE Orders SET \"attempts\"=$1, \"next_check_at\"=$2 WHERE number=$3")
 	if err != nil {
 		return err
 	}
 	_, err = stmt.ExecContext(ctx,
-		wd.Order,
-		wd.Owner,
-		wd.Sum,
-		wd.ProcessedAt,
+		attempts,
+		next,
+		number,
 	)
 	if err != nil {
 		logger.Logger.Error(err)
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.SQLState()) {
-			return db.ErrWithdrawAdded
-		}
 		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
 	}
 	return nil
 }
 
-func (pg *PG) GetWithdrawals(ctx context.Context, owner string) ([]*order.Withdraw, error) {
-	stmt, err := pg.db.Prepare("SELECT \"order\", \"owner\", \"sum\", \"processed_at\" FROM Withdrawals WHERE owner=$1 ORDER BY processed_at DESC")
+func (pg *PG) RequeueOrder(ctx context.Context, number order.OrderNumber) error {
+	stmt, err := pg.db.Prepare("UPDATE Orders SET \"status\"=$1, \"attempts\"=0, \"next_check_at\"=now(), \"requeued_at\"=now(), \"reason\"=NULL WHERE number=$2 AND status=$3")
 	if err != nil {
-		return nil, err
+		return err
 	}
+	res, err := stmt.ExecContext(ctx,
+		order.StatusNew,
+		number,
+		order.StatusStale,
+	)
+	if err != nil {
+		logger.Logger.Error(err)
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	if updated == 0 {
+		_, err = pg.GetOrder(ctx, number)
+		if err != nil {
+			return err
+		}
+		return db.ErrOrderNotStale
+	}
+	err = addStatusHistory(ctx, pg.db, number, order.StatusNew, nil)
+	if err != nil {
+		logger.Logger.Error(err)
+	}
+	return nil
+}
 
-	rows, err := stmt.QueryContext(ctx, owner)
+func (pg *PG) GetDueOrders(ctx context.Context, limit int, revision time.Duration) ([]*order.Order, error) {
+	// statuses are literals, so branches match partial indexes of next_check_at
+	stmt, err := pg.db.Prepare(`(SELECT "number", "owner", "status", "accrual", "uploaded_at", "attempts", "next_check_at", "processed_at", "requeued_at" FROM Orders
+		WHERE "next_check_at" <= now() AND "status" IN ('NEW', 'PROCESSING'))
+	UNION ALL
+	(SELECT "number", "owner", "status", "accrual", "uploaded_at", "attempts", "next_check_at", "processed_at", "requeued_at" FROM Orders
+		WHERE "next_check_at" <= now() AND "status"='PROCESSED' AND "processed_at" > now() - make_interval(secs => $1))
+	ORDER BY "next_check_at" LIMIT $2`)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	columns, err := rows.Columns()
+	rows, err := stmt.QueryContext(ctx, revision.Seconds(), limit)
 	if err != nil {
-		logger.Logger.Error(err)
 		return nil, err
 	}
+	defer rows.Close()
 
-	withdrawals := make([]*order.Withdraw, 0, len(columns))
+	orders := make([]*order.Order, 0, limit)
 
 	for rows.Next() {
-		w := &order.Withdraw{}
-		err = rows.Scan(&w.Order, &w.Owner, &w.Sum, &w.ProcessedAt)
+		o := &order.Order{}
+		err = rows.Scan(&o.Number, &o.Owner, &o.Status, &o.Accrual, &o.UploadedAt, &o.Attempts, &o.NextCheckAt, &o.ProcessedAt, &o.RequeuedAt)
 		if err != nil {
 			return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
 		}
-		withdrawals = append(withdrawals, w)
+		orders = append(orders, o)
 	}
 
 	err = rows.Err()
@@ -316,25 +694,888 @@ func (pg *PG) GetWithdrawals(ctx context.Context, owner string) ([]*order.Withdr
 		return nil, err
 	}
 
-	return withdrawals, nil
+	return orders, nil
 }
 
-func (pg *PG) GetAccruals(ctx context.Context, owner string) (int64, error) {
-	stmt, err := pg.db.Prepare("SELECT SUM(\"accrual\") FROM Orders WHERE owner=$1")
+func (pg *PG) GetRewardPending(ctx context.Context, limit int) ([]*order.Order, error) {
+	rows, err := pg.db.QueryContext(ctx, `SELECT "number", "owner", "status", "accrual", "uploaded_at", "attempts", "next_check_at", "processed_at", "requeued_at" FROM Orders
+	WHERE "reward_pending" AND "status"=$1 ORDER BY "processed_at" LIMIT $2`, order.StatusProcessed, limit)
 	if err != nil {
-		return 0, err
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
 	}
-	var accrual sql.NullInt64
+	defer rows.Close()
+	orders := make([]*order.Order, 0, limit)
+	for rows.Next() {
+		o := &order.Order{}
+		err = rows.Scan(&o.Number, &o.Owner, &o.Status, &o.Accrual, &o.UploadedAt, &o.Attempts, &o.NextCheckAt, &o.ProcessedAt, &o.RequeuedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+		}
+		orders = append(orders, o)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return orders, nil
+}
+
+func (pg *PG) SetRewarded(ctx context.Context, number order.OrderNumber, accrual *money.Amount) error {
+	_, err := pg.db.ExecContext(ctx, `UPDATE Orders SET "reward_pending"=false WHERE "number"=$1 AND "accrual" IS NOT DISTINCT FROM $2::BIGINT`, number, accrual)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return nil
+}
+
+func (pg *PG) AddAccrualRecord(ctx context.Context, r *order.AccrualRecord) error {
+	stmt, err := pg.db.Prepare("INSERT INTO order_accrual_history(\"number\", \"status\", \"accrual\", \"code\", \"error\", \"received_at\") values($1,$2,$3,$4,NULLIF($5, ''),$6)")
+	if err != nil {
+		return err
+	}
+	_, err = stmt.ExecContext(ctx,
+		r.Number,
+		r.Status,
+		r.Accrual,
+		r.Code,
+		r.Error,
+		r.ReceivedAt,
+	)
+	if err != nil {
+		logger.Logger.Error(err)
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return nil
+}
+
+func (pg *PG) ReviseAccrual(ctx context.Context, number order.OrderNumber, accrual *money.Amount, asOf, next time.Time) (*order.Adjustment, error) {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	defer tx.Rollback()
+
+	var prev sql.NullInt64
+	adj := &order.Adjustment{
+		Number:    number,
+		CreatedAt: time.Now(),
+	}
+	var latest sql.NullTime
+	row := tx.QueryRowContext(ctx, "SELECT \"owner\", \"accrual\", COALESCE(\"revised_at\", \"processed_at\") FROM Orders WHERE \"number\"=$1 AND \"status\"=$2 FOR UPDATE", number, order.StatusProcessed)
+	err = row.Scan(&adj.Owner, &prev, &latest)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, db.ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	// replayed or delayed accruals don't roll back newer ones
+	if latest.Valid && !asOf.After(latest.Time) {
+		return nil, db.ErrStaleRevision
+	}
+	var current money.Amount
+	if accrual != nil {
+		current = *accrual
+	}
+	adj.Delta, err = current.Sub(money.Amount(prev.Int64))
+	if err != nil {
+		return nil, err
+	}
+
+	// bonuses depend on accrual, so they are credited again for the revised one
+	_, err = tx.ExecContext(ctx, "UPDATE Orders SET \"accrual\"=$1, \"next_check_at\"=$2, \"reward_pending\"=true, \"revised_at\"=$3 WHERE \"number\"=$4", accrual, next, asOf, number)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	err = addStatusHistory(ctx, tx, number, order.StatusProcessed, accrual)
+	if err != nil {
+		return nil, err
+	}
+	if adj.Delta != 0 {
+		_, err = tx.ExecContext(ctx, "INSERT INTO accrual_adjustments(\"number\", \"owner\", \"delta\", \"created_at\") values($1,$2,$3,$4)",
+			adj.Number, adj.Owner, adj.Delta, adj.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return adj, nil
+}
+
+func (pg *PG) GetWithStatus(ctx context.Context, s order.Status) ([]order.OrderNumber, error) {
+	stmt, err := pg.db.Prepare("SELECT \"number\" FROM Orders WHERE status=$1 ORDER BY uploaded_at")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.QueryContext(ctx, &s)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]order.OrderNumber, 0, len(columns))
+
+	for rows.Next() {
+		var o order.Order
+		err = rows.Scan(&o.Number)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, o.Number)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+func (pg *PG) GetOrder(ctx context.Context, number order.OrderNumber) (*order.Order, error) {
+	stmt, err := pg.db.Prepare("SELECT \"number\", \"owner\", \"status\", \"accrual\", \"uploaded_at\", \"attempts\", \"next_check_at\", COALESCE(\"reason\", ''), \"processed_at\", \"requeued_at\" FROM Orders WHERE \"number\"=$1 ORDER BY \"uploaded_at\"")
+	if err != nil {
+		return nil, err
+	}
+	o := &order.Order{}
+	row := stmt.QueryRowContext(ctx, number)
+	err = row.Scan(&o.Number, &o.Owner, &o.Status, &o.Accrual, &o.UploadedAt, &o.Attempts, &o.NextCheckAt, &o.Reason, &o.ProcessedAt, &o.RequeuedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, db.ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return o, nil
+}
+
+func (pg *PG) GetStatusHistory(ctx context.Context, number order.OrderNumber) ([]*order.StatusChange, error) {
+	stmt, err := pg.db.Prepare("SELECT \"status\", \"accrual\", \"changed_at\" FROM order_status_history WHERE \"number\"=$1 ORDER BY \"id\"")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.QueryContext(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := make([]*order.StatusChange, 0)
+
+	for rows.Next() {
+		c := &order.StatusChange{}
+		err = rows.Scan(&c.Status, &c.Accrual, &c.ChangedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+		}
+		history = append(history, c)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+func (pg *PG) GetOrders(ctx context.Context, owner string) ([]*order.Order, error) {
+	stmt, err := pg.db.Prepare("SELECT \"number\", \"owner\", \"status\", \"accrual\", \"uploaded_at\" FROM Orders WHERE owner=$1 ORDER BY uploaded_at DESC")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.QueryContext(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		logger.Logger.Error(err)
+		return nil, err
+	}
+
+	orders := make([]*order.Order, 0, len(columns))
+
+	for rows.Next() {
+		o := &order.Order{}
+		err = rows.Scan(&o.Number, &o.Owner, &o.Status, &o.Accrual, &o.UploadedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+		}
+		orders = append(orders, o)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// lockOwner locks the user row until the end of tx,
+// so spendings of the same user are checked against balance one at a time.
+func lockOwner(ctx context.Context, tx *sql.Tx, owner string) error {
+	var login string
+	err := tx.QueryRowContext(ctx, `SELECT "login" FROM Users WHERE "login"=$1 FOR UPDATE`, owner).Scan(&login)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return db.ErrUserNotFound
+		}
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return nil
+}
+
+// balance returns current balance of the owner, active holds and recorded expirations are subtracted.
+func balance(ctx context.Context, q queryer, owner string, now time.Time) (money.Amount, error) {
+	var current int64
+	row := q.QueryRowContext(ctx, `SELECT
+	COALESCE((SELECT SUM("accrual") FROM Orders WHERE "owner"=$1), 0)
+	+ COALESCE((SELECT SUM("amount") FROM bonuses WHERE "owner"=$1), 0)
+	+ COALESCE((SELECT SUM("amount") FROM promo_redemptions WHERE "owner"=$1), 0)
+	+ COALESCE((SELECT SUM("sum") FROM transfers WHERE "to"=$1), 0)
+	- COALESCE((SELECT SUM("sum") FROM transfers WHERE "from"=$1), 0)
+	- COALESCE((SELECT SUM("sum"-"refunded") FROM Withdrawals WHERE "owner"=$1), 0)
+	- COALESCE((SELECT SUM("sum") FROM holds WHERE "owner"=$1 AND "status"=$2 AND "expires_at">$3), 0)
+	- COALESCE((SELECT SUM("amount") FROM points_expirations WHERE "owner"=$1), 0)`,
+		owner, order.HoldActive, now)
+	err := row.Scan(&current)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return money.Amount(current), nil
+}
+
+// withdrawUsage returns withdrawals without refunds and active holds of the owner
+// in the day and the month of now, the hold for skip order isn't counted.
+func withdrawUsage(ctx context.Context, q queryer, owner string, now time.Time, skip order.OrderNumber) (policy.Usage, error) {
+	day, month := policy.Periods(now)
+	var usage policy.Usage
+	row := q.QueryRowContext(ctx, `WITH spent AS (
+	SELECT "sum"-"refunded" AS "sum", "processed_at" AS "at" FROM Withdrawals WHERE "owner"=$1 AND "processed_at">=$3
+	UNION ALL
+	SELECT "sum", "created_at" FROM holds WHERE "owner"=$1 AND "status"=$4 AND "expires_at">$5 AND "created_at">=$3 AND "order"<>$6)
+	SELECT COALESCE(SUM("sum") FILTER (WHERE "at">=$2), 0), COALESCE(SUM("sum"), 0) FROM spent`,
+		owner, day, month, order.HoldActive, now, skip)
+	err := row.Scan(&usage.Day, &usage.Month)
+	if err != nil {
+		return usage, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return usage, nil
+}
+
+// checkWithdraw locks the owner in tx and checks withdrawal of sum against the policy and balance of the owner.
+func checkWithdraw(ctx context.Context, tx *sql.Tx, owner string, sum money.Amount, total *money.Amount, p policy.Withdraw, now time.Time) error {
+	err := lockOwner(ctx, tx, owner)
+	if err != nil {
+		return err
+	}
+	usage, err := withdrawUsage(ctx, tx, owner, now, "")
+	if err != nil {
+		return err
+	}
+	err = p.Check(sum, total, usage)
+	if err != nil {
+		return err
+	}
+	current, err := balance(ctx, tx, owner, now)
+	if err != nil {
+		return err
+	}
+	if sum > current {
+		return db.ErrNotEnoughBalance
+	}
+	return nil
+}
+
+func (pg *PG) AddWithdrawal(ctx context.Context, wd *order.Withdraw, p policy.Withdraw) error {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if wd.ProcessedAt != nil {
+		now = *wd.ProcessedAt
+	}
+	err = checkWithdraw(ctx, tx, wd.Owner, wd.Sum, wd.OrderTotal, p, now)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO Withdrawals(\"order\", \"owner\", \"sum\", \"processed_at\") values($1,$2,$3,$4)",
+		wd.Order,
+		wd.Owner,
+		wd.Sum,
+		wd.ProcessedAt,
+	)
+	if err != nil {
+		logger.Logger.Error(err)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.SQLState()) {
+			return db.ErrWithdrawAdded
+		}
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return nil
+}
+
+func (pg *PG) GetWithdrawals(ctx context.Context, owner string) ([]*order.Withdraw, error) {
+	stmt, err := pg.db.Prepare("SELECT \"order\", \"owner\", \"sum\", \"status\", \"refunded\", \"processed_at\" FROM Withdrawals WHERE owner=$1 ORDER BY processed_at DESC")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.QueryContext(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		logger.Logger.Error(err)
+		return nil, err
+	}
+
+	withdrawals := make([]*order.Withdraw, 0, len(columns))
+
+	for rows.Next() {
+		w := &order.Withdraw{}
+		err = rows.Scan(&w.Order, &w.Owner, &w.Sum, &w.Status, &w.Refunded, &w.ProcessedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+		}
+		withdrawals = append(withdrawals, w)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return withdrawals, nil
+}
+
+func (pg *PG) GetAccruals(ctx context.Context, owner string) (money.Amount, error) {
+	stmt, err := pg.db.Prepare("SELECT SUM(\"accrual\") FROM Orders WHERE owner=$1")
+	if err != nil {
+		return 0, err
+	}
+	var accrual sql.NullInt64
 	row := stmt.QueryRowContext(ctx, owner)
 	err = row.Scan(&accrual)
 	if err != nil {
 		return 0, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
 	}
-	return accrual.Int64, nil
+	return money.Amount(accrual.Int64), nil
+}
+
+func (pg *PG) GetAccruedSince(ctx context.Context, owner string, since time.Time) (money.Amount, error) {
+	var accrued sql.NullInt64
+	row := pg.db.QueryRowContext(ctx, "SELECT SUM(\"accrual\") FROM Orders WHERE \"owner\"=$1 AND \"status\"=$2 AND COALESCE(\"processed_at\", \"uploaded_at\")>=$3",
+		owner, order.StatusProcessed, since)
+	err := row.Scan(&accrued)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return money.Amount(accrued.Int64), nil
+}
+
+func (pg *PG) CountProcessed(ctx context.Context, owner string, until time.Time) (int, error) {
+	var count int
+	row := pg.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM Orders WHERE \"owner\"=$1 AND \"status\"=$2 AND COALESCE(\"processed_at\", \"uploaded_at\")<=$3", owner, order.StatusProcessed, until)
+	err := row.Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return count, nil
+}
+
+func (pg *PG) AddCampaign(ctx context.Context, c *campaign.Campaign) error {
+	row := pg.db.QueryRowContext(ctx, `INSERT INTO campaigns("name", "starts_at", "ends_at", "first_orders", "min_accrual", "segment", "multiplier", "fixed")
+	values($1,$2,$3,$4,$5,$6,$7,$8) RETURNING "id"`,
+		c.Name,
+		c.StartsAt,
+		c.EndsAt,
+		c.FirstOrders,
+		c.MinAccrual,
+		c.Segment,
+		c.Multiplier,
+		c.Fixed,
+	)
+	err := row.Scan(&c.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return nil
+}
+
+func (pg *PG) GetCampaigns(ctx context.Context) ([]*campaign.Campaign, error) {
+	rows, err := pg.db.QueryContext(ctx, `SELECT "id", "name", "starts_at", "ends_at", "first_orders", "min_accrual", "segment", "multiplier", "fixed" FROM campaigns
+	WHERE "deleted_at" IS NULL ORDER BY "id"`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return scanCampaigns(rows)
+}
+
+func scanCampaigns(rows *sql.Rows) ([]*campaign.Campaign, error) {
+	defer rows.Close()
+	campaigns := make([]*campaign.Campaign, 0)
+	for rows.Next() {
+		c := &campaign.Campaign{}
+		err := rows.Scan(&c.ID, &c.Name, &c.StartsAt, &c.EndsAt, &c.FirstOrders, &c.MinAccrual, &c.Segment, &c.Multiplier, &c.Fixed)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+		}
+		campaigns = append(campaigns, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return campaigns, nil
+}
+
+// DeleteCampaign marks campaign deleted, orders rewarded by it keep it on revisions.
+func (pg *PG) DeleteCampaign(ctx context.Context, id int64) error {
+	res, err := pg.db.ExecContext(ctx, `UPDATE campaigns SET "deleted_at"=now() WHERE "id"=$1 AND "deleted_at" IS NULL`, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	if deleted == 0 {
+		return db.ErrCampaignNotFound
+	}
+	return nil
+}
+
+func (pg *PG) GetReward(ctx context.Context, number order.OrderNumber) (*campaign.Reward, error) {
+	r := &campaign.Reward{Number: number}
+	row := pg.db.QueryRowContext(ctx, `SELECT "tier" FROM rewards WHERE "number"=$1`, number)
+	err := row.Scan(&r.Tier)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, db.ErrRewardNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	rows, err := pg.db.QueryContext(ctx, `SELECT c."id", c."name", c."starts_at", c."ends_at", c."first_orders", c."min_accrual", c."segment", c."multiplier", c."fixed"
+	FROM campaigns c JOIN reward_campaigns r ON r."campaign_id"=c."id"
+	WHERE r."number"=$1 ORDER BY c."id"`, number)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	r.Campaigns, err = scanCampaigns(rows)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (pg *PG) AddPromoCodes(ctx context.Context, codes []*promo.Code) error {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	defer tx.Rollback()
+	insert, err := tx.PrepareContext(ctx, `INSERT INTO promo_codes("code", "amount", "max_uses", "used", "expires_at", "created_at") values($1,$2,$3,0,$4,$5)`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	for _, c := range codes {
+		_, err = insert.ExecContext(ctx, c.Code, c.Amount, c.MaxUses, c.ExpiresAt, c.CreatedAt)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.SQLState()) {
+				return db.ErrPromoAdded
+			}
+			return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return nil
+}
+
+func (pg *PG) RedeemPromo(ctx context.Context, code, owner string, now time.Time) (*promo.Redemption, error) {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	defer tx.Rollback()
+
+	c := &promo.Code{}
+	row := tx.QueryRowContext(ctx, `SELECT "code", "amount", "max_uses", "used", "expires_at", "created_at" FROM promo_codes WHERE "code"=$1 FOR UPDATE`, code)
+	err = row.Scan(&c.Code, &c.Amount, &c.MaxUses, &c.Used, &c.ExpiresAt, &c.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, db.ErrPromoNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	r, err := c.Redeem(owner, now)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO promo_redemptions("code", "owner", "amount", "redeemed_at") values($1,$2,$3,$4)`,
+		r.Code, r.Owner, r.Amount, r.RedeemedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.SQLState()) {
+			return nil, db.ErrPromoRedeemed
+		}
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE promo_codes SET "used"=$1 WHERE "code"=$2`, c.Used, c.Code)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return r, nil
+}
+
+func (pg *PG) GetRedeemed(ctx context.Context, owner string) (money.Amount, error) {
+	var redeemed sql.NullInt64
+	row := pg.db.QueryRowContext(ctx, `SELECT SUM("amount") FROM promo_redemptions WHERE "owner"=$1`, owner)
+	err := row.Scan(&redeemed)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return money.Amount(redeemed.Int64), nil
+}
+
+func (pg *PG) AddTransfer(ctx context.Context, t *user.Transfer, p policy.Withdraw) error {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	defer tx.Rollback()
+
+	err = lockOwner(ctx, tx, t.From)
+	if err != nil {
+		return err
+	}
+	day, _ := policy.Periods(t.CreatedAt)
+	var usage policy.Usage
+	row := tx.QueryRowContext(ctx, `SELECT COALESCE(SUM("sum"), 0) FROM transfers WHERE "from"=$1 AND "created_at">=$2`, t.From, day)
+	err = row.Scan(&usage.Day)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	err = p.Check(t.Sum, nil, usage)
+	if err != nil {
+		return err
+	}
+	current, err := balance(ctx, tx, t.From, t.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if t.Sum > current {
+		return db.ErrNotEnoughBalance
+	}
+	row = tx.QueryRowContext(ctx, `INSERT INTO transfers("from", "to", "sum", "created_at") values($1,$2,$3,$4) RETURNING "id"`,
+		t.From,
+		t.To,
+		t.Sum,
+		t.CreatedAt,
+	)
+	err = row.Scan(&t.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return nil
+}
+
+func (pg *PG) GetTransfers(ctx context.Context, login string) ([]*user.Transfer, error) {
+	rows, err := pg.db.QueryContext(ctx, `SELECT "id", "from", "to", "sum", "created_at" FROM transfers WHERE "from"=$1 OR "to"=$1 ORDER BY "created_at" DESC, "id" DESC`, login)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	defer rows.Close()
+	transfers := make([]*user.Transfer, 0)
+	for rows.Next() {
+		t := &user.Transfer{}
+		err = rows.Scan(&t.ID, &t.From, &t.To, &t.Sum, &t.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+		}
+		transfers = append(transfers, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return transfers, nil
+}
+
+func (pg *PG) GetTransferred(ctx context.Context, login string) (money.Amount, money.Amount, error) {
+	var in, out sql.NullInt64
+	row := pg.db.QueryRowContext(ctx, `SELECT SUM("sum") FILTER (WHERE "to"=$1), SUM("sum") FILTER (WHERE "from"=$1) FROM transfers WHERE "from"=$1 OR "to"=$1`, login)
+	err := row.Scan(&in, &out)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return money.Amount(in.Int64), money.Amount(out.Int64), nil
+}
+
+func (pg *PG) GetTransferredSince(ctx context.Context, login string, since time.Time) (money.Amount, error) {
+	var out sql.NullInt64
+	row := pg.db.QueryRowContext(ctx, `SELECT SUM("sum") FROM transfers WHERE "from"=$1 AND "created_at">=$2`, login, since)
+	err := row.Scan(&out)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return money.Amount(out.Int64), nil
+}
+
+func (pg *PG) SetReferralCode(ctx context.Context, login, code string) (string, error) {
+	var saved string
+	row := pg.db.QueryRowContext(ctx, `UPDATE Users SET "referral_code"=COALESCE("referral_code", $2) WHERE "login"=$1 RETURNING "referral_code"`, login, code)
+	err := row.Scan(&saved)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", db.ErrUserNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.SQLState()) {
+			return "", db.ErrReferralAdded
+		}
+		return "", fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return saved, nil
+}
+
+func (pg *PG) GetReferrer(ctx context.Context, code string) (string, error) {
+	var login string
+	row := pg.db.QueryRowContext(ctx, `SELECT "login" FROM Users WHERE "referral_code"=$1`, code)
+	err := row.Scan(&login)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", db.ErrReferralNotFound
+		}
+		return "", fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return login, nil
+}
+
+func (pg *PG) AddReferral(ctx context.Context, r *referral.Referral, max int) error {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	defer tx.Rollback()
+
+	// lock the referrer to count referrals consistently
+	_, err = tx.ExecContext(ctx, `SELECT "login" FROM Users WHERE "login"=$1 FOR UPDATE`, r.Referrer)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	var count int
+	row := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM referrals WHERE "referrer"=$1`, r.Referrer)
+	err = row.Scan(&count)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	err = referral.Program{Cap: max}.Check(r.Referrer, r.Referee, count)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO referrals("referee", "referrer", "status", "created_at") values($1,$2,$3,$4)`,
+		r.Referee, r.Referrer, r.Status, r.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.SQLState()) {
+			return db.ErrReferralAdded
+		}
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return nil
+}
+
+func (pg *PG) CountReferrals(ctx context.Context, referrer string) (int, error) {
+	var count int
+	row := pg.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM referrals WHERE "referrer"=$1`, referrer)
+	err := row.Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return count, nil
+}
+
+func (pg *PG) GetReferrals(ctx context.Context, referrer string) ([]*referral.Referral, error) {
+	rows, err := pg.db.QueryContext(ctx, `SELECT "referee", "referrer", "status", "order", "created_at", "rewarded_at" FROM referrals WHERE "referrer"=$1 ORDER BY "created_at" DESC`, referrer)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	defer rows.Close()
+	referrals := make([]*referral.Referral, 0)
+	for rows.Next() {
+		r := &referral.Referral{}
+		err = rows.Scan(&r.Referee, &r.Referrer, &r.Status, &r.Order, &r.CreatedAt, &r.RewardedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+		}
+		referrals = append(referrals, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return referrals, nil
+}
+
+func (pg *PG) GetReferral(ctx context.Context, referee string) (*referral.Referral, error) {
+	r := &referral.Referral{}
+	row := pg.db.QueryRowContext(ctx, `SELECT "referee", "referrer", "status", "order", "created_at", "rewarded_at" FROM referrals WHERE "referee"=$1`, referee)
+	err := row.Scan(&r.Referee, &r.Referrer, &r.Status, &r.Order, &r.CreatedAt, &r.RewardedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, db.ErrReferralNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return r, nil
+}
+
+func (pg *PG) RewardReferral(ctx context.Context, r *referral.Referral, bonuses []*order.Bonus) error {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE referrals SET "status"=$1, "order"=$2, "rewarded_at"=$3 WHERE "referee"=$4 AND "status"=$5`,
+		r.Status, r.Order, r.RewardedAt, r.Referee, referral.StatusPending)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	if updated == 0 {
+		// rewarded by other order
+		return nil
+	}
+	for _, b := range bonuses {
+		err = addBonus(ctx, tx, b)
+		if err != nil {
+			return err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return nil
+}
+
+func (pg *PG) SetBonuses(ctx context.Context, r *campaign.Reward, bonuses []*order.Bonus) error {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO rewards("number", "tier") values($1,$2) ON CONFLICT DO NOTHING`, r.Number, r.Tier)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	added, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	if added > 0 {
+		for _, c := range r.Campaigns {
+			_, err = tx.ExecContext(ctx, `INSERT INTO reward_campaigns("number", "campaign_id") values($1,$2) ON CONFLICT DO NOTHING`, r.Number, c.ID)
+			if err != nil {
+				return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+			}
+		}
+	}
+	// referral bonuses are credited once by RewardReferral
+	_, err = tx.ExecContext(ctx, `DELETE FROM bonuses WHERE "number"=$1 AND "source" NOT IN ($2, $3)`,
+		r.Number, referral.SourceReferrer, referral.SourceReferee)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	for _, b := range bonuses {
+		err = addBonus(ctx, tx, b)
+		if err != nil {
+			return err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return nil
+}
+
+func addBonus(ctx context.Context, e execer, b *order.Bonus) error {
+	_, err := e.ExecContext(ctx, "INSERT INTO bonuses(\"number\", \"owner\", \"source\", \"amount\", \"created_at\") values($1,$2,$3,$4,$5) ON CONFLICT DO NOTHING",
+		b.Number,
+		b.Owner,
+		b.Source,
+		b.Amount,
+		b.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return nil
+}
+
+func (pg *PG) GetBonuses(ctx context.Context, owner string) (money.Amount, error) {
+	var bonuses sql.NullInt64
+	row := pg.db.QueryRowContext(ctx, "SELECT SUM(\"amount\") FROM bonuses WHERE \"owner\"=$1", owner)
+	err := row.Scan(&bonuses)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return money.Amount(bonuses.Int64), nil
 }
 
-func (pg *PG) GetWithdrawn(ctx context.Context, owner string) (int64, error) {
-	stmt, err := pg.db.Prepare("SELECT SUM(\"sum\") as sum FROM Withdrawals WHERE owner=$1")
+func (pg *PG) GetWithdrawn(ctx context.Context, owner string) (money.Amount, error) {
+	stmt, err := pg.db.Prepare("SELECT SUM(\"sum\"-\"refunded\") as sum FROM Withdrawals WHERE owner=$1")
 	if err != nil {
 		return 0, err
 	}
@@ -344,5 +1585,435 @@ func (pg *PG) GetWithdrawn(ctx context.Context, owner string) (int64, error) {
 	if err != nil {
 		return 0, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
 	}
-	return withdrawn.Int64, nil
+	return money.Amount(withdrawn.Int64), nil
+}
+
+func (pg *PG) GetWithdrawnSince(ctx context.Context, owner string, since time.Time) (money.Amount, error) {
+	var withdrawn sql.NullInt64
+	row := pg.db.QueryRowContext(ctx, "SELECT SUM(\"sum\"-\"refunded\") FROM Withdrawals WHERE \"owner\"=$1 AND \"processed_at\">=$2", owner, since)
+	err := row.Scan(&withdrawn)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return money.Amount(withdrawn.Int64), nil
+}
+
+func (pg *PG) RefundWithdrawal(ctx context.Context, number order.OrderNumber, sum *money.Amount, cancel bool) (*order.Withdraw, error) {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	defer tx.Rollback()
+
+	w := &order.Withdraw{}
+	row := tx.QueryRowContext(ctx, "SELECT \"order\", \"owner\", \"sum\", \"status\", \"refunded\", \"processed_at\" FROM Withdrawals WHERE \"order\"=$1 FOR UPDATE", number)
+	err = row.Scan(&w.Order, &w.Owner, &w.Sum, &w.Status, &w.Refunded, &w.ProcessedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, db.ErrWithdrawNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	refund, err := w.Refund(sum, cancel)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE Withdrawals SET \"status\"=$1, \"refunded\"=$2 WHERE \"order\"=$3", w.Status, w.Refunded, number)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO withdrawal_refunds(\"order\", \"owner\", \"sum\", \"status\", \"created_at\") values($1,$2,$3,$4,$5)",
+		w.Order, w.Owner, refund, w.Status, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return w, nil
+}
+
+func (pg *PG) AddHold(ctx context.Context, h *order.Hold, p policy.Withdraw) error {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	defer tx.Rollback()
+
+	err = checkWithdraw(ctx, tx, h.Owner, h.Sum, h.OrderTotal, p, h.CreatedAt)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO holds(\"order\", \"owner\", \"sum\", \"status\", \"created_at\", \"expires_at\") values($1,$2,$3,$4,$5,$6)",
+		h.Order,
+		h.Owner,
+		h.Sum,
+		h.Status,
+		h.CreatedAt,
+		h.ExpiresAt,
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.SQLState()) {
+			return db.ErrHoldAdded
+		}
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return nil
+}
+
+func (pg *PG) GetHeld(ctx context.Context, owner string, now time.Time) (money.Amount, error) {
+	var held sql.NullInt64
+	row := pg.db.QueryRowContext(ctx, "SELECT SUM(\"sum\") FROM holds WHERE \"owner\"=$1 AND \"status\"=$2 AND \"expires_at\">$3",
+		owner, order.HoldActive, now)
+	err := row.Scan(&held)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return money.Amount(held.Int64), nil
+}
+
+// closeHold locks hold of the owner and saves its status changed by closed in the same transaction.
+func (pg *PG) closeHold(ctx context.Context, owner string, number order.OrderNumber, closed func(tx *sql.Tx, h *order.Hold) error) (*order.Hold, error) {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	defer tx.Rollback()
+
+	h := &order.Hold{}
+	row := tx.QueryRowContext(ctx, "SELECT \"order\", \"owner\", \"sum\", \"status\", \"created_at\", \"expires_at\" FROM holds WHERE \"order\"=$1 AND \"owner\"=$2 FOR UPDATE", number, owner)
+	err = row.Scan(&h.Order, &h.Owner, &h.Sum, &h.Status, &h.CreatedAt, &h.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, db.ErrHoldNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	err = closed(tx, h)
+	if errors.Is(err, order.ErrHoldExpired) {
+		// expired hold is saved as EXPIRED even if it can't be closed
+		if _, err := tx.ExecContext(ctx, "UPDATE holds SET \"status\"=$1 WHERE \"order\"=$2", h.Status, number); err != nil {
+			return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+		}
+		return nil, order.ErrHoldExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE holds SET \"status\"=$1 WHERE \"order\"=$2", h.Status, number)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return h, nil
+}
+
+func (pg *PG) CaptureHold(ctx context.Context, owner string, number order.OrderNumber, now time.Time, p policy.Withdraw) (*order.Withdraw, error) {
+	var w *order.Withdraw
+	_, err := pg.closeHold(ctx, owner, number, func(tx *sql.Tx, h *order.Hold) error {
+		var err error
+		w, err = h.Capture(now)
+		if err != nil {
+			return err
+		}
+		err = lockOwner(ctx, tx, owner)
+		if err != nil {
+			return err
+		}
+		usage, err := withdrawUsage(ctx, tx, owner, now, number)
+		if err != nil {
+			return err
+		}
+		// order total isn't saved with the hold, its share was checked by AddHold
+		p.MaxShare = 0
+		err = p.Check(w.Sum, nil, usage)
+		if err != nil {
+			return err
+		}
+		// the hold is still subtracted, so negative balance means held points were expired
+		current, err := balance(ctx, tx, owner, now)
+		if err != nil {
+			return err
+		}
+		if current < 0 {
+			return db.ErrNotEnoughBalance
+		}
+		_, err = tx.ExecContext(ctx, "INSERT INTO Withdrawals(\"order\", \"owner\", \"sum\", \"status\", \"processed_at\") values($1,$2,$3,$4,$5)",
+			w.Order, w.Owner, w.Sum, w.Status, w.ProcessedAt)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.SQLState()) {
+				return db.ErrWithdrawAdded
+			}
+			return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (pg *PG) VoidHold(ctx context.Context, owner string, number order.OrderNumber, now time.Time) (*order.Hold, error) {
+	return pg.closeHold(ctx, owner, number, func(tx *sql.Tx, h *order.Hold) error {
+		return h.Close(order.HoldVoided, now)
+	})
+}
+
+func (pg *PG) GetLots(ctx context.Context, owner string) ([]expiry.Lot, error) {
+	return lots(ctx, pg.db, owner)
+}
+
+func lots(ctx context.Context, q queryer, owner string) ([]expiry.Lot, error) {
+	rows, err := q.QueryContext(ctx, `SELECT "number", "amount", "processed_at" FROM (
+		SELECT o."number", COALESCE(o."accrual", 0) + COALESCE((SELECT SUM(b."amount") FROM bonuses b WHERE b."number"=o."number" AND b."owner"=o."owner"), 0) AS "amount",
+			COALESCE(o."processed_at", o."uploaded_at") AS "processed_at"
+		FROM Orders o WHERE o."owner"=$1 AND o."status"=$2
+		UNION ALL
+		SELECT b."number", SUM(b."amount"), MIN(b."created_at")
+		FROM bonuses b JOIN Orders o ON o."number"=b."number" WHERE b."owner"=$1 AND o."owner"<>$1 AND o."status"=$2 GROUP BY b."number"
+	) lots WHERE "amount">0 ORDER BY "processed_at", "number"`,
+		owner, order.StatusProcessed)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	defer rows.Close()
+	result := make([]expiry.Lot, 0)
+	for rows.Next() {
+		var l expiry.Lot
+		err = rows.Scan(&l.Number, &l.Amount, &l.ProcessedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+		}
+		result = append(result, l)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return result, nil
+}
+
+func (pg *PG) GetDebits(ctx context.Context, owner string, now time.Time) ([]expiry.Debit, error) {
+	return debits(ctx, pg.db, owner, now)
+}
+
+// debits returns withdrawals, outgoing transfers and active holds of the owner,
+// held points are reserved, so they aren't expired.
+func debits(ctx context.Context, q queryer, owner string, now time.Time) ([]expiry.Debit, error) {
+	rows, err := q.QueryContext(ctx, `SELECT "sum"-"refunded", "processed_at" FROM Withdrawals WHERE "owner"=$1
+	UNION ALL
+	SELECT "sum", "created_at" FROM transfers WHERE "from"=$1
+	UNION ALL
+	SELECT "sum", "created_at" FROM holds WHERE "owner"=$1 AND "status"=$2 AND "expires_at">$3
+	ORDER BY 2`, owner, order.HoldActive, now)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	defer rows.Close()
+	result := make([]expiry.Debit, 0)
+	for rows.Next() {
+		var d expiry.Debit
+		err = rows.Scan(&d.Amount, &d.At)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+		}
+		result = append(result, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return result, nil
+}
+
+func (pg *PG) GetExpired(ctx context.Context, owner string) (money.Amount, error) {
+	var expired sql.NullInt64
+	row := pg.db.QueryRowContext(ctx, `SELECT SUM("amount") FROM points_expirations WHERE "owner"=$1`, owner)
+	err := row.Scan(&expired)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return money.Amount(expired.Int64), nil
+}
+
+func (pg *PG) GetExpirations(ctx context.Context, owner string) ([]*expiry.Expiration, error) {
+	return expirations(ctx, pg.db, owner)
+}
+
+func expirations(ctx context.Context, q queryer, owner string) ([]*expiry.Expiration, error) {
+	rows, err := q.QueryContext(ctx, "SELECT \"number\", \"owner\", \"amount\", \"expired_at\" FROM points_expirations WHERE \"owner\"=$1 ORDER BY \"expired_at\"", owner)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	defer rows.Close()
+	result := make([]*expiry.Expiration, 0)
+	for rows.Next() {
+		e := &expiry.Expiration{}
+		err = rows.Scan(&e.Number, &e.Owner, &e.Amount, &e.ExpiredAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+		}
+		result = append(result, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return result, nil
+}
+
+func (pg *PG) ExpirePoints(ctx context.Context, owner string, p expiry.Policy, now time.Time) ([]*expiry.Expiration, error) {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	defer tx.Rollback()
+
+	// spendings of the owner wait until the plan is saved, so points aren't both spent and expired
+	err = lockOwner(ctx, tx, owner)
+	if err != nil {
+		return nil, err
+	}
+	l, err := lots(ctx, tx, owner)
+	if err != nil {
+		return nil, err
+	}
+	d, err := debits(ctx, tx, owner, now)
+	if err != nil {
+		return nil, err
+	}
+	recorded, err := expirations(ctx, tx, owner)
+	if err != nil {
+		return nil, err
+	}
+	plan := p.Plan(owner, l, d, recorded, now)
+	for _, e := range plan.Expired {
+		_, err = tx.ExecContext(ctx, "INSERT INTO points_expirations(\"number\", \"owner\", \"amount\", \"expired_at\") values($1,$2,$3,$4) ON CONFLICT DO NOTHING",
+			e.Number, e.Owner, e.Amount, e.ExpiredAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return plan.Expired, nil
+}
+
+func (pg *PG) GetExpiringOwners(ctx context.Context, processedBefore time.Time) ([]string, error) {
+	rows, err := pg.db.QueryContext(ctx, `SELECT o."owner" FROM Orders o
+	WHERE o."status"=$1 AND o."accrual">0 AND COALESCE(o."processed_at", o."uploaded_at")<$2
+	AND NOT EXISTS (SELECT 1 FROM points_expirations e WHERE e."number"=o."number" AND e."owner"=o."owner")
+	UNION
+	SELECT b."owner" FROM bonuses b JOIN Orders o ON o."number"=b."number"
+	WHERE o."status"=$1 AND b."created_at"<$2
+	AND NOT EXISTS (SELECT 1 FROM points_expirations e WHERE e."number"=b."number" AND e."owner"=b."owner")`,
+		order.StatusProcessed, processedBefore)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	defer rows.Close()
+	owners := make([]string, 0)
+	for rows.Next() {
+		var owner string
+		err = rows.Scan(&owner)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+		}
+		owners = append(owners, owner)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return owners, nil
+}
+
+func (pg *PG) BeginIdempotent(ctx context.Context, r *idempotency.Record, ttl time.Duration) (*idempotency.Record, error) {
+	_, err := pg.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE \"owner\"=$1 AND \"created_at\"<$2", r.Owner, time.Now().Add(-ttl))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	now := time.Now()
+	res, err := pg.db.ExecContext(ctx, "INSERT INTO idempotency_keys(\"owner\", \"key\", \"hash\", \"created_at\", \"locked_until\") values($1,$2,$3,$4,$5) ON CONFLICT DO NOTHING",
+		r.Owner,
+		r.Key,
+		r.Hash,
+		now,
+		r.LockedUntil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	if inserted == 1 {
+		return nil, nil
+	}
+	// the first request was lost without saving response, retry takes the key
+	res, err = pg.db.ExecContext(ctx, "UPDATE idempotency_keys SET \"created_at\"=$4, \"locked_until\"=$5 WHERE \"owner\"=$1 AND \"key\"=$2 AND \"hash\"=$3 AND \"status\"=0 AND \"locked_until\"<$4",
+		r.Owner,
+		r.Key,
+		r.Hash,
+		now,
+		r.LockedUntil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	reclaimed, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	if reclaimed == 1 {
+		return nil, nil
+	}
+	saved := &idempotency.Record{
+		Owner: r.Owner,
+		Key:   r.Key,
+	}
+	row := pg.db.QueryRowContext(ctx, "SELECT \"hash\", \"status\", \"content_type\", \"body\", \"created_at\", \"locked_until\" FROM idempotency_keys WHERE \"owner\"=$1 AND \"key\"=$2", r.Owner, r.Key)
+	err = row.Scan(&saved.Hash, &saved.Status, &saved.ContentType, &saved.Body, &saved.CreatedAt, &saved.LockedUntil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	if saved.Hash != r.Hash {
+		return nil, db.ErrKeyReused
+	}
+	return saved, nil
+}
+
+func (pg *PG) SaveIdempotent(ctx context.Context, r *idempotency.Record) error {
+	_, err := pg.db.ExecContext(ctx, "UPDATE idempotency_keys SET \"status\"=$3, \"content_type\"=$4, \"body\"=$5 WHERE \"owner\"=$1 AND \"key\"=$2",
+		r.Owner,
+		r.Key,
+		r.Status,
+		r.ContentType,
+		r.Body,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return nil
+}
+
+func (pg *PG) DeleteIdempotent(ctx context.Context, owner, key string) error {
+	_, err := pg.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE \"owner\"=$1 AND \"key\"=$2", owner, key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.ErrSomeWrong, err)
+	}
+	return nil
 }
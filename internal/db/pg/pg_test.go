@@ -1 +1,115 @@
 package pg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/gophmart/internal/db"
+	"github.com/Nexadis/gophmart/internal/money"
+	"github.com/Nexadis/gophmart/internal/order"
+	"github.com/Nexadis/gophmart/internal/policy"
+	"github.com/Nexadis/gophmart/internal/referral"
+	"github.com/Nexadis/gophmart/internal/user"
+)
+
+// openTestDB connects to DATABASE_URI, tests are skipped without it.
+func openTestDB(t *testing.T) *PG {
+	uri := os.Getenv("DATABASE_URI")
+	if uri == "" {
+		t.Skip("DATABASE_URI isn't set")
+	}
+	pg := New().(*PG)
+	require.NoError(t, pg.Open(uri))
+	t.Cleanup(func() { pg.Close() })
+	return pg
+}
+
+func TestConcurrentSpendings(t *testing.T) {
+	pg := openTestDB(t)
+	ctx := context.Background()
+	suffix := pg.id
+	sender := &user.User{Login: "sender-" + suffix, Password: "password"}
+	recipient := &user.User{Login: "recipient-" + suffix, Password: "password"}
+	require.NoError(t, pg.AddUser(ctx, sender))
+	require.NoError(t, pg.AddUser(ctx, recipient))
+	_, err := pg.db.ExecContext(ctx, `INSERT INTO Orders("number", "owner", "status", "accrual", "uploaded_at", "processed_at") values($1,$2,$3,$4,now(),now())`,
+		"accrual-"+suffix, sender.Login, order.StatusProcessed, money.Amount(1000))
+	require.NoError(t, err)
+
+	const spendings = 20
+	var wg sync.WaitGroup
+	errs := make([]error, spendings)
+	for i := 0; i < spendings; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			now := time.Now()
+			if i%2 == 0 {
+				errs[i] = pg.AddWithdrawal(ctx, &order.Withdraw{
+					Order:       order.OrderNumber(fmt.Sprintf("withdraw-%s-%d", suffix, i)),
+					Owner:       sender.Login,
+					Sum:         100,
+					ProcessedAt: &now,
+				}, policy.Withdraw{})
+				return
+			}
+			errs[i] = pg.AddTransfer(ctx, &user.Transfer{
+				From:      sender.Login,
+				To:        recipient.Login,
+				Sum:       100,
+				CreatedAt: now,
+			}, policy.Withdraw{})
+		}(i)
+	}
+	wg.Wait()
+
+	spent := 0
+	for _, err := range errs {
+		if err == nil {
+			spent++
+			continue
+		}
+		assert.ErrorIs(t, err, db.ErrNotEnoughBalance)
+	}
+	assert.Equal(t, 10, spent)
+	current, err := balance(ctx, pg.db, sender.Login, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, money.Amount(0), current)
+}
+
+func TestReferralBonusLots(t *testing.T) {
+	pg := openTestDB(t)
+	ctx := context.Background()
+	suffix := pg.id
+	referrer, referee := "referrer-"+suffix, "referee-"+suffix
+	number := order.OrderNumber("referral-" + suffix)
+	processedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	_, err := pg.db.ExecContext(ctx, `INSERT INTO Orders("number", "owner", "status", "accrual", "uploaded_at", "processed_at") values($1,$2,$3,$4,$5,$5)`,
+		number, referee, order.StatusProcessed, money.Amount(1000), processedAt)
+	require.NoError(t, err)
+	for _, b := range []*order.Bonus{
+		{Number: number, Owner: referrer, Source: referral.SourceReferrer, Amount: 500, CreatedAt: processedAt},
+		{Number: number, Owner: referee, Source: referral.SourceReferee, Amount: 300, CreatedAt: processedAt},
+	} {
+		require.NoError(t, addBonus(ctx, pg.db, b))
+	}
+
+	lots, err := pg.GetLots(ctx, referee)
+	require.NoError(t, err)
+	if assert.Len(t, lots, 1) {
+		assert.Equal(t, money.Amount(1300), lots[0].Amount, "bonus of referrer isn't in lot of referee")
+	}
+	lots, err = pg.GetLots(ctx, referrer)
+	require.NoError(t, err)
+	if assert.Len(t, lots, 1) {
+		assert.Equal(t, number, lots[0].Number)
+		assert.Equal(t, money.Amount(500), lots[0].Amount)
+	}
+}
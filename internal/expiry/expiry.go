@@ -0,0 +1,157 @@
+// Package expiry plans expiration of accrued points.
+// Withdrawals consume the oldest points first, points left unspent expire
+// after the policy period since the order was PROCESSED.
+package expiry
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Nexadis/gophmart/internal/money"
+	"github.com/Nexadis/gophmart/internal/order"
+)
+
+type Policy struct {
+	// Months after PROCESSED date when points expire, 0 disables expiration.
+	Months int
+	// Soon is a period before expiration when points are reported as expiring soon.
+	Soon time.Duration
+}
+
+func (p Policy) Enabled() bool {
+	return p.Months > 0
+}
+
+func (p Policy) ExpiresAt(processedAt time.Time) time.Time {
+	return processedAt.AddDate(0, p.Months, 0)
+}
+
+// Lot is points accrued for the order.
+type Lot struct {
+	Number      order.OrderNumber
+	Amount      money.Amount
+	ProcessedAt time.Time
+}
+
+// Debit is points spent by the owner.
+type Debit struct {
+	Amount money.Amount
+	At     time.Time
+}
+
+// Expiration is an entry about points of the order expired unspent.
+type Expiration struct {
+	Number    order.OrderNumber
+	Owner     string
+	Amount    money.Amount
+	ExpiredAt time.Time
+}
+
+// Expiring is amount of points which expire at the time.
+type Expiring struct {
+	Amount    money.Amount `json:"amount"`
+	ExpiresAt time.Time    `json:"expires_at"`
+}
+
+type Plan struct {
+	// Expired are expirations due by now and not recorded yet,
+	// lots spent before expiration have zero amount.
+	Expired []*Expiration
+	// Remaining are unspent and unexpired points.
+	Remaining []Lot
+}
+
+// Pending is sum of due expirations.
+func (p *Plan) Pending() money.Amount {
+	var sum money.Amount
+	for _, e := range p.Expired {
+		sum += e.Amount
+	}
+	return sum
+}
+
+// ExpiringSoon groups remaining points expiring in Soon period after now.
+func (p *Plan) ExpiringSoon(policy Policy, now time.Time) []Expiring {
+	var soon []Expiring
+	for _, l := range p.Remaining {
+		at := policy.ExpiresAt(l.ProcessedAt)
+		if at.After(now.Add(policy.Soon)) {
+			break
+		}
+		if len(soon) > 0 && soon[len(soon)-1].ExpiresAt.Equal(at) {
+			soon[len(soon)-1].Amount += l.Amount
+			continue
+		}
+		soon = append(soon, Expiring{Amount: l.Amount, ExpiresAt: at})
+	}
+	return soon
+}
+
+// Plan replays lots, debits and recorded expirations of one owner up to now.
+// Recorded expirations are taken as is, other lots expire with the unspent rest.
+func (p Policy) Plan(owner string, lots []Lot, debits []Debit, recorded []*Expiration, now time.Time) *Plan {
+	lots = append([]Lot(nil), lots...)
+	sort.SliceStable(lots, func(i, j int) bool {
+		return lots[i].ProcessedAt.Before(lots[j].ProcessedAt)
+	})
+	debits = append([]Debit(nil), debits...)
+	sort.SliceStable(debits, func(i, j int) bool {
+		return debits[i].At.Before(debits[j].At)
+	})
+	done := make(map[order.OrderNumber]bool, len(recorded))
+	for _, e := range recorded {
+		done[e.Number] = true
+	}
+
+	plan := &Plan{}
+	// queue holds unexpired lots accrued before current event, head is the oldest,
+	// spent lots stay in queue with zero amount until they expire
+	queue := make([]Lot, 0, len(lots))
+	next := 0
+	expire := func(until time.Time) {
+		for len(queue) > 0 {
+			head := queue[0]
+			at := p.ExpiresAt(head.ProcessedAt)
+			if at.After(until) {
+				return
+			}
+			queue = queue[1:]
+			if done[head.Number] {
+				continue
+			}
+			plan.Expired = append(plan.Expired, &Expiration{
+				Number:    head.Number,
+				Owner:     owner,
+				Amount:    head.Amount,
+				ExpiredAt: at,
+			})
+		}
+	}
+	accrue := func(until time.Time) {
+		for next < len(lots) && !lots[next].ProcessedAt.After(until) {
+			expire(lots[next].ProcessedAt)
+			queue = append(queue, lots[next])
+			next++
+		}
+		expire(until)
+	}
+	for _, d := range debits {
+		accrue(d.At)
+		rest := d.Amount
+		for i := 0; rest > 0 && i < len(queue); i++ {
+			spent := queue[i].Amount
+			if spent > rest {
+				spent = rest
+			}
+			queue[i].Amount -= spent
+			rest -= spent
+		}
+	}
+	accrue(now)
+	for _, l := range queue {
+		if l.Amount > 0 {
+			plan.Remaining = append(plan.Remaining, l)
+		}
+	}
+	return plan
+}
@@ -0,0 +1,94 @@
+package expiry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nexadis/gophmart/internal/money"
+	"github.com/Nexadis/gophmart/internal/order"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+var policy = Policy{Months: 12, Soon: 30 * 24 * time.Hour}
+
+var lots = []Lot{
+	{Number: "12345678903", Amount: 10000, ProcessedAt: date(2022, time.January, 1)},
+	{Number: "79927398713", Amount: 5000, ProcessedAt: date(2022, time.June, 1)},
+}
+
+var testsPlan = []struct {
+	name      string
+	debits    []Debit
+	recorded  []*Expiration
+	now       time.Time
+	expired   []*Expiration
+	remaining money.Amount
+	soon      []Expiring
+}{
+	{
+		name:      "Nothing expired",
+		now:       date(2022, time.December, 15),
+		remaining: 15000,
+		soon:      []Expiring{{Amount: 10000, ExpiresAt: date(2023, time.January, 1)}},
+	},
+	{
+		name:   "Unspent rest expires",
+		debits: []Debit{{Amount: 3000, At: date(2022, time.February, 1)}},
+		now:    date(2023, time.January, 2),
+		expired: []*Expiration{
+			{Number: "12345678903", Owner: "user", Amount: 7000, ExpiredAt: date(2023, time.January, 1)},
+		},
+		remaining: 5000,
+	},
+	{
+		name:   "Withdrawal spends the oldest points first",
+		debits: []Debit{{Amount: 12000, At: date(2022, time.July, 1)}},
+		now:    date(2023, time.May, 15),
+		expired: []*Expiration{
+			{Number: "12345678903", Owner: "user", Amount: 0, ExpiredAt: date(2023, time.January, 1)},
+		},
+		remaining: 3000,
+		soon:      []Expiring{{Amount: 3000, ExpiresAt: date(2023, time.June, 1)}},
+	},
+	{
+		name:      "Withdrawal can't spend expired points",
+		debits:    []Debit{{Amount: 4000, At: date(2023, time.February, 1)}},
+		recorded:  []*Expiration{{Number: "12345678903", Owner: "user", Amount: 10000}},
+		now:       date(2023, time.February, 2),
+		remaining: 1000,
+	},
+	{
+		name:     "All expired",
+		recorded: []*Expiration{{Number: "12345678903", Owner: "user", Amount: 10000}},
+		now:      date(2024, time.January, 1),
+		expired: []*Expiration{
+			{Number: "79927398713", Owner: "user", Amount: 5000, ExpiredAt: date(2023, time.June, 1)},
+		},
+	},
+}
+
+func TestPlan(t *testing.T) {
+	for _, test := range testsPlan {
+		t.Run(test.name, func(t *testing.T) {
+			plan := policy.Plan("user", lots, test.debits, test.recorded, test.now)
+			assert.Equal(t, test.expired, plan.Expired)
+			var remaining money.Amount
+			for _, l := range plan.Remaining {
+				remaining += l.Amount
+			}
+			assert.Equal(t, test.remaining, remaining)
+			assert.Equal(t, test.soon, plan.ExpiringSoon(policy, test.now))
+		})
+	}
+}
+
+func TestPlanDoesNotChangeLots(t *testing.T) {
+	policy.Plan("user", lots, []Debit{{Amount: 12000, At: date(2022, time.July, 1)}}, nil, date(2022, time.August, 1))
+	assert.Equal(t, money.Amount(10000), lots[0].Amount)
+	assert.Equal(t, order.OrderNumber("12345678903"), lots[0].Number)
+}
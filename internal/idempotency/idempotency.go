@@ -0,0 +1,41 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+const MaxKeyLen = 255
+
+// Record is the response saved for Idempotency-Key of the user.
+// Status is 0 while the first request is in progress, the key may be
+// reclaimed by retry after LockedUntil if the response wasn't saved.
+type Record struct {
+	Owner       string
+	Key         string
+	Hash        string
+	Status      int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+	LockedUntil time.Time
+}
+
+// InProgress reports whether the response of the first request isn't saved yet.
+func (r *Record) InProgress() bool {
+	return r.Status == 0
+}
+
+// Hash identifies request by method, path, content type and body.
+func Hash(method, path, contentType string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write([]byte(contentType))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
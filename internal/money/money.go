@@ -0,0 +1,136 @@
+package money
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Amount is money in hundredths (kopecks), JSON form is decimal number with up to two fractional digits.
+type Amount int64
+
+const (
+	fractionDigits = 2
+	scale          = 100
+)
+
+var (
+	ErrInvalid   = errors.New(`invalid amount`)
+	ErrPrecision = errors.New(`amount has more than two fractional digits`)
+	ErrOverflow  = errors.New(`amount overflow`)
+)
+
+// Parse parses decimal string like "-123.45" exactly.
+func Parse(s string) (Amount, error) {
+	if s == "" {
+		return 0, ErrInvalid
+	}
+	negative := s[0] == '-'
+	if negative {
+		s = s[1:]
+	}
+	var value uint64
+	digits, fraction := 0, -1
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '.' && fraction < 0 && digits > 0 {
+			fraction = 0
+			continue
+		}
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("%w: %q", ErrInvalid, s)
+		}
+		if fraction >= 0 {
+			if fraction == fractionDigits {
+				return 0, fmt.Errorf("%w: %q", ErrPrecision, s)
+			}
+			fraction++
+		}
+		digits++
+		if value > (math.MaxInt64-uint64(c-'0'))/10 {
+			return 0, fmt.Errorf("%w: %q", ErrOverflow, s)
+		}
+		value = value*10 + uint64(c-'0')
+	}
+	if digits == 0 || fraction == 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+	if fraction < 0 {
+		fraction = 0
+	}
+	for ; fraction < fractionDigits; fraction++ {
+		if value > math.MaxInt64/10 {
+			return 0, fmt.Errorf("%w: %q", ErrOverflow, s)
+		}
+		value *= 10
+	}
+	if negative {
+		return -Amount(value), nil
+	}
+	return Amount(value), nil
+}
+
+func (a Amount) String() string {
+	v := uint64(a)
+	sign := ""
+	if a < 0 {
+		sign = "-"
+		v = -v
+	}
+	units := strconv.FormatUint(v/scale, 10)
+	cents := v % scale
+	switch {
+	case cents == 0:
+		return sign + units
+	case cents%10 == 0:
+		return fmt.Sprintf("%s%s.%d", sign, units, cents/10)
+	}
+	return fmt.Sprintf("%s%s.%02d", sign, units, cents)
+}
+
+func (a Amount) Add(b Amount) (Amount, error) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, ErrOverflow
+	}
+	return sum, nil
+}
+
+func (a Amount) Sub(b Amount) (Amount, error) {
+	diff := a - b
+	if (b > 0 && diff > a) || (b < 0 && diff < a) {
+		return 0, ErrOverflow
+	}
+	return diff, nil
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte(`null`)) {
+		return nil
+	}
+	amount, err := Parse(string(data))
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+func (a Amount) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Amount) UnmarshalText(data []byte) error {
+	amount, err := Parse(string(data))
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
@@ -0,0 +1,83 @@
+package money
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var parseTests = []struct {
+	name   string
+	value  string
+	amount Amount
+	err    error
+}{
+	{"Integer", "500", 50000, nil},
+	{"Two digits", "123.12", 12312, nil},
+	{"Float trap", "0.29", 29, nil},
+	{"One digit", "729.9", 72990, nil},
+	{"Negative", "-0.01", -1, nil},
+	{"Too precise", "0.291", 0, ErrPrecision},
+	{"Overflow", "92233720368547758.08", 0, ErrOverflow},
+	{"Max", "92233720368547758.07", math.MaxInt64, nil},
+	{"Exponent", "1e2", 0, ErrInvalid},
+	{"Empty fraction", "1.", 0, ErrInvalid},
+	{"No integer part", ".5", 0, ErrInvalid},
+	{"String", `"1"`, 0, ErrInvalid},
+}
+
+func TestParse(t *testing.T) {
+	for _, test := range parseTests {
+		t.Run(test.name, func(t *testing.T) {
+			var a Amount
+			err := a.UnmarshalJSON([]byte(test.value))
+			if test.err != nil {
+				assert.ErrorIs(t, err, test.err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.amount, a)
+			}
+		})
+	}
+}
+
+var (
+	value       = Amount(12312)
+	res, _      = json.Marshal(123.12)
+	checkAmount = []struct {
+		name string
+		a    *Amount
+		res  []byte
+	}{
+		{"Normal value", &value, res},
+		{"Nil value", nil, []byte(`null`)},
+		{"Integer value", &[]Amount{50000}[0], []byte(`500`)},
+		{"One digit value", &[]Amount{-50}[0], []byte(`-0.5`)},
+		{"Cents value", &[]Amount{7}[0], []byte(`0.07`)},
+	}
+)
+
+func TestMarshal(t *testing.T) {
+	for _, test := range checkAmount {
+		t.Run(test.name, func(t *testing.T) {
+			jsoned, err := json.Marshal(test.a)
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.res, jsoned)
+			}
+		})
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	_, err := Amount(math.MaxInt64).Add(1)
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = Amount(math.MinInt64).Sub(1)
+	assert.ErrorIs(t, err, ErrOverflow)
+	diff, err := Amount(100).Sub(29)
+	if assert.NoError(t, err) {
+		assert.Equal(t, Amount(71), diff)
+	}
+}
@@ -0,0 +1,33 @@
+package order
+
+import (
+	"time"
+
+	"github.com/Nexadis/gophmart/internal/money"
+)
+
+// AccrualRecord is a response of accrual system about the order.
+// Failed requests are recorded with Error and status Code of response if it was received.
+type AccrualRecord struct {
+	Number     OrderNumber   `json:"order"`
+	Status     string        `json:"status"`
+	Accrual    *money.Amount `json:"accrual,omitempty"`
+	Code       int           `json:"code,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	ReceivedAt time.Time     `json:"received_at"`
+}
+
+// Adjustment is a change of accrual of PROCESSED order after revision by accrual system.
+type Adjustment struct {
+	Number    OrderNumber  `json:"order"`
+	Owner     string       `json:"-"`
+	Delta     money.Amount `json:"delta"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// StatusChange is a record of order status timeline.
+type StatusChange struct {
+	Status    Status        `json:"status"`
+	Accrual   *money.Amount `json:"accrual,omitempty"`
+	ChangedAt time.Time     `json:"changed_at"`
+}
@@ -0,0 +1,17 @@
+package order
+
+import (
+	"time"
+
+	"github.com/Nexadis/gophmart/internal/money"
+)
+
+// Bonus is points credited for the order on top of its accrual.
+// Source tells what gave the bonus, there is one bonus of each source for the order.
+type Bonus struct {
+	Number    OrderNumber  `json:"order"`
+	Owner     string       `json:"-"`
+	Source    string       `json:"source"`
+	Amount    money.Amount `json:"amount"`
+	CreatedAt time.Time    `json:"created_at"`
+}
@@ -0,0 +1,70 @@
+package order
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Nexadis/gophmart/internal/money"
+)
+
+type HoldStatus string
+
+const (
+	HoldActive   HoldStatus = "ACTIVE"
+	HoldCaptured HoldStatus = "CAPTURED"
+	HoldVoided   HoldStatus = "VOIDED"
+	HoldExpired  HoldStatus = "EXPIRED"
+)
+
+var (
+	ErrHoldClosed  = errors.New(`hold is captured or voided`)
+	ErrHoldExpired = errors.New(`hold is expired`)
+)
+
+// Hold reserves points for the order until it is captured, voided or expired.
+type Hold struct {
+	Owner     string       `json:"-"`
+	Order     OrderNumber  `json:"order"`
+	Sum       money.Amount `json:"sum"`
+	Status    HoldStatus   `json:"status"`
+	CreatedAt time.Time    `json:"created_at"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	// OrderTotal is total of the order paid with points, it is used only to check hold.
+	OrderTotal *money.Amount `json:"order_total,omitempty"`
+}
+
+// StatusAt returns status of the hold at the time, active hold is expired after ExpiresAt.
+func (h *Hold) StatusAt(now time.Time) HoldStatus {
+	if h.Status == HoldActive && !now.Before(h.ExpiresAt) {
+		return HoldExpired
+	}
+	return h.Status
+}
+
+// Close moves active hold to the status.
+func (h *Hold) Close(to HoldStatus, now time.Time) error {
+	switch h.StatusAt(now) {
+	case HoldActive:
+		h.Status = to
+		return nil
+	case HoldExpired:
+		h.Status = HoldExpired
+		return ErrHoldExpired
+	}
+	return ErrHoldClosed
+}
+
+// Capture closes the hold and returns withdrawal of its sum.
+func (h *Hold) Capture(now time.Time) (*Withdraw, error) {
+	err := h.Close(HoldCaptured, now)
+	if err != nil {
+		return nil, err
+	}
+	return &Withdraw{
+		Owner:       h.Owner,
+		Order:       h.Order,
+		Sum:         h.Sum,
+		Status:      WithdrawCompleted,
+		ProcessedAt: &now,
+	}, nil
+}
@@ -0,0 +1,38 @@
+package order
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHoldCapture(t *testing.T) {
+	now := time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		hold   Hold
+		status HoldStatus
+		err    error
+	}{
+		{"Capture active", Hold{Status: HoldActive, ExpiresAt: now.Add(time.Minute)}, HoldCaptured, nil},
+		{"Capture expired", Hold{Status: HoldActive, ExpiresAt: now}, HoldExpired, ErrHoldExpired},
+		{"Capture voided", Hold{Status: HoldVoided, ExpiresAt: now.Add(time.Minute)}, HoldVoided, ErrHoldClosed},
+		{"Capture captured", Hold{Status: HoldCaptured, ExpiresAt: now.Add(time.Minute)}, HoldCaptured, ErrHoldClosed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := test.hold
+			h.Order = "12345678903"
+			h.Sum = 500
+			w, err := h.Capture(now)
+			assert.ErrorIs(t, err, test.err)
+			assert.Equal(t, test.status, h.Status)
+			if test.err == nil {
+				assert.Equal(t, h.Order, w.Order)
+				assert.Equal(t, h.Sum, w.Sum)
+				assert.Equal(t, WithdrawCompleted, w.Status)
+			}
+		})
+	}
+}
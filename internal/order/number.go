@@ -0,0 +1,106 @@
+package order
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ZerosPolicy string
+
+const (
+	// ZerosKeep keeps leading zeros, "018" and "18" are different orders.
+	ZerosKeep ZerosPolicy = "keep"
+	// ZerosStrip removes leading zeros, "018" is stored as "18".
+	ZerosStrip ZerosPolicy = "strip"
+	// ZerosReject rejects numbers with leading zeros as malformed.
+	ZerosReject ZerosPolicy = "reject"
+)
+
+const defaultMinLen = 2
+
+// MalformedError means that order number isn't a string of digits of allowed length.
+type MalformedError struct {
+	Number string
+	Reason string
+}
+
+func (e *MalformedError) Error() string {
+	return fmt.Sprintf("%s %q: %s", ErrInvalidNum, e.Number, e.Reason)
+}
+
+func (e *MalformedError) Unwrap() error {
+	return ErrInvalidNum
+}
+
+// ChecksumError means that order number fails Luhn check.
+type ChecksumError struct {
+	Number string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s %q: checksum failed", ErrInvalidNum, e.Number)
+}
+
+func (e *ChecksumError) Unwrap() error {
+	return ErrInvalidNum
+}
+
+// NumberParser checks order numbers and returns them in canonical form.
+// Zero MinLen is 2, zero MaxLen is unlimited, empty Zeros is ZerosKeep.
+type NumberParser struct {
+	MinLen int
+	MaxLen int
+	Zeros  ZerosPolicy
+}
+
+// Parse trims surrounding whitespace, accepts only digits and checks Luhn sum.
+func (p NumberParser) Parse(s string) (OrderNumber, error) {
+	number := strings.TrimSpace(s)
+	for i := 0; i < len(number); i++ {
+		if number[i] < '0' || number[i] > '9' {
+			return "", &MalformedError{Number: s, Reason: "only digits are allowed"}
+		}
+	}
+	if len(number) > 1 && number[0] == '0' {
+		switch p.Zeros {
+		case ZerosReject:
+			return "", &MalformedError{Number: s, Reason: "leading zeros aren't allowed"}
+		case ZerosStrip:
+			number = strings.TrimLeft(number, "0")
+			if number == "" {
+				number = "0"
+			}
+		}
+	}
+	minLen := p.MinLen
+	if minLen < 1 {
+		minLen = defaultMinLen
+	}
+	if len(number) < minLen {
+		return "", &MalformedError{Number: s, Reason: fmt.Sprintf("shorter than %d digits", minLen)}
+	}
+	if p.MaxLen > 0 && len(number) > p.MaxLen {
+		return "", &MalformedError{Number: s, Reason: fmt.Sprintf("longer than %d digits", p.MaxLen)}
+	}
+	if !luhn(number) {
+		return "", &ChecksumError{Number: s}
+	}
+	return OrderNumber(number), nil
+}
+
+func luhn(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		digit := int(digits[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
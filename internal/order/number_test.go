@@ -0,0 +1,47 @@
+package order
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var parseTests = []struct {
+	name      string
+	parser    NumberParser
+	number    string
+	canonical OrderNumber
+	malformed bool
+	checksum  bool
+}{
+	{"Valid", NumberParser{}, "25461716", "25461716", false, false},
+	{"Surrounding spaces", NumberParser{}, "  18\n", "18", false, false},
+	{"Inner space", NumberParser{}, "2546 1716", "", true, false},
+	{"Letter passing Luhn as zero", NumberParser{}, "0a", "", true, false},
+	{"Checksum", NumberParser{}, "12345678902", "", false, true},
+	{"Too short", NumberParser{}, "0", "", true, false},
+	{"Too long", NumberParser{MaxLen: 4}, "25461716", "", true, false},
+	{"Keep zeros", NumberParser{}, "018", "018", false, false},
+	{"Strip zeros", NumberParser{Zeros: ZerosStrip}, "018", "18", false, false},
+	{"Reject zeros", NumberParser{Zeros: ZerosReject}, "018", "", true, false},
+}
+
+func TestParseNumber(t *testing.T) {
+	for _, test := range parseTests {
+		t.Run(test.name, func(t *testing.T) {
+			n, err := test.parser.Parse(test.number)
+			var malformed *MalformedError
+			var checksum *ChecksumError
+			assert.Equal(t, test.malformed, errors.As(err, &malformed))
+			assert.Equal(t, test.checksum, errors.As(err, &checksum))
+			if test.malformed || test.checksum {
+				assert.ErrorIs(t, err, ErrInvalidNum)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.canonical, n)
+			}
+		})
+	}
+}
@@ -1,23 +1,20 @@
 package order
 
 import (
-	"encoding/json"
 	"errors"
-	"math"
-	"strconv"
-	"strings"
 	"time"
+
+	"github.com/Nexadis/gophmart/internal/money"
 )
 
 type Status string
 
-type Points int64
-
 const (
 	StatusNew        Status = "NEW"
 	StatusProcessing Status = "PROCESSING"
 	StatusInvalid    Status = "INVALID"
 	StatusProcessed  Status = "PROCESSED"
+	StatusStale      Status = "STALE"
 )
 
 var Statuses []Status
@@ -28,6 +25,7 @@ func init() {
 		StatusProcessed,
 		StatusInvalid,
 		StatusProcessing,
+		StatusStale,
 	}
 }
 
@@ -39,73 +37,37 @@ var (
 type OrderNumber string
 
 type Order struct {
-	Owner      string      `json:"-"`
-	Number     OrderNumber `json:"number"`
-	Status     Status      `json:"status"`
-	Accrual    *Points     `json:"accrual,omitempty"`
-	UploadedAt *time.Time  `json:"uploaded_at"`
+	Owner       string        `json:"-"`
+	Number      OrderNumber   `json:"number"`
+	Status      Status        `json:"status"`
+	Accrual     *money.Amount `json:"accrual,omitempty"`
+	UploadedAt  *time.Time    `json:"uploaded_at"`
+	Attempts    int           `json:"-"`
+	NextCheckAt *time.Time    `json:"-"`
+	Reason      string        `json:"-"`
+	ProcessedAt *time.Time    `json:"-"`
+	RequeuedAt  *time.Time    `json:"-"`
 }
 
-func New(number, owner string) (*Order, error) {
+// New returns NEW order, number should be parsed by NumberParser.
+func New(number OrderNumber, owner string) *Order {
 	upload := time.Now()
 	order := &Order{
-		Owner:      owner,
-		Number:     OrderNumber(number),
-		Status:     StatusNew,
-		Accrual:    nil,
-		UploadedAt: &upload,
-	}
-	if order.IsValid() {
-		return order, nil
+		Owner:       owner,
+		Number:      number,
+		Status:      StatusNew,
+		Accrual:     nil,
+		UploadedAt:  &upload,
+		NextCheckAt: &upload,
 	}
-	return nil, ErrInvalidNum
-}
-
-func (o Order) IsValid() bool {
-	return o.Number.IsValid()
+	return order
 }
 
-func (o OrderNumber) IsValid() bool {
-	digits := strings.Split(strings.ReplaceAll(string(o), " ", ""), "")
-	lengthOfString := len(digits)
-
-	if lengthOfString < 2 {
-		return false
+// WaitingSince returns time since the order waits for final status,
+// requeued order waits since the requeue.
+func (o Order) WaitingSince() *time.Time {
+	if o.RequeuedAt != nil {
+		return o.RequeuedAt
 	}
-
-	sum := 0
-	flag := false
-
-	for i := lengthOfString - 1; i > -1; i-- {
-		digit, _ := strconv.Atoi(digits[i])
-
-		if flag {
-			digit *= 2
-
-			if digit > 9 {
-				digit -= 9
-			}
-		}
-
-		sum += digit
-		flag = !flag
-	}
-
-	return math.Mod(float64(sum), 10) == 0
-}
-
-func (p Points) MarshalJSON() ([]byte, error) {
-	points := float64(p) / 100
-	return json.Marshal(points)
-}
-
-func (p *Points) UnmarshalJSON(data []byte) error {
-	var points float64
-	err := json.Unmarshal(data, &points)
-	if err != nil {
-		return err
-	}
-	*p = Points(points * 100)
-
-	return nil
+	return o.UploadedAt
 }
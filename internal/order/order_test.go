@@ -1,7 +1,6 @@
 package order
 
 import (
-	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -35,41 +34,8 @@ var tests = []struct {
 func TestOrderNumber(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			orNum := OrderNumber(test.number)
-			valid := orNum.IsValid()
-			assert.Equal(t, test.want.isValid, valid)
-		})
-	}
-}
-
-var (
-	value       = Points(12312)
-	res, _      = json.Marshal(123.12)
-	checkPoints = []struct {
-		name string
-		p    *Points
-		res  []byte
-	}{
-		{
-			"Normal value",
-			&value,
-			res,
-		},
-		{
-			"Nil value",
-			nil,
-			[]byte(`null`),
-		},
-	}
-)
-
-func TestPoints(t *testing.T) {
-	for _, test := range checkPoints {
-		t.Run(test.name, func(t *testing.T) {
-			jsoned, err := json.Marshal(test.p)
-			if assert.NoError(t, err) {
-				assert.Equal(t, test.res, jsoned)
-			}
+			_, err := NumberParser{}.Parse(test.number)
+			assert.Equal(t, test.want.isValid, err == nil)
 		})
 	}
 }
@@ -0,0 +1,50 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrTransition = errors.New(`status transition isn't allowed`)
+
+// transitions lists statuses the order may move to from the status.
+// PROCESSED and INVALID are final, PROCESSED accrual may only be revised.
+var transitions = map[Status][]Status{
+	StatusNew:        {StatusNew, StatusProcessing, StatusProcessed, StatusInvalid, StatusStale},
+	StatusProcessing: {StatusProcessing, StatusProcessed, StatusInvalid, StatusStale},
+	StatusStale:      {StatusNew, StatusProcessing, StatusProcessed, StatusInvalid},
+	StatusProcessed:  {StatusProcessed},
+	StatusInvalid:    {StatusInvalid},
+}
+
+func (s Status) IsFinal() bool {
+	return s == StatusProcessed || s == StatusInvalid
+}
+
+func (s Status) CanTransit(to Status) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transit returns ErrTransition if the order can't move from s to status to.
+func (s Status) Transit(to Status) error {
+	if !s.CanTransit(to) {
+		return fmt.Errorf("%w: %s -> %s", ErrTransition, s, to)
+	}
+	return nil
+}
+
+// AllowedFrom returns statuses the order may have to move to the status.
+func AllowedFrom(to Status) []Status {
+	var from []Status
+	for _, s := range Statuses {
+		if s.CanTransit(to) {
+			from = append(from, s)
+		}
+	}
+	return from
+}
@@ -0,0 +1,37 @@
+package order
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var transitTests = []struct {
+	name    string
+	from    Status
+	to      Status
+	allowed bool
+}{
+	{"New to processing", StatusNew, StatusProcessing, true},
+	{"Processing to processed", StatusProcessing, StatusProcessed, true},
+	{"Processing back to new", StatusProcessing, StatusNew, false},
+	{"Processed back to new", StatusProcessed, StatusNew, false},
+	{"Processed revision", StatusProcessed, StatusProcessed, true},
+	{"Invalid to processed", StatusInvalid, StatusProcessed, false},
+	{"Stale requeue", StatusStale, StatusNew, true},
+}
+
+func TestTransit(t *testing.T) {
+	for _, test := range transitTests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.from.Transit(test.to)
+			if test.allowed {
+				assert.NoError(t, err)
+				assert.Contains(t, AllowedFrom(test.to), test.from)
+				return
+			}
+			assert.ErrorIs(t, err, ErrTransition)
+			assert.NotContains(t, AllowedFrom(test.to), test.from)
+		})
+	}
+}
@@ -1,47 +1,69 @@
 package order
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
+
+	"github.com/Nexadis/gophmart/internal/money"
+)
+
+type WithdrawStatus string
+
+const (
+	WithdrawCompleted         WithdrawStatus = "COMPLETED"
+	WithdrawCancelled         WithdrawStatus = "CANCELLED"
+	WithdrawRefunded          WithdrawStatus = "REFUNDED"
+	WithdrawPartiallyRefunded WithdrawStatus = "PARTIALLY_REFUNDED"
+)
+
+var (
+	ErrWithdrawReversed = errors.New(`withdrawal is cancelled or refunded`)
+	ErrRefundSum        = errors.New(`invalid refund sum`)
 )
 
 type Withdraw struct {
-	Owner       string      `json:"-"`
-	Order       OrderNumber `json:"order"`
-	Sum         int64       `json:"sum"`
-	ProcessedAt *time.Time  `json:"processed_at"`
+	Owner       string         `json:"-"`
+	Order       OrderNumber    `json:"order"`
+	Sum         money.Amount   `json:"sum"`
+	Status      WithdrawStatus `json:"status"`
+	Refunded    money.Amount   `json:"refunded"`
+	ProcessedAt *time.Time     `json:"processed_at"`
+	// OrderTotal is total of the order paid with points, it is used only to check withdrawal.
+	OrderTotal *money.Amount `json:"order_total,omitempty"`
 }
 
-type jsonWithdraw struct {
-	Owner       string      `json:"-"`
-	Order       OrderNumber `json:"order"`
-	Sum         float64     `json:"sum"`
-	ProcessedAt *time.Time  `json:"processed_at"`
+// Spent is sum of withdrawal left after refunds.
+func (w *Withdraw) Spent() money.Amount {
+	return w.Sum - w.Refunded
 }
 
-func (w Withdraw) MarshalJSON() ([]byte, error) {
-	j := &jsonWithdraw{
-		Owner:       w.Owner,
-		Order:       w.Order,
-		Sum:         float64(w.Sum) / 100,
-		ProcessedAt: w.ProcessedAt,
-	}
-	return json.Marshal(j)
+func (s WithdrawStatus) IsFinal() bool {
+	return s == WithdrawCancelled || s == WithdrawRefunded
 }
 
-func (w *Withdraw) UnmarshalJSON(data []byte) error {
-	j := &jsonWithdraw{}
-	err := json.Unmarshal(data, j)
-	if err != nil {
-		return err
+// Refund returns sum of points to the owner, nil sum refunds the rest of withdrawal.
+// Cancel refunds the whole rest and marks withdrawal CANCELLED.
+func (w *Withdraw) Refund(sum *money.Amount, cancel bool) (money.Amount, error) {
+	if w.Status.IsFinal() {
+		return 0, ErrWithdrawReversed
+	}
+	rest := w.Spent()
+	refund := rest
+	if sum != nil {
+		refund = *sum
+	}
+	if refund <= 0 || refund > rest || (cancel && refund != rest) {
+		return 0, fmt.Errorf("%w: %s of %s", ErrRefundSum, refund, rest)
 	}
-	w.Owner = j.Owner
-	w.Order = j.Order
-	w.Sum = int64(j.Sum * 100)
-	if j.ProcessedAt == nil {
-		t := time.Now()
-		j.ProcessedAt = &t
+	w.Refunded += refund
+	switch {
+	case cancel:
+		w.Status = WithdrawCancelled
+	case w.Refunded == w.Sum:
+		w.Status = WithdrawRefunded
+	default:
+		w.Status = WithdrawPartiallyRefunded
 	}
-	w.ProcessedAt = j.ProcessedAt
-	return nil
+	return refund, nil
 }
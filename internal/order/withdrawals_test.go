@@ -0,0 +1,45 @@
+package order
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nexadis/gophmart/internal/money"
+)
+
+func amount(a money.Amount) *money.Amount {
+	return &a
+}
+
+var refundTests = []struct {
+	name     string
+	w        Withdraw
+	sum      *money.Amount
+	cancel   bool
+	status   WithdrawStatus
+	refunded money.Amount
+	err      error
+}{
+	{"Refund all", Withdraw{Sum: 1000, Status: WithdrawCompleted}, nil, false, WithdrawRefunded, 1000, nil},
+	{"Refund part", Withdraw{Sum: 1000, Status: WithdrawCompleted}, amount(300), false, WithdrawPartiallyRefunded, 300, nil},
+	{"Refund rest", Withdraw{Sum: 1000, Refunded: 300, Status: WithdrawPartiallyRefunded}, amount(700), false, WithdrawRefunded, 1000, nil},
+	{"Cancel", Withdraw{Sum: 1000, Status: WithdrawCompleted}, nil, true, WithdrawCancelled, 1000, nil},
+	{"Cancel partially refunded", Withdraw{Sum: 1000, Refunded: 300, Status: WithdrawPartiallyRefunded}, nil, true, WithdrawCancelled, 1000, nil},
+	{"Refund more than rest", Withdraw{Sum: 1000, Refunded: 300, Status: WithdrawPartiallyRefunded}, amount(800), false, WithdrawPartiallyRefunded, 300, ErrRefundSum},
+	{"Refund zero", Withdraw{Sum: 1000, Status: WithdrawCompleted}, amount(0), false, WithdrawCompleted, 0, ErrRefundSum},
+	{"Cancel with part", Withdraw{Sum: 1000, Status: WithdrawCompleted}, amount(300), true, WithdrawCompleted, 0, ErrRefundSum},
+	{"Refund cancelled", Withdraw{Sum: 1000, Refunded: 1000, Status: WithdrawCancelled}, nil, false, WithdrawCancelled, 1000, ErrWithdrawReversed},
+}
+
+func TestRefund(t *testing.T) {
+	for _, test := range refundTests {
+		t.Run(test.name, func(t *testing.T) {
+			w := test.w
+			_, err := w.Refund(test.sum, test.cancel)
+			assert.ErrorIs(t, err, test.err)
+			assert.Equal(t, test.status, w.Status)
+			assert.Equal(t, test.refunded, w.Refunded)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// Package policy validates withdrawals of points against configured limits.
+package policy
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/Nexadis/gophmart/internal/money"
+)
+
+// Codes of violated rules.
+const (
+	CodeNotPositive   = "sum_not_positive"
+	CodeBelowMin      = "sum_below_min"
+	CodeAboveMax      = "sum_above_max"
+	CodeDailyCap      = "daily_cap_exceeded"
+	CodeMonthlyCap    = "monthly_cap_exceeded"
+	CodeTotalRequired = "order_total_required"
+	CodeOrderShare    = "order_share_exceeded"
+)
+
+// Violation is an error of the rule violated by withdrawal.
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("%s: %s", v.Code, v.Message)
+}
+
+func violation(code, format string, args ...any) *Violation {
+	return &Violation{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Withdraw is limits of withdrawals, zero value of any limit disables it.
+type Withdraw struct {
+	Min        money.Amount
+	Max        money.Amount
+	DailyCap   money.Amount
+	MonthlyCap money.Amount
+	// MaxShare is max percent of order total paid with points.
+	MaxShare int
+}
+
+// Usage is points already spent by the user.
+type Usage struct {
+	Day   money.Amount
+	Month money.Amount
+}
+
+// Periods returns starts of the day and the month of now, usage is counted since them.
+func Periods(now time.Time) (day, month time.Time) {
+	day = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	month = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	return day, month
+}
+
+// Check returns Violation of the first rule the withdrawal breaks.
+// Total is order total, it is required only if MaxShare is set.
+func (p Withdraw) Check(sum money.Amount, total *money.Amount, usage Usage) error {
+	if sum <= 0 {
+		return violation(CodeNotPositive, "sum %s should be positive", sum)
+	}
+	if p.Min > 0 && sum < p.Min {
+		return violation(CodeBelowMin, "sum %s is less than %s", sum, p.Min)
+	}
+	if p.Max > 0 && sum > p.Max {
+		return violation(CodeAboveMax, "sum %s is more than %s", sum, p.Max)
+	}
+	if p.DailyCap > 0 && exceeds(usage.Day, sum, p.DailyCap) {
+		return violation(CodeDailyCap, "%s of %s daily cap is spent", usage.Day, p.DailyCap)
+	}
+	if p.MonthlyCap > 0 && exceeds(usage.Month, sum, p.MonthlyCap) {
+		return violation(CodeMonthlyCap, "%s of %s monthly cap is spent", usage.Month, p.MonthlyCap)
+	}
+	if p.MaxShare > 0 {
+		if total == nil || *total <= 0 {
+			return violation(CodeTotalRequired, "order total is required")
+		}
+		// sum*100 > total*share without int64 overflow
+		paid := new(big.Int).Mul(big.NewInt(int64(sum)), big.NewInt(100))
+		allowed := new(big.Int).Mul(big.NewInt(int64(*total)), big.NewInt(int64(p.MaxShare)))
+		if paid.Cmp(allowed) > 0 {
+			return violation(CodeOrderShare, "points may pay only %d%% of order total %s", p.MaxShare, *total)
+		}
+	}
+	return nil
+}
+
+func exceeds(spent, sum, limit money.Amount) bool {
+	total, err := spent.Add(sum)
+	return err != nil || total > limit
+}
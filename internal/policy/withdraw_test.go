@@ -0,0 +1,71 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nexadis/gophmart/internal/money"
+)
+
+func total(a money.Amount) *money.Amount {
+	return &a
+}
+
+var withdrawPolicy = Withdraw{
+	Min:        100,
+	Max:        10000,
+	DailyCap:   20000,
+	MonthlyCap: 50000,
+	MaxShare:   50,
+}
+
+var checkTests = []struct {
+	name  string
+	sum   money.Amount
+	total *money.Amount
+	usage Usage
+	code  string
+}{
+	{"Allowed", 5000, total(10000), Usage{Day: 15000, Month: 45000}, ""},
+	{"Zero", 0, total(10000), Usage{}, CodeNotPositive},
+	{"Negative", -500, total(10000), Usage{}, CodeNotPositive},
+	{"Below min", 99, total(10000), Usage{}, CodeBelowMin},
+	{"Above max", 10001, total(30000), Usage{}, CodeAboveMax},
+	{"Daily cap", 5000, total(10000), Usage{Day: 15001, Month: 15001}, CodeDailyCap},
+	{"Monthly cap", 5000, total(10000), Usage{Day: 0, Month: 45001}, CodeMonthlyCap},
+	{"Without total", 5000, nil, Usage{}, CodeTotalRequired},
+	{"Order share", 5001, total(10000), Usage{}, CodeOrderShare},
+}
+
+func TestCheck(t *testing.T) {
+	for _, test := range checkTests {
+		t.Run(test.name, func(t *testing.T) {
+			err := withdrawPolicy.Check(test.sum, test.total, test.usage)
+			if test.code == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var v *Violation
+			if assert.ErrorAs(t, err, &v) {
+				assert.Equal(t, test.code, v.Code)
+			}
+		})
+	}
+}
+
+func TestCheckWithoutLimits(t *testing.T) {
+	assert.NoError(t, Withdraw{}.Check(1, nil, Usage{Day: 1 << 40, Month: 1 << 40}))
+	var v *Violation
+	if assert.ErrorAs(t, Withdraw{}.Check(0, nil, Usage{}), &v) {
+		assert.Equal(t, CodeNotPositive, v.Code)
+	}
+}
+
+func TestPeriods(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 13, 45, 0, 0, time.UTC)
+	day, month := Periods(now)
+	assert.Equal(t, time.Date(2024, time.March, 15, 0, 0, 0, 0, time.UTC), day)
+	assert.Equal(t, time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), month)
+}
@@ -0,0 +1,106 @@
+// Package promo describes promo codes redeemable for points.
+package promo
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Nexadis/gophmart/internal/money"
+	"github.com/Nexadis/gophmart/internal/shortcode"
+)
+
+const (
+	CodeLen   = 12
+	MaxPrefix = 32
+	MaxBatch  = 10000
+)
+
+var (
+	ErrExpired   = errors.New(`promo code is expired`)
+	ErrExhausted = errors.New(`promo code usage limit is reached`)
+	ErrInvalid   = errors.New(`invalid promo codes`)
+)
+
+// Code is promo code credited with Amount points, MaxUses 0 is unlimited.
+type Code struct {
+	Code      string       `json:"code"`
+	Amount    money.Amount `json:"amount"`
+	MaxUses   int          `json:"max_uses"`
+	Used      int          `json:"used"`
+	ExpiresAt *time.Time   `json:"expires_at,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// Redemption is points credited to the user for the code.
+type Redemption struct {
+	Code       string       `json:"code"`
+	Owner      string       `json:"-"`
+	Amount     money.Amount `json:"amount"`
+	RedeemedAt time.Time    `json:"redeemed_at"`
+}
+
+// Batch is a request to generate Count unique codes.
+type Batch struct {
+	Count     int          `json:"count"`
+	Prefix    string       `json:"prefix"`
+	Amount    money.Amount `json:"amount"`
+	MaxUses   int          `json:"max_uses"`
+	ExpiresAt *time.Time   `json:"expires_at,omitempty"`
+}
+
+// Redeem checks the code may be used at the time and counts the use.
+func (c *Code) Redeem(owner string, now time.Time) (*Redemption, error) {
+	if c.ExpiresAt != nil && !now.Before(*c.ExpiresAt) {
+		return nil, ErrExpired
+	}
+	if c.MaxUses > 0 && c.Used >= c.MaxUses {
+		return nil, ErrExhausted
+	}
+	c.Used++
+	return &Redemption{
+		Code:       c.Code,
+		Owner:      owner,
+		Amount:     c.Amount,
+		RedeemedAt: now,
+	}, nil
+}
+
+func (b *Batch) Validate() error {
+	switch {
+	case b.Count < 1 || b.Count > MaxBatch:
+		return fmt.Errorf("%w: count should be 1-%d", ErrInvalid, MaxBatch)
+	case b.Amount <= 0:
+		return fmt.Errorf("%w: amount should be positive", ErrInvalid)
+	case b.MaxUses < 0:
+		return fmt.Errorf("%w: max_uses can't be negative", ErrInvalid)
+	case len(b.Prefix) > MaxPrefix || shortcode.Normalize(b.Prefix) != b.Prefix:
+		return fmt.Errorf("%w: prefix should be upper case up to %d characters", ErrInvalid, MaxPrefix)
+	}
+	return nil
+}
+
+// Generate returns codes of the batch, codes are unique within the batch.
+func (b *Batch) Generate(now time.Time) ([]*Code, error) {
+	seen := make(map[string]struct{}, b.Count)
+	codes := make([]*Code, 0, b.Count)
+	for len(codes) < b.Count {
+		random, err := shortcode.Random(CodeLen)
+		if err != nil {
+			return nil, err
+		}
+		code := b.Prefix + random
+		if _, ok := seen[code]; ok {
+			continue
+		}
+		seen[code] = struct{}{}
+		codes = append(codes, &Code{
+			Code:      code,
+			Amount:    b.Amount,
+			MaxUses:   b.MaxUses,
+			ExpiresAt: b.ExpiresAt,
+			CreatedAt: now,
+		})
+	}
+	return codes, nil
+}
@@ -0,0 +1,68 @@
+package promo
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nexadis/gophmart/internal/shortcode"
+)
+
+func TestRedeem(t *testing.T) {
+	now := time.Date(2023, time.June, 1, 0, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+	tests := []struct {
+		name string
+		code Code
+		err  error
+	}{
+		{"Unlimited", Code{Amount: 100}, nil},
+		{"Last use", Code{Amount: 100, MaxUses: 2, Used: 1, ExpiresAt: &later}, nil},
+		{"Exhausted", Code{Amount: 100, MaxUses: 2, Used: 2}, ErrExhausted},
+		{"Expired", Code{Amount: 100, ExpiresAt: &now}, ErrExpired},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := test.code
+			r, err := c.Redeem("user", now)
+			assert.ErrorIs(t, err, test.err)
+			if test.err == nil {
+				assert.Equal(t, test.code.Used+1, c.Used)
+				assert.Equal(t, c.Amount, r.Amount)
+				assert.Equal(t, "user", r.Owner)
+			} else {
+				assert.Equal(t, test.code.Used, c.Used)
+			}
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	b := &Batch{Count: 500, Prefix: "SUMMER", Amount: 1000, MaxUses: 1}
+	assert.NoError(t, b.Validate())
+	codes, err := b.Generate(time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, codes, 500)
+	seen := map[string]bool{}
+	for _, c := range codes {
+		assert.True(t, strings.HasPrefix(c.Code, "SUMMER"))
+		assert.Len(t, c.Code, len("SUMMER")+CodeLen)
+		assert.Equal(t, shortcode.Normalize(c.Code), c.Code)
+		assert.False(t, seen[c.Code])
+		seen[c.Code] = true
+	}
+}
+
+func TestValidateBatch(t *testing.T) {
+	for _, b := range []Batch{
+		{Count: 0, Amount: 100},
+		{Count: MaxBatch + 1, Amount: 100},
+		{Count: 1, Amount: 0},
+		{Count: 1, Amount: 100, MaxUses: -1},
+		{Count: 1, Amount: 100, Prefix: "summer"},
+	} {
+		assert.ErrorIs(t, b.Validate(), ErrInvalid)
+	}
+}
@@ -0,0 +1,99 @@
+// Package referral describes referral program rewarding users for invited users.
+package referral
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Nexadis/gophmart/internal/money"
+	"github.com/Nexadis/gophmart/internal/order"
+	"github.com/Nexadis/gophmart/internal/shortcode"
+)
+
+const CodeLen = 8
+
+// Sources of bonuses credited by the program.
+const (
+	SourceReferrer = "referral:referrer"
+	SourceReferee  = "referral:referee"
+)
+
+// Statuses of referral.
+const (
+	StatusPending  = "PENDING"
+	StatusRewarded = "REWARDED"
+)
+
+var (
+	ErrSelfReferral = errors.New(`can't use own referral code`)
+	ErrCapReached   = errors.New(`referral limit of referrer is reached`)
+)
+
+// Referral links user registered with referral code to owner of the code.
+type Referral struct {
+	Referrer   string            `json:"-"`
+	Referee    string            `json:"login"`
+	Status     string            `json:"status"`
+	Order      order.OrderNumber `json:"order,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	RewardedAt *time.Time        `json:"rewarded_at,omitempty"`
+}
+
+// Program rewards both users when the first order of referee is processed.
+// Cap limits referrals of one referrer, 0 is unlimited.
+type Program struct {
+	ReferrerReward money.Amount
+	RefereeReward  money.Amount
+	Cap            int
+}
+
+// Enabled returns true if the program credits any reward.
+func (p Program) Enabled() bool {
+	return p.ReferrerReward > 0 || p.RefereeReward > 0
+}
+
+// Check returns error if referee can't be invited by referrer having count referrals.
+func (p Program) Check(referrer, referee string, count int) error {
+	if referrer == referee {
+		return ErrSelfReferral
+	}
+	if p.Cap > 0 && count >= p.Cap {
+		return ErrCapReached
+	}
+	return nil
+}
+
+// Reward marks pending referral rewarded for the order and returns bonuses to credit.
+func (p Program) Reward(r *Referral, number order.OrderNumber, now time.Time) []*order.Bonus {
+	if r.Status != StatusPending {
+		return nil
+	}
+	r.Status = StatusRewarded
+	r.Order = number
+	r.RewardedAt = &now
+	bonuses := make([]*order.Bonus, 0, 2)
+	if p.ReferrerReward > 0 {
+		bonuses = append(bonuses, &order.Bonus{
+			Number:    number,
+			Owner:     r.Referrer,
+			Source:    SourceReferrer,
+			Amount:    p.ReferrerReward,
+			CreatedAt: now,
+		})
+	}
+	if p.RefereeReward > 0 {
+		bonuses = append(bonuses, &order.Bonus{
+			Number:    number,
+			Owner:     r.Referee,
+			Source:    SourceReferee,
+			Amount:    p.RefereeReward,
+			CreatedAt: now,
+		})
+	}
+	return bonuses
+}
+
+// NewCode returns random referral code.
+func NewCode() (string, error) {
+	return shortcode.Random(CodeLen)
+}
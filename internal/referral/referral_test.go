@@ -0,0 +1,58 @@
+package referral
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nexadis/gophmart/internal/shortcode"
+)
+
+func TestCheck(t *testing.T) {
+	p := Program{ReferrerReward: 100, Cap: 2}
+	tests := []struct {
+		name     string
+		referrer string
+		count    int
+		err      error
+	}{
+		{"Invite", "alice", 1, nil},
+		{"Self referral", "bob", 0, ErrSelfReferral},
+		{"Cap reached", "alice", 2, ErrCapReached},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.ErrorIs(t, p.Check(test.referrer, "bob", test.count), test.err)
+		})
+	}
+	assert.NoError(t, Program{}.Check("alice", "bob", 1000))
+}
+
+func TestReward(t *testing.T) {
+	now := time.Date(2023, time.June, 1, 0, 0, 0, 0, time.UTC)
+	p := Program{ReferrerReward: 500, RefereeReward: 300}
+	r := &Referral{Referrer: "alice", Referee: "bob", Status: StatusPending}
+	bonuses := p.Reward(r, "2377225624", now)
+	if assert.Len(t, bonuses, 2) {
+		assert.Equal(t, "alice", bonuses[0].Owner)
+		assert.Equal(t, SourceReferrer, bonuses[0].Source)
+		assert.Equal(t, p.ReferrerReward, bonuses[0].Amount)
+		assert.Equal(t, "bob", bonuses[1].Owner)
+		assert.Equal(t, SourceReferee, bonuses[1].Source)
+		assert.Equal(t, p.RefereeReward, bonuses[1].Amount)
+	}
+	assert.Equal(t, StatusRewarded, r.Status)
+	assert.Equal(t, &now, r.RewardedAt)
+	assert.Empty(t, p.Reward(r, "12345678903", now), "referral is rewarded once")
+
+	r = &Referral{Referrer: "alice", Referee: "bob", Status: StatusPending}
+	assert.Len(t, Program{RefereeReward: 300}.Reward(r, "2377225624", now), 1)
+}
+
+func TestNewCode(t *testing.T) {
+	code, err := NewCode()
+	assert.NoError(t, err)
+	assert.Len(t, code, CodeLen)
+	assert.Equal(t, shortcode.Normalize(code), code)
+}
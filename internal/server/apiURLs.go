@@ -5,7 +5,26 @@ const (
 	APIUserLogin           = "/api/user/login"
 	APIRestricted          = "/api/user"
 	APIUserOrders          = "/orders"
+	APIUserOrder           = "/orders/:number"
+	APIUserOrdersBatch     = "/orders/batch"
 	APIUserBalance         = "/balance"
 	APIUserBalanceWithdraw = "/balance/withdraw"
 	APIUserWithdrawals     = "/withdrawals"
+	APIUserBalanceHold     = "/balance/hold"
+	APIUserHoldCapture     = "/balance/hold/:number/capture"
+	APIUserHoldVoid        = "/balance/hold/:number/void"
+	APIUserTier            = "/tier"
+	APIUserPromo           = "/promo"
+	APIUserBalanceTransfer = "/balance/transfer"
+	APIUserTransfers       = "/transfers"
+	APIUserReferrals       = "/referrals"
+	APIInternalAccruals    = "/api/internal/accruals"
+	APIAdmin               = "/api/admin"
+	APIAdminOrderRequeue   = "/orders/:number/requeue"
+	APIAdminWithdrawRefund = "/withdrawals/:number/refund"
+	APIAdminWithdrawCancel = "/withdrawals/:number/cancel"
+	APIAdminCampaigns      = "/campaigns"
+	APIAdminCampaign       = "/campaigns/:id"
+	APIAdminPromo          = "/promo"
+	APIAdminAccrualStatus  = "/accrual/status"
 )
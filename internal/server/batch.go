@@ -0,0 +1,118 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Nexadis/gophmart/internal/db"
+	"github.com/Nexadis/gophmart/internal/logger"
+	"github.com/Nexadis/gophmart/internal/order"
+	"github.com/Nexadis/gophmart/internal/server/auth"
+)
+
+const (
+	MIMETextCSV    = "text/csv"
+	MaxBatchOrders = 10000
+)
+
+const (
+	ResultAccepted     = `accepted`
+	ResultAlreadyYours = `already_yours`
+	ResultConflict     = `conflict`
+)
+
+type orderResult struct {
+	Number string `json:"number"`
+	Result string `json:"result"`
+}
+
+// UserOrdersBatch saves JSON array or CSV of order numbers and returns result for each number.
+func (s *Server) UserOrdersBatch(c echo.Context) error {
+	req := c.Request()
+	login, err := auth.GetLogin(c)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	defer req.Body.Close()
+	numbers, err := parseBatch(req.Header.Get(echo.HeaderContentType), req.Body)
+	if err != nil {
+		logger.Logger.Infoln(err)
+		return c.String(http.StatusBadRequest, InvalidReq)
+	}
+	if len(numbers) == 0 || len(numbers) > MaxBatchOrders {
+		return c.String(http.StatusBadRequest, fmt.Sprintf("batch should have 1-%d orders", MaxBatchOrders))
+	}
+
+	results := make([]orderResult, len(numbers))
+	orders := make([]*order.Order, 0, len(numbers))
+	indexes := make([]int, 0, len(numbers))
+	for i, number := range numbers {
+		results[i].Number = number
+		n, err := s.parser.Parse(number)
+		if err != nil {
+			results[i].Result = ResultInvalid
+			continue
+		}
+		o := order.New(n, login)
+		results[i].Number = string(o.Number)
+		orders = append(orders, o)
+		indexes = append(indexes, i)
+	}
+	if len(orders) > 0 {
+		errs, err := s.db.AddOrders(req.Context(), orders)
+		if err != nil {
+			logger.Logger.Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		for j, err := range errs {
+			i := indexes[j]
+			switch {
+			case err == nil:
+				results[i].Result = ResultAccepted
+				if s.accrual != nil {
+					s.accrual.Notify(orders[j].Number)
+				}
+			case errors.Is(err, db.ErrOrderAdded):
+				results[i].Result = ResultAlreadyYours
+			case errors.Is(err, db.ErrOtherUserOrder):
+				results[i].Result = ResultConflict
+			}
+		}
+	}
+	logger.Logger.Infof("Save batch of %d orders for %s", len(numbers), login)
+	return c.JSON(http.StatusOK, results)
+}
+
+// parseBatch reads order numbers from JSON array or from the first column of CSV,
+// CSV may have header "number".
+func parseBatch(contentType string, body io.Reader) ([]string, error) {
+	if strings.HasPrefix(contentType, MIMETextCSV) {
+		r := csv.NewReader(body)
+		r.FieldsPerRecord = -1
+		records, err := r.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		numbers := make([]string, 0, len(records))
+		for i, record := range records {
+			if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "number") {
+				continue
+			}
+			numbers = append(numbers, record[0])
+		}
+		return numbers, nil
+	}
+	if strings.HasPrefix(contentType, echo.MIMEApplicationJSON) {
+		var numbers []string
+		err := json.NewDecoder(body).Decode(&numbers)
+		return numbers, err
+	}
+	return nil, fmt.Errorf("unsupported content type: %q", contentType)
+}
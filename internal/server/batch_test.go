@@ -0,0 +1,91 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nexadis/gophmart/internal/db"
+	"github.com/Nexadis/gophmart/mocks"
+)
+
+var testsUserOrdersBatch = []struct {
+	name        string
+	contentType string
+	body        string
+	status      int
+	results     []orderResult
+}{
+	{
+		name:        "JSON batch",
+		contentType: echo.MIMEApplicationJSON,
+		body:        `["445084503850","12345678903","79927398713","4450845a3850"]`,
+		status:      http.StatusOK,
+		results: []orderResult{
+			{"445084503850", ResultAccepted},
+			{"12345678903", ResultAlreadyYours},
+			{"79927398713", ResultConflict},
+			{"4450845a3850", ResultInvalid},
+		},
+	},
+	{
+		name:        "CSV batch with header",
+		contentType: MIMETextCSV,
+		body:        "number\n445084503850\n12345678903\n79927398713\n4450845a3850\n",
+		status:      http.StatusOK,
+		results: []orderResult{
+			{"445084503850", ResultAccepted},
+			{"12345678903", ResultAlreadyYours},
+			{"79927398713", ResultConflict},
+			{"4450845a3850", ResultInvalid},
+		},
+	},
+	{
+		name:        "Empty batch",
+		contentType: echo.MIMEApplicationJSON,
+		body:        `[]`,
+		status:      http.StatusBadRequest,
+	},
+	{
+		name:        "Unsupported content type",
+		contentType: echo.MIMETextPlain,
+		body:        "445084503850",
+		status:      http.StatusBadRequest,
+	},
+}
+
+func TestUserOrdersBatch(t *testing.T) {
+	s := newTestServer()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockDatabase(ctrl)
+	s.db = mockdb
+	for _, test := range testsUserOrdersBatch {
+		t.Run(test.name, func(t *testing.T) {
+			if test.status == http.StatusOK {
+				mockdb.EXPECT().AddOrders(gomock.Any(), gomock.Len(3)).Return(
+					[]error{nil, db.ErrOrderAdded, db.ErrOtherUserOrder}, nil)
+			}
+			req := httptest.NewRequest(http.MethodPost, APIRestricted+APIUserOrdersBatch, strings.NewReader(test.body))
+			req.Header.Set(echo.HeaderContentType, test.contentType)
+			rec := httptest.NewRecorder()
+			c := s.e.NewContext(req, rec)
+			setLogin(c, defaultUser.Login)
+			if assert.NoError(t, s.UserOrdersBatch(c)) {
+				assert.Equal(t, test.status, rec.Code)
+			}
+			if test.status == http.StatusOK {
+				var results []orderResult
+				if assert.NoError(t, json.NewDecoder(rec.Body).Decode(&results)) {
+					assert.Equal(t, test.results, results)
+				}
+			}
+		})
+	}
+}
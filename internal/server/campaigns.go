@@ -0,0 +1,57 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Nexadis/gophmart/internal/campaign"
+	"github.com/Nexadis/gophmart/internal/db"
+	"github.com/Nexadis/gophmart/internal/logger"
+)
+
+func (s *Server) AdminCampaignAdd(c echo.Context) error {
+	added := &campaign.Campaign{}
+	if err := c.Bind(added); err != nil {
+		return c.String(http.StatusBadRequest, InvalidReq)
+	}
+	added.ID = 0
+	if err := added.Validate(); err != nil {
+		return c.String(http.StatusUnprocessableEntity, err.Error())
+	}
+	err := s.db.AddCampaign(c.Request().Context(), added)
+	if err != nil {
+		logger.Logger.Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	logger.Logger.Infof("Add campaign %d %q", added.ID, added.Name)
+	return c.JSON(http.StatusCreated, added)
+}
+
+func (s *Server) AdminCampaigns(c echo.Context) error {
+	campaigns, err := s.db.GetCampaigns(c.Request().Context())
+	if err != nil {
+		logger.Logger.Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, campaigns)
+}
+
+func (s *Server) AdminCampaignDelete(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	err = s.db.DeleteCampaign(c.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, db.ErrCampaignNotFound) {
+			return c.String(http.StatusNotFound, err.Error())
+		}
+		logger.Logger.Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	logger.Logger.Infof("Delete campaign %d", id)
+	return c.NoContent(http.StatusOK)
+}
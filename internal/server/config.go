@@ -2,18 +2,85 @@ package server
 
 import (
 	"flag"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v9"
 
+	"github.com/Nexadis/gophmart/internal/client"
+	"github.com/Nexadis/gophmart/internal/expiry"
 	"github.com/Nexadis/gophmart/internal/logger"
+	"github.com/Nexadis/gophmart/internal/money"
+	"github.com/Nexadis/gophmart/internal/order"
+	"github.com/Nexadis/gophmart/internal/policy"
+	"github.com/Nexadis/gophmart/internal/referral"
+	"github.com/Nexadis/gophmart/internal/tier"
 )
 
 type Config struct {
 	RunAddress           string `env:"RUN_ADDRESS"`
 	DBURI                string `env:"DATABASE_URI"`
 	AccrualSystemAddress string `env:"ACCRUAL_SYSTEM_ADDRESS"`
-	JwtSecret            string `env:"JWT_SECRET"`
-	Wait                 int64  `env:"WAIT"`
+	AccrualRoutes        string `env:"ACCRUAL_ROUTES"`
+
+	AccrualTimeout   time.Duration `env:"ACCRUAL_TIMEOUT"`
+	AccrualCAFile    string        `env:"ACCRUAL_CA_FILE"`
+	AccrualCertFile  string        `env:"ACCRUAL_CERT_FILE"`
+	AccrualKeyFile   string        `env:"ACCRUAL_KEY_FILE"`
+	AccrualBasePath  string        `env:"ACCRUAL_BASE_PATH"`
+	AccrualUserAgent string        `env:"ACCRUAL_USER_AGENT"`
+	AccrualDebug     bool          `env:"ACCRUAL_DEBUG"`
+
+	JwtSecret     string `env:"JWT_SECRET"`
+	AdminToken    string `env:"ADMIN_TOKEN"`
+	WebhookSecret string `env:"ACCRUAL_WEBHOOK_SECRET"`
+	// WebhookTolerance is max difference between signed timestamp of pushed accruals and now.
+	WebhookTolerance time.Duration `env:"ACCRUAL_WEBHOOK_TOLERANCE"`
+	Wait             int64         `env:"WAIT"`
+	AccrualBatch     int           `env:"ACCRUAL_BATCH"`
+
+	OrderMinLen int    `env:"ORDER_MIN_LEN"`
+	OrderMaxLen int    `env:"ORDER_MAX_LEN"`
+	OrderZeros  string `env:"ORDER_LEADING_ZEROS"`
+
+	RetryBaseDelay   time.Duration `env:"RETRY_BASE_DELAY"`
+	RetryMaxDelay    time.Duration `env:"RETRY_MAX_DELAY"`
+	BreakerThreshold int           `env:"BREAKER_THRESHOLD"`
+	BreakerTimeout   time.Duration `env:"BREAKER_TIMEOUT"`
+
+	AccrualMaxAttempts int           `env:"ACCRUAL_MAX_ATTEMPTS"`
+	AccrualMaxAge      time.Duration `env:"ACCRUAL_MAX_AGE"`
+
+	AccrualRevisionWindow   time.Duration `env:"ACCRUAL_REVISION_WINDOW"`
+	AccrualRevisionInterval time.Duration `env:"ACCRUAL_REVISION_INTERVAL"`
+
+	IdempotencyTTL   time.Duration `env:"IDEMPOTENCY_TTL"`
+	IdempotencyLease time.Duration `env:"IDEMPOTENCY_LEASE"`
+	HoldTTL          time.Duration `env:"HOLD_TTL"`
+
+	WithdrawMin        money.Amount `env:"WITHDRAW_MIN"`
+	WithdrawMax        money.Amount `env:"WITHDRAW_MAX"`
+	WithdrawDailyCap   money.Amount `env:"WITHDRAW_DAILY_CAP"`
+	WithdrawMonthlyCap money.Amount `env:"WITHDRAW_MONTHLY_CAP"`
+	WithdrawMaxShare   int          `env:"WITHDRAW_MAX_ORDER_SHARE"`
+
+	TransferMin      money.Amount `env:"TRANSFER_MIN"`
+	TransferMax      money.Amount `env:"TRANSFER_MAX"`
+	TransferDailyCap money.Amount `env:"TRANSFER_DAILY_CAP"`
+
+	ReferrerReward money.Amount `env:"REFERRER_REWARD"`
+	RefereeReward  money.Amount `env:"REFEREE_REWARD"`
+	ReferralCap    int          `env:"REFERRAL_CAP"`
+
+	Tiers        string        `env:"TIERS"`
+	TierBasis    string        `env:"TIER_BASIS"`
+	TierWindow   time.Duration `env:"TIER_WINDOW"`
+	TierInterval time.Duration `env:"TIER_INTERVAL"`
+
+	PointsExpireMonths   int           `env:"POINTS_EXPIRE_MONTHS"`
+	PointsExpiringSoon   time.Duration `env:"POINTS_EXPIRING_SOON"`
+	PointsExpiryInterval time.Duration `env:"POINTS_EXPIRY_INTERVAL"`
 }
 
 func NewConfig() *Config {
@@ -26,7 +93,51 @@ func setFlags(c *Config) {
 	flag.StringVar(&c.RunAddress, "a", ":8080", "Run Address for server")
 	flag.StringVar(&c.DBURI, "d", "", "Database Uri")
 	flag.StringVar(&c.AccrualSystemAddress, "r", "", "Accrual System Address")
+	flag.StringVar(&c.AccrualRoutes, "accrual-routes", "", "Accrual systems for order prefixes: 'prefix=address,...'")
+	flag.DurationVar(&c.AccrualTimeout, "accrual-timeout", 10*time.Second, "Timeout of request to accrual system")
+	flag.StringVar(&c.AccrualCAFile, "accrual-ca", "", "PEM bundle to verify accrual system")
+	flag.StringVar(&c.AccrualCertFile, "accrual-cert", "", "Client certificate for accrual system")
+	flag.StringVar(&c.AccrualKeyFile, "accrual-key", "", "Client key for accrual system")
+	flag.StringVar(&c.AccrualBasePath, "accrual-base-path", client.DefaultBasePath, "Base path of accrual system API")
+	flag.StringVar(&c.AccrualUserAgent, "accrual-user-agent", client.DefaultUserAgent, "User-Agent for requests to accrual system")
+	flag.BoolVar(&c.AccrualDebug, "accrual-debug", false, "Log requests and responses of accrual system")
+	flag.StringVar(&c.AdminToken, "admin-token", "", "Token for admin API, admin API is disabled if empty")
+	flag.StringVar(&c.WebhookSecret, "webhook-secret", "", "Secret for accruals pushed by accrual system, push is disabled if empty")
+	flag.DurationVar(&c.WebhookTolerance, "webhook-tolerance", 5*time.Minute, "Max age of signed timestamp of accruals pushed by accrual system")
 	flag.Int64Var(&c.Wait, "t", 1, "Timeout for get accruals")
+	flag.IntVar(&c.OrderMinLen, "order-min-len", 2, "Min digits in order number")
+	flag.IntVar(&c.OrderMaxLen, "order-max-len", 64, "Max digits in order number, 0 is unlimited")
+	flag.StringVar(&c.OrderZeros, "order-leading-zeros", string(order.ZerosKeep), "Leading zeros in order number: keep, strip or reject")
+	flag.IntVar(&c.AccrualBatch, "accrual-batch", 100, "Max orders checked in accrual system per scan")
+	flag.DurationVar(&c.RetryBaseDelay, "retry-base", time.Second, "Base delay before retry order in accrual system")
+	flag.DurationVar(&c.RetryMaxDelay, "retry-max", 5*time.Minute, "Max delay before retry order in accrual system")
+	flag.IntVar(&c.BreakerThreshold, "breaker-threshold", 5, "Failures in a row to open accrual breaker")
+	flag.DurationVar(&c.BreakerTimeout, "breaker-timeout", 30*time.Second, "Time before accrual breaker lets a probe request")
+	flag.IntVar(&c.AccrualMaxAttempts, "accrual-max-attempts", 0, "Checks of order before it becomes STALE, 0 is unlimited")
+	flag.DurationVar(&c.AccrualMaxAge, "accrual-max-age", 72*time.Hour, "Age of unprocessed order before it becomes STALE, 0 is unlimited")
+	flag.DurationVar(&c.AccrualRevisionWindow, "revision-window", 0, "Time after processing while order accrual is re-checked, 0 disables re-checks")
+	flag.DurationVar(&c.AccrualRevisionInterval, "revision-interval", time.Hour, "Interval between re-checks of processed order")
+	flag.StringVar(&c.Tiers, "tiers", "", "Loyalty tiers: 'name:threshold:multiplier percent,...', tiers are disabled if empty")
+	flag.StringVar(&c.TierBasis, "tier-basis", tier.BasisAccrued, "Points of tier: accrued or spent")
+	flag.DurationVar(&c.TierWindow, "tier-window", 365*24*time.Hour, "Rolling period of tier points")
+	flag.DurationVar(&c.TierInterval, "tier-interval", 24*time.Hour, "Interval between recalculations of tiers")
+	flag.IntVar(&c.PointsExpireMonths, "points-expire-months", 0, "Months after processing when unspent points expire, 0 disables expiration")
+	flag.DurationVar(&c.PointsExpiringSoon, "points-expiring-soon", 30*24*time.Hour, "Period before expiration when points are shown as expiring soon")
+	flag.DurationVar(&c.PointsExpiryInterval, "points-expiry-interval", time.Hour, "Interval between runs of points expiry job")
+	flag.DurationVar(&c.HoldTTL, "hold-ttl", 15*time.Minute, "Time before uncaptured hold of points expires")
+	flag.TextVar(&c.WithdrawMin, "withdraw-min", money.Amount(0), "Min sum of withdrawal, 0 is unlimited")
+	flag.TextVar(&c.WithdrawMax, "withdraw-max", money.Amount(0), "Max sum of withdrawal, 0 is unlimited")
+	flag.TextVar(&c.WithdrawDailyCap, "withdraw-daily-cap", money.Amount(0), "Max sum withdrawn by user per day, 0 is unlimited")
+	flag.TextVar(&c.WithdrawMonthlyCap, "withdraw-monthly-cap", money.Amount(0), "Max sum withdrawn by user per month, 0 is unlimited")
+	flag.IntVar(&c.WithdrawMaxShare, "withdraw-max-order-share", 0, "Max percent of order total paid with points, 0 is unlimited")
+	flag.TextVar(&c.TransferMin, "transfer-min", money.Amount(0), "Min sum of transfer, 0 is unlimited")
+	flag.TextVar(&c.TransferMax, "transfer-max", money.Amount(0), "Max sum of transfer, 0 is unlimited")
+	flag.TextVar(&c.TransferDailyCap, "transfer-daily-cap", money.Amount(0), "Max sum transferred by user per day, 0 is unlimited")
+	flag.TextVar(&c.ReferrerReward, "referrer-reward", money.Amount(0), "Points for referrer when first order of referee is processed")
+	flag.TextVar(&c.RefereeReward, "referee-reward", money.Amount(0), "Points for referee when the first order is processed")
+	flag.IntVar(&c.ReferralCap, "referral-cap", 0, "Max referrals of one user, 0 is unlimited")
+	flag.DurationVar(&c.IdempotencyTTL, "idempotency-ttl", 24*time.Hour, "Time while response is replayed for Idempotency-Key, 0 disables keys")
+	flag.DurationVar(&c.IdempotencyLease, "idempotency-lease", 30*time.Second, "Time after which unfinished request with Idempotency-Key may be retried")
 }
 
 func (c *Config) Parse() error {
@@ -38,12 +149,145 @@ func (c *Config) Parse() error {
 	RunAddress: %q
 	DBUri: %q
 	AccrualSystemAddress: %q
+	AccrualRoutes: %q
+	Accrual HTTP: timeout %s, base path %q, user agent %q, CA %q, cert %q, debug %t
 	JwtSecret: %q
-	Interval get Accruals: %d`,
+	Interval get Accruals: %d
+	Accruals batch: %d
+	Order number: %d-%d digits, leading zeros %q
+	Retry delay: %s-%s
+	Breaker: %d failures, %s timeout
+	Give up: %d attempts, %s age
+	Revision: %s window, %s interval
+	Webhook tolerance: %s
+	Idempotency TTL: %s, lease %s
+	Hold TTL: %s
+	Withdraw limits: %s-%s, caps %s daily, %s monthly, %d%% of order
+	Transfer limits: %s-%s, cap %s daily
+	Referral rewards: %s to referrer, %s to referee, cap %d
+	Tiers: %q by %s points for %s, recalculated every %s
+	Points expiration: %d months, %s expiring soon, %s interval`,
 		c.RunAddress,
 		c.DBURI,
 		c.AccrualSystemAddress,
+		c.AccrualRoutes,
+		c.AccrualTimeout,
+		c.AccrualBasePath,
+		c.AccrualUserAgent,
+		c.AccrualCAFile,
+		c.AccrualCertFile,
+		c.AccrualDebug,
 		c.JwtSecret,
-		c.Wait)
+		c.Wait,
+		c.AccrualBatch,
+		c.OrderMinLen,
+		c.OrderMaxLen,
+		c.OrderZeros,
+		c.RetryBaseDelay,
+		c.RetryMaxDelay,
+		c.BreakerThreshold,
+		c.BreakerTimeout,
+		c.AccrualMaxAttempts,
+		c.AccrualMaxAge,
+		c.AccrualRevisionWindow,
+		c.AccrualRevisionInterval,
+		c.WebhookTolerance,
+		c.IdempotencyTTL,
+		c.IdempotencyLease,
+		c.HoldTTL,
+		c.WithdrawMin,
+		c.WithdrawMax,
+		c.WithdrawDailyCap,
+		c.WithdrawMonthlyCap,
+		c.WithdrawMaxShare,
+		c.TransferMin,
+		c.TransferMax,
+		c.TransferDailyCap,
+		c.ReferrerReward,
+		c.RefereeReward,
+		c.ReferralCap,
+		c.Tiers,
+		c.TierBasis,
+		c.TierWindow,
+		c.TierInterval,
+		c.PointsExpireMonths,
+		c.PointsExpiringSoon,
+		c.PointsExpiryInterval)
 	return nil
 }
+
+func (c *Config) NumberParser() (order.NumberParser, error) {
+	zeros := order.ZerosPolicy(c.OrderZeros)
+	switch zeros {
+	case "", order.ZerosKeep, order.ZerosStrip, order.ZerosReject:
+	default:
+		return order.NumberParser{}, fmt.Errorf("invalid leading zeros policy: %q", c.OrderZeros)
+	}
+	return order.NumberParser{
+		MinLen: c.OrderMinLen,
+		MaxLen: c.OrderMaxLen,
+		Zeros:  zeros,
+	}, nil
+}
+
+// Routes parses AccrualRoutes, e.g. "4=http://partner:8080,55=http://other:8080".
+func (c *Config) Routes() ([]client.Route, error) {
+	if c.AccrualRoutes == "" {
+		return nil, nil
+	}
+	var routes []client.Route
+	for _, r := range strings.Split(c.AccrualRoutes, ",") {
+		prefix, addr, ok := strings.Cut(strings.TrimSpace(r), "=")
+		if !ok || addr == "" {
+			return nil, fmt.Errorf("invalid accrual route: %q", r)
+		}
+		routes = append(routes, client.Route{
+			Prefix: prefix,
+			Addr:   addr,
+		})
+	}
+	return routes, nil
+}
+
+func (c *Config) ExpiryPolicy() expiry.Policy {
+	return expiry.Policy{
+		Months: c.PointsExpireMonths,
+		Soon:   c.PointsExpiringSoon,
+	}
+}
+
+func (c *Config) WithdrawPolicy() policy.Withdraw {
+	return policy.Withdraw{
+		Min:        c.WithdrawMin,
+		Max:        c.WithdrawMax,
+		DailyCap:   c.WithdrawDailyCap,
+		MonthlyCap: c.WithdrawMonthlyCap,
+		MaxShare:   c.WithdrawMaxShare,
+	}
+}
+
+func (c *Config) ParseTiers() (tier.Tiers, error) {
+	switch c.TierBasis {
+	case "", tier.BasisAccrued, tier.BasisSpent:
+	default:
+		return nil, fmt.Errorf("invalid tier basis: %q", c.TierBasis)
+	}
+	return tier.Parse(c.Tiers)
+}
+
+// TransferPolicy limits transfers with the same rules as withdrawals.
+func (c *Config) TransferPolicy() policy.Withdraw {
+	return policy.Withdraw{
+		Min:      c.TransferMin,
+		Max:      c.TransferMax,
+		DailyCap: c.TransferDailyCap,
+	}
+}
+
+func (c *Config) ReferralProgram() referral.Program {
+	return referral.Program{
+		ReferrerReward: c.ReferrerReward,
+		RefereeReward:  c.RefereeReward,
+		Cap:            c.ReferralCap,
+	}
+}
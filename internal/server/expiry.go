@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/Nexadis/gophmart/internal/expiry"
+	"github.com/Nexadis/gophmart/internal/logger"
+)
+
+// expirePoints records expirations of unspent points until ctx is done.
+func (s *Server) expirePoints(ctx context.Context) {
+	ticker := time.NewTicker(s.config.PointsExpiryInterval)
+	defer ticker.Stop()
+	for {
+		err := s.expireOnce(ctx, time.Now())
+		if err != nil {
+			logger.Logger.Errorf("Can't expire points: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) expireOnce(ctx context.Context, now time.Time) error {
+	policy := s.config.ExpiryPolicy()
+	// a week of margin covers months of different length
+	before := now.AddDate(0, -policy.Months, 7)
+	owners, err := s.db.GetExpiringOwners(ctx, before)
+	if err != nil {
+		return err
+	}
+	for _, owner := range owners {
+		expired, err := s.db.ExpirePoints(ctx, owner, policy, now)
+		if err != nil {
+			return err
+		}
+		if len(expired) == 0 {
+			continue
+		}
+		plan := &expiry.Plan{Expired: expired}
+		logger.Logger.Infof("Expired %s points of %s in %d orders", plan.Pending(), owner, len(expired))
+	}
+	return nil
+}
+
+// expiringSoon returns points of the owner which expire within the policy Soon period.
+func (s *Server) expiringSoon(ctx context.Context, owner string, now time.Time) ([]expiry.Expiring, error) {
+	policy := s.config.ExpiryPolicy()
+	if !policy.Enabled() || policy.Soon <= 0 {
+		return nil, nil
+	}
+	plan, err := s.planExpiry(ctx, owner, now)
+	if err != nil {
+		return nil, err
+	}
+	return plan.ExpiringSoon(policy, now), nil
+}
+
+// planExpiry returns plan of due expirations of the owner, it doesn't record them.
+func (s *Server) planExpiry(ctx context.Context, owner string, now time.Time) (*expiry.Plan, error) {
+	lots, err := s.db.GetLots(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	debits, err := s.db.GetDebits(ctx, owner, now)
+	if err != nil {
+		return nil, err
+	}
+	recorded, err := s.db.GetExpirations(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	return s.config.ExpiryPolicy().Plan(owner, lots, debits, recorded, now), nil
+}
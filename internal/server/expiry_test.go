@@ -0,0 +1,30 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nexadis/gophmart/internal/expiry"
+	"github.com/Nexadis/gophmart/mocks"
+)
+
+func TestExpireOnce(t *testing.T) {
+	s := newTestServer()
+	s.config.PointsExpireMonths = 12
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockDatabase(ctrl)
+	s.db = mockdb
+	now := time.Date(2024, time.June, 10, 12, 0, 0, 0, time.UTC)
+	mockdb.EXPECT().GetExpiringOwners(gomock.Any(), now.AddDate(-1, 0, 7)).Return([]string{"alice", "bob"}, nil)
+	// plan is made and recorded by the database with the owner locked
+	mockdb.EXPECT().ExpirePoints(gomock.Any(), "alice", s.config.ExpiryPolicy(), now).Return([]*expiry.Expiration{
+		{Number: "12345678903", Owner: "alice", Amount: 500, ExpiredAt: now},
+	}, nil)
+	mockdb.EXPECT().ExpirePoints(gomock.Any(), "bob", s.config.ExpiryPolicy(), now).Return(nil, nil)
+	assert.NoError(t, s.expireOnce(context.Background(), now))
+}
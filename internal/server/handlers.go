@@ -12,7 +12,9 @@ import (
 
 	"github.com/Nexadis/gophmart/internal/db"
 	"github.com/Nexadis/gophmart/internal/logger"
+	"github.com/Nexadis/gophmart/internal/money"
 	"github.com/Nexadis/gophmart/internal/order"
+	"github.com/Nexadis/gophmart/internal/referral"
 	"github.com/Nexadis/gophmart/internal/server/auth"
 	"github.com/Nexadis/gophmart/internal/user"
 )
@@ -25,6 +27,14 @@ func (s *Server) UserRegister(c echo.Context) error {
 		logger.Logger.Errorln(err)
 		return c.String(http.StatusBadRequest, InvalidReq)
 	}
+	var r *referral.Referral
+	if u.ReferralCode != "" {
+		var err error
+		r, err = s.newReferral(c.Request().Context(), u)
+		if err != nil {
+			return referralError(c, err)
+		}
+	}
 	err := s.db.AddUser(c.Request().Context(), u)
 	if err != nil {
 		logger.Logger.Errorln(err)
@@ -37,6 +47,13 @@ func (s *Server) UserRegister(c echo.Context) error {
 			return c.NoContent(http.StatusInternalServerError)
 		}
 	}
+	if r != nil {
+		err = s.db.AddReferral(c.Request().Context(), r, s.config.ReferralCap)
+		if err != nil {
+			// user is registered, only the referral is lost
+			logger.Logger.Errorf("Can't add referral of %s by %s: %v", r.Referee, r.Referrer, err)
+		}
+	}
 	logger.Logger.Debugf("Register user:%v", *u)
 	return returnToken(c, u.Login)
 }
@@ -76,14 +93,16 @@ func (s *Server) UserOrdersSave(c echo.Context) error {
 	if err != nil {
 		return c.String(http.StatusInternalServerError, err.Error())
 	}
-	regOrder, err := order.New(string(body), login)
+	number, err := s.parser.Parse(string(body))
 	if err != nil {
 		switch {
 		case errors.Is(err, order.ErrInvalidNum):
+			logger.Logger.Infoln(err)
 			return c.String(http.StatusUnprocessableEntity, err.Error())
 		}
 		return c.String(http.StatusBadRequest, err.Error())
 	}
+	regOrder := order.New(number, login)
 	logger.Logger.Infof("Save order for %s, order %s", login, regOrder.Number)
 	err = s.db.AddOrder(req.Context(), regOrder)
 	if err != nil {
@@ -95,6 +114,9 @@ func (s *Server) UserOrdersSave(c echo.Context) error {
 			return c.String(http.StatusConflict, err.Error())
 		}
 	}
+	if s.accrual != nil {
+		s.accrual.Notify(regOrder.Number)
+	}
 
 	return c.NoContent(http.StatusAccepted)
 }
@@ -117,13 +139,54 @@ func (s *Server) UserOrdersGet(c echo.Context) error {
 	return c.JSON(http.StatusOK, orders)
 }
 
+type orderDetail struct {
+	*order.Order
+	History []*order.StatusChange `json:"history"`
+}
+
+func (s *Server) UserOrderGet(c echo.Context) error {
+	req := c.Request()
+	login, err := auth.GetLogin(c)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	number, err := s.parser.Parse(c.Param("number"))
+	if err != nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	o, err := s.db.GetOrder(req.Context(), number)
+	if err != nil {
+		if errors.Is(err, db.ErrOrderNotFound) {
+			return c.NoContent(http.StatusNotFound)
+		}
+		logger.Logger.Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	if o.Owner != login {
+		return c.NoContent(http.StatusNotFound)
+	}
+	history, err := s.db.GetStatusHistory(req.Context(), number)
+	if err != nil {
+		logger.Logger.Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, orderDetail{
+		Order:   o,
+		History: history,
+	})
+}
+
 func (s *Server) UserBalance(c echo.Context) error {
 	req := c.Request()
 	login, err := auth.GetLogin(c)
 	if err != nil {
 		return c.String(http.StatusInternalServerError, err.Error())
 	}
-	balance, err := getBalance(req.Context(), s.db, login)
+	balance, err := s.getBalance(req.Context(), login)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	balance.ExpiringSoon, err = s.expiringSoon(req.Context(), login, time.Now())
 	if err != nil {
 		return c.String(http.StatusInternalServerError, err.Error())
 	}
@@ -139,27 +202,24 @@ func (s *Server) UserBalanceWithdraw(c echo.Context) error {
 	w := &order.Withdraw{}
 	err = c.Bind(w)
 	if err != nil {
-		return c.String(http.StatusInternalServerError, err.Error())
+		return c.String(http.StatusBadRequest, InvalidReq)
 	}
-	if !w.Order.IsValid() {
+	w.Order, err = s.parser.Parse(string(w.Order))
+	if err != nil {
+		logger.Logger.Infoln(err)
 		return c.NoContent(http.StatusUnprocessableEntity)
 	}
 	w.Owner = login
+	w.Status = order.WithdrawCompleted
+	w.Refunded = 0
 	t := time.Now()
 	w.ProcessedAt = &t
-	balance, err := getBalance(req.Context(), s.db, login)
-	if err != nil {
-		return c.String(http.StatusInternalServerError, err.Error())
-	}
-	if w.Sum > balance.Current {
-		return c.String(http.StatusPaymentRequired, "not enough balance")
-	}
-	err = s.db.AddWithdrawal(req.Context(), w)
+	err = s.db.AddWithdrawal(req.Context(), w, s.config.WithdrawPolicy())
 	if err != nil {
 		if errors.Is(err, db.ErrWithdrawAdded) {
 			return c.String(http.StatusConflict, err.Error())
 		}
-		return c.String(http.StatusInternalServerError, err.Error())
+		return withdrawError(c, err)
 	}
 
 	return c.NoContent(http.StatusOK)
@@ -181,20 +241,146 @@ func (s *Server) UserWithdrawals(c echo.Context) error {
 	return c.JSON(http.StatusOK, withdrawals)
 }
 
-func getBalance(ctx context.Context, db db.Database, owner string) (*user.Balance, error) {
-	accrualled, err := db.GetAccruals(ctx, owner)
+func (s *Server) AccrualStatus(c echo.Context) error {
+	if s.accrual == nil {
+		return c.NoContent(http.StatusServiceUnavailable)
+	}
+	return c.JSON(http.StatusOK, s.accrual.Status())
+}
+
+func (s *Server) AdminOrderRequeue(c echo.Context) error {
+	number, err := s.parser.Parse(c.Param("number"))
+	if err != nil {
+		return c.String(http.StatusUnprocessableEntity, err.Error())
+	}
+	err = s.db.RequeueOrder(c.Request().Context(), number)
+	if err != nil {
+		logger.Logger.Error(err)
+		switch {
+		case errors.Is(err, db.ErrOrderNotFound):
+			return c.String(http.StatusNotFound, err.Error())
+		case errors.Is(err, db.ErrOrderNotStale):
+			return c.String(http.StatusConflict, err.Error())
+		}
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	logger.Logger.Infof("Requeue order %s", number)
+	if s.accrual != nil {
+		s.accrual.Notify(number)
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+type refundRequest struct {
+	Sum *money.Amount `json:"sum"`
+}
+
+// AdminWithdrawRefund refunds sum of withdrawal to the user, the rest is refunded without sum.
+func (s *Server) AdminWithdrawRefund(c echo.Context) error {
+	r := &refundRequest{}
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(r); err != nil {
+			return c.String(http.StatusBadRequest, InvalidReq)
+		}
+	}
+	return s.refundWithdrawal(c, r.Sum, false)
+}
+
+// AdminWithdrawCancel refunds the rest of withdrawal to the user and marks it cancelled.
+func (s *Server) AdminWithdrawCancel(c echo.Context) error {
+	return s.refundWithdrawal(c, nil, true)
+}
+
+func (s *Server) refundWithdrawal(c echo.Context, sum *money.Amount, cancel bool) error {
+	number, err := s.parser.Parse(c.Param("number"))
+	if err != nil {
+		return c.String(http.StatusUnprocessableEntity, err.Error())
+	}
+	w, err := s.db.RefundWithdrawal(c.Request().Context(), number, sum, cancel)
+	if err != nil {
+		logger.Logger.Error(err)
+		switch {
+		case errors.Is(err, db.ErrWithdrawNotFound):
+			return c.String(http.StatusNotFound, err.Error())
+		case errors.Is(err, order.ErrWithdrawReversed):
+			return c.String(http.StatusConflict, err.Error())
+		case errors.Is(err, order.ErrRefundSum):
+			return c.String(http.StatusUnprocessableEntity, err.Error())
+		}
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	logger.Logger.Infof("Withdrawal %s of %s is %s, refunded %s", w.Order, w.Owner, w.Status, w.Refunded)
+	return c.JSON(http.StatusOK, w)
+}
+
+func (s *Server) getBalance(ctx context.Context, owner string) (*user.Balance, error) {
+	accrualled, err := s.db.GetAccruals(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	bonuses, err := s.db.GetBonuses(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	accrualled, err = accrualled.Add(bonuses)
 	if err != nil {
 		return nil, err
 	}
-	withdrawn, err := db.GetWithdrawn(ctx, owner)
+	redeemed, err := s.db.GetRedeemed(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	accrualled, err = accrualled.Add(redeemed)
+	if err != nil {
+		return nil, err
+	}
+	received, sent, err := s.db.GetTransferred(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	accrualled, err = accrualled.Add(received)
+	if err != nil {
+		return nil, err
+	}
+	withdrawn, err := s.db.GetWithdrawn(ctx, owner)
 	if err != nil {
 		return nil, err
 	}
 
-	return &user.Balance{
-		Current:   accrualled - withdrawn,
+	current, err := accrualled.Sub(withdrawn)
+	if err != nil {
+		return nil, err
+	}
+	current, err = current.Sub(sent)
+	if err != nil {
+		return nil, err
+	}
+	held, err := s.db.GetHeld(ctx, owner, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	current, err = current.Sub(held)
+	if err != nil {
+		return nil, err
+	}
+	balance := &user.Balance{
+		Current:   current,
 		Withdrawn: withdrawn,
-	}, nil
+		Held:      held,
+	}
+	if !s.config.ExpiryPolicy().Enabled() {
+		return balance, nil
+	}
+	// expirations are recorded by expirePoints, so history isn't replayed for every balance check
+	expired, err := s.db.GetExpired(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	balance.Current, err = balance.Current.Sub(expired)
+	if err != nil {
+		return nil, err
+	}
+	return balance, nil
 }
 
 func returnToken(c echo.Context, login string) error {
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -15,7 +16,9 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/Nexadis/gophmart/internal/db"
+	"github.com/Nexadis/gophmart/internal/money"
 	"github.com/Nexadis/gophmart/internal/order"
+	"github.com/Nexadis/gophmart/internal/policy"
 	"github.com/Nexadis/gophmart/internal/server/auth"
 	"github.com/Nexadis/gophmart/internal/user"
 	"github.com/Nexadis/gophmart/mocks"
@@ -274,7 +277,21 @@ var testOrdersSave = []testCase{
 		},
 		want: want{
 			status: http.StatusUnprocessableEntity,
-			body:   order.ErrInvalidNum.Error(),
+			body:   (&order.ChecksumError{Number: "445084503851"}).Error(),
+			err:    nil,
+		},
+	},
+	{
+		name: "Malformed number",
+		r: request{
+			method: http.MethodPost,
+			URI:    APIRestricted + APIUserOrders,
+			body:   "44508450385a",
+			user:   otherUser,
+		},
+		want: want{
+			status: http.StatusUnprocessableEntity,
+			body:   (&order.MalformedError{Number: "44508450385a", Reason: "only digits are allowed"}).Error(),
 			err:    nil,
 		},
 	},
@@ -396,10 +413,265 @@ func TestOrdersGet(t *testing.T) {
 }
 
 func TestUserBalance(t *testing.T) {
+	s := newTestServer()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockDatabase(ctrl)
+	s.db = mockdb
+	mockdb.EXPECT().GetAccruals(gomock.Any(), defaultUser.Login).Return(money.Amount(100000), nil)
+	mockdb.EXPECT().GetBonuses(gomock.Any(), defaultUser.Login).Return(money.Amount(2550), nil)
+	mockdb.EXPECT().GetRedeemed(gomock.Any(), defaultUser.Login).Return(money.Amount(1000), nil)
+	mockdb.EXPECT().GetTransferred(gomock.Any(), defaultUser.Login).Return(money.Amount(0), money.Amount(0), nil)
+	mockdb.EXPECT().GetWithdrawn(gomock.Any(), defaultUser.Login).Return(money.Amount(30000), nil)
+	mockdb.EXPECT().GetHeld(gomock.Any(), defaultUser.Login, gomock.Any()).Return(money.Amount(500), nil)
+
+	req := httptest.NewRequest(http.MethodGet, APIRestricted+APIUserBalance, nil)
+	rec := httptest.NewRecorder()
+	c := s.e.NewContext(req, rec)
+	setLogin(c, defaultUser.Login)
+	if assert.NoError(t, s.UserBalance(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"current":730.5,"withdrawn":300,"held":5}`, rec.Body.String())
+	}
+}
+
+func TestUserBalanceExpired(t *testing.T) {
+	s := newTestServer()
+	s.config.PointsExpireMonths = 12
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockDatabase(ctrl)
+	s.db = mockdb
+	mockdb.EXPECT().GetAccruals(gomock.Any(), defaultUser.Login).Return(money.Amount(100000), nil)
+	mockdb.EXPECT().GetBonuses(gomock.Any(), defaultUser.Login).Return(money.Amount(0), nil)
+	mockdb.EXPECT().GetRedeemed(gomock.Any(), defaultUser.Login).Return(money.Amount(0), nil)
+	mockdb.EXPECT().GetTransferred(gomock.Any(), defaultUser.Login).Return(money.Amount(0), money.Amount(0), nil)
+	mockdb.EXPECT().GetWithdrawn(gomock.Any(), defaultUser.Login).Return(money.Amount(0), nil)
+	mockdb.EXPECT().GetHeld(gomock.Any(), defaultUser.Login, gomock.Any()).Return(money.Amount(0), nil)
+	// only recorded expirations are subtracted, history isn't replayed
+	mockdb.EXPECT().GetExpired(gomock.Any(), defaultUser.Login).Return(money.Amount(40000), nil)
+
+	balance, err := s.getBalance(context.Background(), defaultUser.Login)
+	if assert.NoError(t, err) {
+		assert.Equal(t, money.Amount(60000), balance.Current)
+	}
+}
+
+var testsUserBalanceWithdraw = []struct {
+	name   string
+	body   string
+	err    error
+	code   string
+	status int
+}{
+	{"Withdraw", `{"order":"2377225624","sum":5,"order_total":20}`, nil, "", http.StatusOK},
+	{"Daily cap", `{"order":"2377225624","sum":5,"order_total":20}`, &policy.Violation{Code: policy.CodeDailyCap}, policy.CodeDailyCap, http.StatusUnprocessableEntity},
+	{"Not enough balance", `{"order":"2377225624","sum":10,"order_total":100}`, db.ErrNotEnoughBalance, "", http.StatusPaymentRequired},
+	{"Order was payed", `{"order":"2377225624","sum":5,"order_total":20}`, db.ErrWithdrawAdded, "", http.StatusConflict},
+	{"Invalid order", `{"order":"2377225625","sum":5,"order_total":20}`, nil, "", http.StatusUnprocessableEntity},
+	{"Sum with 3 fractional digits", `{"order":"2377225624","sum":5.001,"order_total":20}`, nil, "", http.StatusBadRequest},
 }
 
 func TestUserBalanceWithdraw(t *testing.T) {
+	s := newTestServer()
+	s.config.WithdrawDailyCap = 1000
+	s.config.WithdrawMaxShare = 25
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockDatabase(ctrl)
+	s.db = mockdb
+	for _, test := range testsUserBalanceWithdraw {
+		t.Run(test.name, func(t *testing.T) {
+			if test.err != nil || test.status == http.StatusOK {
+				// balance and policy are checked by the database in the same transaction
+				mockdb.EXPECT().AddWithdrawal(gomock.Any(), gomock.Any(), s.config.WithdrawPolicy()).Return(test.err)
+			}
+			req := httptest.NewRequest(http.MethodPost, APIRestricted+APIUserBalanceWithdraw, strings.NewReader(test.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := s.e.NewContext(req, rec)
+			setLogin(c, defaultUser.Login)
+			if assert.NoError(t, s.UserBalanceWithdraw(c)) {
+				assert.Equal(t, test.status, rec.Code)
+			}
+			if test.code != "" {
+				v := &policy.Violation{}
+				if assert.NoError(t, json.NewDecoder(rec.Body).Decode(v)) {
+					assert.Equal(t, test.code, v.Code)
+				}
+			}
+		})
+	}
 }
 
 func TestUserWithDrawals(t *testing.T) {
 }
+
+var testsAdminOrderRequeue = []struct {
+	name   string
+	number string
+	token  string
+	err    error
+	status int
+}{
+	{"Requeue stale order", "445084503850", "admintoken", nil, http.StatusOK},
+	{"Order isn't stale", "445084503850", "admintoken", db.ErrOrderNotStale, http.StatusConflict},
+	{"Order not found", "12345678903", "admintoken", db.ErrOrderNotFound, http.StatusNotFound},
+	{"Invalid token", "445084503850", "invalid", nil, http.StatusUnauthorized},
+}
+
+func TestAdminOrderRequeue(t *testing.T) {
+	s := newTestServer()
+	s.config.AdminToken = "admintoken"
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockDatabase(ctrl)
+	s.db = mockdb
+	for _, test := range testsAdminOrderRequeue {
+		t.Run(test.name, func(t *testing.T) {
+			if test.token == s.config.AdminToken {
+				mockdb.EXPECT().RequeueOrder(
+					gomock.Any(),
+					order.OrderNumber(test.number),
+				).Return(test.err)
+			}
+			req := httptest.NewRequest(http.MethodPost, APIAdmin+"/orders/"+test.number+"/requeue", nil)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+test.token)
+			rec := httptest.NewRecorder()
+			s.e.ServeHTTP(rec, req)
+			assert.Equal(t, test.status, rec.Code)
+		})
+	}
+}
+
+var testsAccrualStatus = []struct {
+	name   string
+	token  string
+	status int
+}{
+	{"Admin token", "admintoken", http.StatusServiceUnavailable},
+	{"Invalid token", "invalid", http.StatusUnauthorized},
+	{"Without token", "", http.StatusBadRequest},
+}
+
+func TestAccrualStatus(t *testing.T) {
+	s := newTestServer()
+	s.config.AdminToken = "admintoken"
+	for _, test := range testsAccrualStatus {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, APIAdmin+APIAdminAccrualStatus, nil)
+			if test.token != "" {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+test.token)
+			}
+			rec := httptest.NewRecorder()
+			s.e.ServeHTTP(rec, req)
+			assert.Equal(t, test.status, rec.Code)
+		})
+	}
+}
+
+var testsUserOrderGet = []struct {
+	name   string
+	number string
+	owner  string
+	err    error
+	status int
+}{
+	{"Own order", "445084503850", "admin", nil, http.StatusOK},
+	{"Order of other user", "445084503850", "otheruser", nil, http.StatusNotFound},
+	{"Order not found", "12345678903", "", db.ErrOrderNotFound, http.StatusNotFound},
+}
+
+func TestUserOrderGet(t *testing.T) {
+	s := newTestServer()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockDatabase(ctrl)
+	s.db = mockdb
+	for _, test := range testsUserOrderGet {
+		t.Run(test.name, func(t *testing.T) {
+			number := order.OrderNumber(test.number)
+			if test.err != nil {
+				mockdb.EXPECT().GetOrder(gomock.Any(), number).Return(nil, test.err)
+			} else {
+				mockdb.EXPECT().GetOrder(gomock.Any(), number).Return(
+					&order.Order{Number: number, Owner: test.owner, Status: order.StatusProcessing}, nil)
+			}
+			if test.status == http.StatusOK {
+				mockdb.EXPECT().GetStatusHistory(gomock.Any(), number).Return([]*order.StatusChange{
+					{Status: order.StatusNew},
+					{Status: order.StatusProcessing},
+				}, nil)
+			}
+			req := httptest.NewRequest(http.MethodGet, APIRestricted+"/orders/"+test.number, nil)
+			rec := httptest.NewRecorder()
+			c := s.e.NewContext(req, rec)
+			c.SetParamNames("number")
+			c.SetParamValues(test.number)
+			setLogin(c, defaultUser.Login)
+			if assert.NoError(t, s.UserOrderGet(c)) {
+				assert.Equal(t, test.status, rec.Code)
+			}
+			if test.status == http.StatusOK {
+				detail := &struct {
+					Number  string                `json:"number"`
+					History []*order.StatusChange `json:"history"`
+				}{}
+				if assert.NoError(t, json.NewDecoder(rec.Body).Decode(detail)) {
+					assert.Equal(t, test.number, detail.Number)
+					assert.Len(t, detail.History, 2)
+				}
+			}
+		})
+	}
+}
+
+var testsAdminWithdrawRefund = []struct {
+	name   string
+	uri    string
+	body   string
+	sum    *money.Amount
+	cancel bool
+	err    error
+	status int
+}{
+	{"Refund part", "/withdrawals/2377225624/refund", `{"sum":1.5}`, newAmount(150), false, nil, http.StatusOK},
+	{"Refund rest", "/withdrawals/2377225624/refund", ``, nil, false, nil, http.StatusOK},
+	{"Cancel", "/withdrawals/2377225624/cancel", ``, nil, true, nil, http.StatusOK},
+	{"Refund too much", "/withdrawals/2377225624/refund", `{"sum":100}`, newAmount(10000), false, order.ErrRefundSum, http.StatusUnprocessableEntity},
+	{"Refund cancelled", "/withdrawals/2377225624/refund", ``, nil, false, order.ErrWithdrawReversed, http.StatusConflict},
+	{"Withdrawal not found", "/withdrawals/2377225624/cancel", ``, nil, true, db.ErrWithdrawNotFound, http.StatusNotFound},
+}
+
+func newAmount(a money.Amount) *money.Amount {
+	return &a
+}
+
+func TestAdminWithdrawRefund(t *testing.T) {
+	s := newTestServer()
+	s.config.AdminToken = "admintoken"
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockDatabase(ctrl)
+	s.db = mockdb
+	for _, test := range testsAdminWithdrawRefund {
+		t.Run(test.name, func(t *testing.T) {
+			var w *order.Withdraw
+			if test.err == nil {
+				w = &order.Withdraw{Order: "2377225624", Sum: 10000, Status: order.WithdrawRefunded}
+			}
+			mockdb.EXPECT().RefundWithdrawal(
+				gomock.Any(),
+				order.OrderNumber("2377225624"),
+				test.sum,
+				test.cancel,
+			).Return(w, test.err)
+			req := httptest.NewRequest(http.MethodPost, APIAdmin+test.uri, strings.NewReader(test.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer admintoken")
+			rec := httptest.NewRecorder()
+			s.e.ServeHTTP(rec, req)
+			assert.Equal(t, test.status, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Nexadis/gophmart/internal/db"
+	"github.com/Nexadis/gophmart/internal/logger"
+	"github.com/Nexadis/gophmart/internal/order"
+	"github.com/Nexadis/gophmart/internal/policy"
+	"github.com/Nexadis/gophmart/internal/server/auth"
+)
+
+// UserBalanceHold reserves points for the order until capture, void or expiration.
+func (s *Server) UserBalanceHold(c echo.Context) error {
+	req := c.Request()
+	login, err := auth.GetLogin(c)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	h := &order.Hold{}
+	err = c.Bind(h)
+	if err != nil {
+		return c.String(http.StatusBadRequest, InvalidReq)
+	}
+	h.Order, err = s.parser.Parse(string(h.Order))
+	if err != nil {
+		logger.Logger.Infoln(err)
+		return c.NoContent(http.StatusUnprocessableEntity)
+	}
+	now := time.Now()
+	h.Owner = login
+	h.Status = order.HoldActive
+	h.CreatedAt = now
+	h.ExpiresAt = now.Add(s.config.HoldTTL)
+	err = s.db.AddHold(req.Context(), h, s.config.WithdrawPolicy())
+	if err != nil {
+		if errors.Is(err, db.ErrHoldAdded) {
+			return c.String(http.StatusConflict, err.Error())
+		}
+		return withdrawError(c, err)
+	}
+	logger.Logger.Infof("Hold %s of %s for order %s", h.Sum, login, h.Order)
+	return c.JSON(http.StatusOK, h)
+}
+
+// UserHoldCapture turns the hold into withdrawal.
+func (s *Server) UserHoldCapture(c echo.Context) error {
+	login, number, err := s.holdParams(c)
+	if err != nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	w, err := s.db.CaptureHold(c.Request().Context(), login, number, time.Now(), s.config.WithdrawPolicy())
+	if err != nil {
+		return holdError(c, err)
+	}
+	logger.Logger.Infof("Capture hold %s of %s for order %s", w.Sum, login, number)
+	return c.JSON(http.StatusOK, w)
+}
+
+// UserHoldVoid releases held points.
+func (s *Server) UserHoldVoid(c echo.Context) error {
+	login, number, err := s.holdParams(c)
+	if err != nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	h, err := s.db.VoidHold(c.Request().Context(), login, number, time.Now())
+	if err != nil {
+		return holdError(c, err)
+	}
+	logger.Logger.Infof("Void hold %s of %s for order %s", h.Sum, login, number)
+	return c.JSON(http.StatusOK, h)
+}
+
+func (s *Server) holdParams(c echo.Context) (string, order.OrderNumber, error) {
+	login, err := auth.GetLogin(c)
+	if err != nil {
+		return "", "", err
+	}
+	number, err := s.parser.Parse(c.Param("number"))
+	if err != nil {
+		return "", "", err
+	}
+	return login, number, nil
+}
+
+func holdError(c echo.Context, err error) error {
+	logger.Logger.Error(err)
+	switch {
+	case errors.Is(err, db.ErrHoldNotFound):
+		return c.String(http.StatusNotFound, err.Error())
+	case errors.Is(err, order.ErrHoldClosed), errors.Is(err, db.ErrWithdrawAdded):
+		return c.String(http.StatusConflict, err.Error())
+	case errors.Is(err, order.ErrHoldExpired):
+		return c.String(http.StatusGone, err.Error())
+	case errors.Is(err, db.ErrNotEnoughBalance):
+		return c.String(http.StatusPaymentRequired, err.Error())
+	}
+	var v *policy.Violation
+	if errors.As(err, &v) {
+		return c.JSON(http.StatusUnprocessableEntity, v)
+	}
+	return c.String(http.StatusInternalServerError, err.Error())
+}
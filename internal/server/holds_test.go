@@ -0,0 +1,116 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nexadis/gophmart/internal/db"
+	"github.com/Nexadis/gophmart/internal/money"
+	"github.com/Nexadis/gophmart/internal/order"
+	"github.com/Nexadis/gophmart/internal/policy"
+	"github.com/Nexadis/gophmart/mocks"
+)
+
+var testsUserBalanceHold = []struct {
+	name   string
+	body   string
+	err    error
+	status int
+}{
+	{"Hold points", `{"order":"2377225624","sum":5}`, nil, http.StatusOK},
+	{"Held points aren't available", `{"order":"2377225624","sum":5}`, db.ErrNotEnoughBalance, http.StatusPaymentRequired},
+	{"Daily cap", `{"order":"2377225624","sum":5}`, &policy.Violation{Code: policy.CodeDailyCap}, http.StatusUnprocessableEntity},
+	{"Order is held", `{"order":"2377225624","sum":5}`, db.ErrHoldAdded, http.StatusConflict},
+	{"Invalid order", `{"order":"2377225625","sum":5}`, nil, http.StatusUnprocessableEntity},
+}
+
+func TestUserBalanceHold(t *testing.T) {
+	s := newTestServer()
+	s.config.HoldTTL = time.Minute
+	s.config.WithdrawDailyCap = 1000
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockDatabase(ctrl)
+	s.db = mockdb
+	for _, test := range testsUserBalanceHold {
+		t.Run(test.name, func(t *testing.T) {
+			if test.status == http.StatusOK || test.err != nil {
+				mockdb.EXPECT().AddHold(gomock.Any(), gomock.Any(), s.config.WithdrawPolicy()).DoAndReturn(
+					func(_ any, h *order.Hold, _ policy.Withdraw) error {
+						assert.Equal(t, order.HoldActive, h.Status)
+						assert.Equal(t, money.Amount(500), h.Sum)
+						assert.Equal(t, time.Minute, h.ExpiresAt.Sub(h.CreatedAt))
+						return test.err
+					})
+			}
+			req := httptest.NewRequest(http.MethodPost, APIRestricted+APIUserBalanceHold, strings.NewReader(test.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := s.e.NewContext(req, rec)
+			setLogin(c, defaultUser.Login)
+			if assert.NoError(t, s.UserBalanceHold(c)) {
+				assert.Equal(t, test.status, rec.Code)
+			}
+		})
+	}
+}
+
+var testsUserHoldClose = []struct {
+	name    string
+	capture bool
+	err     error
+	status  int
+}{
+	{"Capture", true, nil, http.StatusOK},
+	{"Capture expired", true, order.ErrHoldExpired, http.StatusGone},
+	{"Capture voided", true, order.ErrHoldClosed, http.StatusConflict},
+	{"Capture expired points", true, db.ErrNotEnoughBalance, http.StatusPaymentRequired},
+	{"Capture over daily cap", true, &policy.Violation{Code: policy.CodeDailyCap}, http.StatusUnprocessableEntity},
+	{"Void", false, nil, http.StatusOK},
+	{"Void not found", false, db.ErrHoldNotFound, http.StatusNotFound},
+}
+
+func TestUserHoldClose(t *testing.T) {
+	s := newTestServer()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockDatabase(ctrl)
+	s.db = mockdb
+	number := order.OrderNumber("2377225624")
+	for _, test := range testsUserHoldClose {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			rec := httptest.NewRecorder()
+			c := s.e.NewContext(req, rec)
+			c.SetParamNames("number")
+			c.SetParamValues(string(number))
+			setLogin(c, defaultUser.Login)
+			var err error
+			if test.capture {
+				var w *order.Withdraw
+				if test.err == nil {
+					w = &order.Withdraw{Order: number, Sum: 500, Status: order.WithdrawCompleted}
+				}
+				mockdb.EXPECT().CaptureHold(gomock.Any(), defaultUser.Login, number, gomock.Any(), s.config.WithdrawPolicy()).Return(w, test.err)
+				err = s.UserHoldCapture(c)
+			} else {
+				var h *order.Hold
+				if test.err == nil {
+					h = &order.Hold{Order: number, Sum: 500, Status: order.HoldVoided}
+				}
+				mockdb.EXPECT().VoidHold(gomock.Any(), defaultUser.Login, number, gomock.Any()).Return(h, test.err)
+				err = s.UserHoldVoid(c)
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.status, rec.Code)
+			}
+		})
+	}
+}
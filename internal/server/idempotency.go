@@ -0,0 +1,104 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Nexadis/gophmart/internal/db"
+	"github.com/Nexadis/gophmart/internal/idempotency"
+	"github.com/Nexadis/gophmart/internal/logger"
+	"github.com/Nexadis/gophmart/internal/server/auth"
+)
+
+const (
+	HeaderIdempotencyKey = "Idempotency-Key"
+	HeaderReplayed       = "Idempotent-Replayed"
+)
+
+// recorder copies response to replay it for retries.
+type recorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// idempotent saves response of POST request with Idempotency-Key and replays it
+// for requests with the same key, method, path, content type and body.
+func (s *Server) idempotent(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		key := req.Header.Get(HeaderIdempotencyKey)
+		if req.Method != http.MethodPost || key == "" || s.config.IdempotencyTTL <= 0 {
+			return next(c)
+		}
+		if len(key) > idempotency.MaxKeyLen {
+			return c.String(http.StatusBadRequest, "idempotency key is too long")
+		}
+		login, err := auth.GetLogin(c)
+		if err != nil {
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return c.String(http.StatusBadRequest, InvalidReq)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		r := &idempotency.Record{
+			Owner:       login,
+			Key:         key,
+			Hash:        idempotency.Hash(req.Method, req.URL.Path, req.Header.Get(echo.HeaderContentType), body),
+			LockedUntil: time.Now().Add(s.config.IdempotencyLease),
+		}
+		saved, err := s.db.BeginIdempotent(req.Context(), r, s.config.IdempotencyTTL)
+		if errors.Is(err, db.ErrKeyReused) {
+			return c.String(http.StatusUnprocessableEntity, err.Error())
+		}
+		if err != nil {
+			logger.Logger.Error(err)
+			return c.NoContent(http.StatusInternalServerError)
+		}
+		if saved != nil {
+			if saved.InProgress() {
+				if wait := time.Until(saved.LockedUntil); wait > 0 {
+					c.Response().Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+				}
+				return c.String(http.StatusConflict, "request with this idempotency key is in progress")
+			}
+			logger.Logger.Infof("Replay response for key %q of %s", key, login)
+			c.Response().Header().Set(HeaderReplayed, "true")
+			return c.Blob(saved.Status, saved.ContentType, saved.Body)
+		}
+
+		rec := &recorder{ResponseWriter: c.Response().Writer}
+		c.Response().Writer = rec
+		err = next(c)
+		c.Response().Writer = rec.ResponseWriter
+		status := c.Response().Status
+		if err != nil || status >= http.StatusInternalServerError {
+			// failed request may be retried with the same key
+			if err := s.db.DeleteIdempotent(req.Context(), login, key); err != nil {
+				logger.Logger.Error(err)
+			}
+			return err
+		}
+		r.Status = status
+		r.ContentType = c.Response().Header().Get(echo.HeaderContentType)
+		r.Body = rec.body.Bytes()
+		if err := s.db.SaveIdempotent(req.Context(), r); err != nil {
+			logger.Logger.Error(err)
+		}
+		return nil
+	}
+}
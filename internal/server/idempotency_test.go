@@ -0,0 +1,88 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nexadis/gophmart/internal/db"
+	"github.com/Nexadis/gophmart/internal/idempotency"
+	"github.com/Nexadis/gophmart/internal/server/auth"
+	"github.com/Nexadis/gophmart/mocks"
+)
+
+var testsIdempotent = []struct {
+	name     string
+	key      string
+	saved    *idempotency.Record
+	err      error
+	status   int
+	replayed bool
+}{
+	{"First request", "key-1", nil, nil, http.StatusAccepted, false},
+	{"Replay saved response", "key-1", &idempotency.Record{Status: http.StatusAccepted}, nil, http.StatusAccepted, true},
+	{"First request in progress", "key-1", &idempotency.Record{}, nil, http.StatusConflict, false},
+	{"Key used for other request", "key-1", nil, db.ErrKeyReused, http.StatusUnprocessableEntity, false},
+	{"Without key", "", nil, nil, http.StatusAccepted, false},
+}
+
+func TestIdempotent(t *testing.T) {
+	s := newTestServer()
+	s.config.IdempotencyTTL = time.Hour
+	s.config.IdempotencyLease = time.Minute
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockDatabase(ctrl)
+	s.db = mockdb
+	token, err := auth.NewToken(defaultUser.Login, JwtSecret)
+	assert.NoError(t, err)
+	number := "445084503850"
+	hash := idempotency.Hash(http.MethodPost, APIRestricted+APIUserOrders, echo.MIMETextPlain, []byte(number))
+	for _, test := range testsIdempotent {
+		t.Run(test.name, func(t *testing.T) {
+			if test.key != "" {
+				mockdb.EXPECT().BeginIdempotent(gomock.Any(), gomock.Any(), time.Hour).DoAndReturn(
+					func(_ any, r *idempotency.Record, _ time.Duration) (*idempotency.Record, error) {
+						assert.Equal(t, defaultUser.Login, r.Owner)
+						assert.Equal(t, test.key, r.Key)
+						assert.Equal(t, hash, r.Hash)
+						assert.WithinDuration(t, time.Now().Add(time.Minute), r.LockedUntil, time.Second)
+						return test.saved, test.err
+					})
+			}
+			if test.saved == nil && test.err == nil {
+				mockdb.EXPECT().AddOrder(gomock.Any(), gomock.Any()).Return(nil)
+			}
+			if test.key != "" && test.saved == nil && test.err == nil {
+				mockdb.EXPECT().SaveIdempotent(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ any, r *idempotency.Record) error {
+						assert.Equal(t, test.status, r.Status)
+						return nil
+					})
+			}
+			req := httptest.NewRequest(http.MethodPost, APIRestricted+APIUserOrders, strings.NewReader(number))
+			req.Header.Set(echo.HeaderContentType, echo.MIMETextPlain)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+			if test.key != "" {
+				req.Header.Set(HeaderIdempotencyKey, test.key)
+			}
+			rec := httptest.NewRecorder()
+			s.e.ServeHTTP(rec, req)
+			assert.Equal(t, test.status, rec.Code)
+			assert.Equal(t, test.replayed, rec.Header().Get(HeaderReplayed) == "true")
+		})
+	}
+}
+
+func TestHashContentType(t *testing.T) {
+	body := []byte(`["445084503850"]`)
+	assert.NotEqual(t,
+		idempotency.Hash(http.MethodPost, APIRestricted+APIUserOrdersBatch, echo.MIMEApplicationJSON, body),
+		idempotency.Hash(http.MethodPost, APIRestricted+APIUserOrdersBatch, "text/csv", body))
+}
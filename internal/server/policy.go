@@ -0,0 +1,23 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Nexadis/gophmart/internal/db"
+	"github.com/Nexadis/gophmart/internal/policy"
+)
+
+// withdrawError responds with code of violated rule or with 402 if balance isn't enough.
+func withdrawError(c echo.Context, err error) error {
+	var v *policy.Violation
+	if errors.As(err, &v) {
+		return c.JSON(http.StatusUnprocessableEntity, v)
+	}
+	if errors.Is(err, db.ErrNotEnoughBalance) {
+		return c.String(http.StatusPaymentRequired, err.Error())
+	}
+	return c.String(http.StatusInternalServerError, err.Error())
+}
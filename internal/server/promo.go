@@ -0,0 +1,76 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Nexadis/gophmart/internal/db"
+	"github.com/Nexadis/gophmart/internal/logger"
+	"github.com/Nexadis/gophmart/internal/promo"
+	"github.com/Nexadis/gophmart/internal/server/auth"
+	"github.com/Nexadis/gophmart/internal/shortcode"
+)
+
+type promoRequest struct {
+	Code string `json:"code"`
+}
+
+// UserPromoRedeem credits points of promo code to the user.
+func (s *Server) UserPromoRedeem(c echo.Context) error {
+	login, err := auth.GetLogin(c)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	r := &promoRequest{}
+	if err := c.Bind(r); err != nil {
+		return c.String(http.StatusBadRequest, InvalidReq)
+	}
+	code := shortcode.Normalize(r.Code)
+	if code == "" {
+		return c.String(http.StatusBadRequest, InvalidReq)
+	}
+	redemption, err := s.db.RedeemPromo(c.Request().Context(), code, login, time.Now())
+	if err != nil {
+		logger.Logger.Infof("Can't redeem promo code for %s: %s", login, err)
+		switch {
+		case errors.Is(err, db.ErrPromoNotFound):
+			return c.String(http.StatusNotFound, err.Error())
+		case errors.Is(err, db.ErrPromoRedeemed):
+			return c.String(http.StatusConflict, err.Error())
+		case errors.Is(err, promo.ErrExpired), errors.Is(err, promo.ErrExhausted):
+			return c.String(http.StatusGone, err.Error())
+		}
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	logger.Logger.Infof("Promo code %s redeemed by %s for %s", code, login, redemption.Amount)
+	return c.JSON(http.StatusOK, redemption)
+}
+
+// AdminPromoGenerate generates batch of unique promo codes.
+func (s *Server) AdminPromoGenerate(c echo.Context) error {
+	b := &promo.Batch{}
+	if err := c.Bind(b); err != nil {
+		return c.String(http.StatusBadRequest, InvalidReq)
+	}
+	if err := b.Validate(); err != nil {
+		return c.String(http.StatusUnprocessableEntity, err.Error())
+	}
+	codes, err := b.Generate(time.Now())
+	if err != nil {
+		logger.Logger.Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	err = s.db.AddPromoCodes(c.Request().Context(), codes)
+	if err != nil {
+		logger.Logger.Error(err)
+		if errors.Is(err, db.ErrPromoAdded) {
+			return c.String(http.StatusConflict, err.Error())
+		}
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	logger.Logger.Infof("Generated %d promo codes for %s", len(codes), b.Amount)
+	return c.JSON(http.StatusCreated, codes)
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nexadis/gophmart/internal/db"
+	"github.com/Nexadis/gophmart/internal/promo"
+	"github.com/Nexadis/gophmart/mocks"
+)
+
+var testsUserPromoRedeem = []struct {
+	name   string
+	body   string
+	err    error
+	status int
+}{
+	{"Redeem", `{"code":" summer2345 "}`, nil, http.StatusOK},
+	{"Not found", `{"code":"SUMMER2345"}`, db.ErrPromoNotFound, http.StatusNotFound},
+	{"Redeemed by user", `{"code":"SUMMER2345"}`, db.ErrPromoRedeemed, http.StatusConflict},
+	{"Expired", `{"code":"SUMMER2345"}`, promo.ErrExpired, http.StatusGone},
+	{"Exhausted", `{"code":"SUMMER2345"}`, promo.ErrExhausted, http.StatusGone},
+	{"Empty code", `{"code":""}`, nil, http.StatusBadRequest},
+}
+
+func TestUserPromoRedeem(t *testing.T) {
+	s := newTestServer()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockDatabase(ctrl)
+	s.db = mockdb
+	for _, test := range testsUserPromoRedeem {
+		t.Run(test.name, func(t *testing.T) {
+			if test.status != http.StatusBadRequest {
+				var r *promo.Redemption
+				if test.err == nil {
+					r = &promo.Redemption{Code: "SUMMER2345", Owner: defaultUser.Login, Amount: 1000}
+				}
+				mockdb.EXPECT().RedeemPromo(gomock.Any(), "SUMMER2345", defaultUser.Login, gomock.Any()).Return(r, test.err)
+			}
+			req := httptest.NewRequest(http.MethodPost, APIRestricted+APIUserPromo, strings.NewReader(test.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := s.e.NewContext(req, rec)
+			setLogin(c, defaultUser.Login)
+			if assert.NoError(t, s.UserPromoRedeem(c)) {
+				assert.Equal(t, test.status, rec.Code)
+			}
+		})
+	}
+}
+
+func TestAdminPromoGenerate(t *testing.T) {
+	s := newTestServer()
+	s.config.AdminToken = "admintoken"
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockDatabase(ctrl)
+	s.db = mockdb
+	mockdb.EXPECT().AddPromoCodes(gomock.Any(), gomock.Len(3)).DoAndReturn(
+		func(_ context.Context, codes []*promo.Code) error {
+			for _, c := range codes {
+				assert.True(t, strings.HasPrefix(c.Code, "GIFT"))
+				assert.Equal(t, 1, c.MaxUses)
+			}
+			return nil
+		})
+	body := `{"count":3,"prefix":"GIFT","amount":10,"max_uses":1,"expires_at":"` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`
+	req := httptest.NewRequest(http.MethodPost, APIAdmin+APIAdminPromo, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer admintoken")
+	rec := httptest.NewRecorder()
+	s.e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var codes []*promo.Code
+	if assert.NoError(t, json.NewDecoder(rec.Body).Decode(&codes)) {
+		assert.Len(t, codes, 3)
+	}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Nexadis/gophmart/internal/db"
+	"github.com/Nexadis/gophmart/internal/logger"
+	"github.com/Nexadis/gophmart/internal/order"
+	"github.com/Nexadis/gophmart/internal/referral"
+	"github.com/Nexadis/gophmart/internal/server/auth"
+	"github.com/Nexadis/gophmart/internal/shortcode"
+	"github.com/Nexadis/gophmart/internal/user"
+)
+
+// codeAttempts limits generation of referral code colliding with saved codes.
+const codeAttempts = 3
+
+type referralsResponse struct {
+	Code      string               `json:"code"`
+	Cap       int                  `json:"cap,omitempty"`
+	Referrals []*referral.Referral `json:"referrals"`
+}
+
+// UserReferrals returns referral code of the user and users registered with it.
+func (s *Server) UserReferrals(c echo.Context) error {
+	ctx := c.Request().Context()
+	login, err := auth.GetLogin(c)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	code, err := s.referralCode(ctx, login)
+	if err != nil {
+		logger.Logger.Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	referrals, err := s.db.GetReferrals(ctx, login)
+	if err != nil {
+		logger.Logger.Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, referralsResponse{
+		Code:      code,
+		Cap:       s.config.ReferralCap,
+		Referrals: referrals,
+	})
+}
+
+// referralCode returns code of the user, the code is created on first use.
+func (s *Server) referralCode(ctx context.Context, login string) (string, error) {
+	var err error
+	for i := 0; i < codeAttempts; i++ {
+		var code string
+		code, err = referral.NewCode()
+		if err != nil {
+			return "", err
+		}
+		code, err = s.db.SetReferralCode(ctx, login, code)
+		if !errors.Is(err, db.ErrReferralAdded) {
+			return code, err
+		}
+	}
+	return "", err
+}
+
+// newReferral checks the referral code sent at registration of the user.
+func (s *Server) newReferral(ctx context.Context, u *user.User) (*referral.Referral, error) {
+	referrer, err := s.db.GetReferrer(ctx, shortcode.Normalize(u.ReferralCode))
+	if err != nil {
+		return nil, err
+	}
+	count, err := s.db.CountReferrals(ctx, referrer)
+	if err != nil {
+		return nil, err
+	}
+	err = s.config.ReferralProgram().Check(referrer, u.Login, count)
+	if err != nil {
+		return nil, err
+	}
+	return &referral.Referral{
+		Referrer:  referrer,
+		Referee:   u.Login,
+		Status:    referral.StatusPending,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// referralError responds with status for error of referral code.
+func referralError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, db.ErrReferralNotFound),
+		errors.Is(err, referral.ErrSelfReferral),
+		errors.Is(err, referral.ErrCapReached):
+		return c.String(http.StatusUnprocessableEntity, err.Error())
+	default:
+		logger.Logger.Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+}
+
+// rewardReferral credits referral rewards for the first processed order of the referee.
+func (s *Server) rewardReferral(ctx context.Context, owner string, number order.OrderNumber, processedAt time.Time) error {
+	program := s.config.ReferralProgram()
+	if !program.Enabled() {
+		return nil
+	}
+	r, err := s.db.GetReferral(ctx, owner)
+	if err != nil {
+		if errors.Is(err, db.ErrReferralNotFound) {
+			return nil
+		}
+		return err
+	}
+	bonuses := program.Reward(r, number, processedAt)
+	if len(bonuses) == 0 {
+		return nil
+	}
+	err = s.db.RewardReferral(ctx, r, bonuses)
+	if err != nil {
+		return err
+	}
+	logger.Logger.Infof("Referral of %s by %s is rewarded for order %s", r.Referee, r.Referrer, number)
+	return nil
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nexadis/gophmart/internal/campaign"
+	"github.com/Nexadis/gophmart/internal/db"
+	"github.com/Nexadis/gophmart/internal/money"
+	"github.com/Nexadis/gophmart/internal/order"
+	"github.com/Nexadis/gophmart/internal/referral"
+	"github.com/Nexadis/gophmart/mocks"
+)
+
+var testsUserRegisterReferral = []struct {
+	name     string
+	code     string
+	referrer string
+	err      error
+	count    int
+	status   int
+}{
+	{"Register with code", "abcd2345", "alice", nil, 0, http.StatusOK},
+	{"Unknown code", "ABCD2345", "", db.ErrReferralNotFound, 0, http.StatusUnprocessableEntity},
+	{"Self referral", "ABCD2345", "bob", nil, 0, http.StatusUnprocessableEntity},
+	{"Cap reached", "ABCD2345", "alice", nil, 2, http.StatusUnprocessableEntity},
+}
+
+func TestUserRegisterReferral(t *testing.T) {
+	s := newTestServer()
+	s.config.ReferralCap = 2
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockDatabase(ctrl)
+	s.db = mockdb
+	for _, test := range testsUserRegisterReferral {
+		t.Run(test.name, func(t *testing.T) {
+			mockdb.EXPECT().GetReferrer(gomock.Any(), "ABCD2345").Return(test.referrer, test.err)
+			if test.err == nil {
+				mockdb.EXPECT().CountReferrals(gomock.Any(), test.referrer).Return(test.count, nil)
+			}
+			if test.status == http.StatusOK {
+				mockdb.EXPECT().AddUser(gomock.Any(), gomock.Any()).Return(nil)
+				mockdb.EXPECT().AddReferral(gomock.Any(), gomock.Any(), 2).DoAndReturn(
+					func(_ context.Context, r *referral.Referral, _ int) error {
+						assert.Equal(t, "alice", r.Referrer)
+						assert.Equal(t, "bob", r.Referee)
+						assert.Equal(t, referral.StatusPending, r.Status)
+						return nil
+					})
+			}
+			body := `{"login":"bob","password":"secretpassword","referral_code":"` + test.code + `"}`
+			req := httptest.NewRequest(http.MethodPost, APIUserRegister, strings.NewReader(body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := s.e.NewContext(req, rec)
+			if assert.NoError(t, s.UserRegister(c)) {
+				assert.Equal(t, test.status, rec.Code)
+			}
+		})
+	}
+}
+
+func TestUserReferrals(t *testing.T) {
+	s := newTestServer()
+	s.config.ReferralCap = 5
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockDatabase(ctrl)
+	s.db = mockdb
+	rewardedAt := time.Date(2023, time.June, 10, 12, 0, 0, 0, time.UTC)
+	gomock.InOrder(
+		mockdb.EXPECT().SetReferralCode(gomock.Any(), defaultUser.Login, gomock.Any()).Return("", db.ErrReferralAdded),
+		mockdb.EXPECT().SetReferralCode(gomock.Any(), defaultUser.Login, gomock.Any()).Return("ABCD2345", nil),
+	)
+	mockdb.EXPECT().GetReferrals(gomock.Any(), defaultUser.Login).Return([]*referral.Referral{
+		{Referrer: defaultUser.Login, Referee: "bob", Status: referral.StatusPending},
+		{Referrer: defaultUser.Login, Referee: "carol", Status: referral.StatusRewarded, Order: "2377225624", RewardedAt: &rewardedAt},
+	}, nil)
+	req := httptest.NewRequest(http.MethodGet, APIRestricted+APIUserReferrals, nil)
+	rec := httptest.NewRecorder()
+	c := s.e.NewContext(req, rec)
+	setLogin(c, defaultUser.Login)
+	if assert.NoError(t, s.UserReferrals(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		got := referralsResponse{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Equal(t, "ABCD2345", got.Code)
+		assert.Equal(t, 5, got.Cap)
+		if assert.Len(t, got.Referrals, 2) {
+			assert.Equal(t, "bob", got.Referrals[0].Referee)
+			assert.Equal(t, referral.StatusRewarded, got.Referrals[1].Status)
+		}
+	}
+}
+
+func TestRewardReferral(t *testing.T) {
+	s := newTestServer()
+	s.config.ReferrerReward = 50000
+	s.config.RefereeReward = 30000
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockDatabase(ctrl)
+	s.db = mockdb
+	accrual := money.Amount(1000)
+	processedAt := time.Date(2023, time.June, 10, 12, 0, 0, 0, time.UTC)
+	o := &order.Order{
+		Number:      "12345678903",
+		Owner:       "bob",
+		Status:      order.StatusProcessed,
+		Accrual:     &accrual,
+		ProcessedAt: &processedAt,
+	}
+	mockdb.EXPECT().GetReward(gomock.Any(), o.Number).Return(&campaign.Reward{Number: o.Number}, nil).Times(3)
+	mockdb.EXPECT().SetBonuses(gomock.Any(), gomock.Any(), gomock.Len(0)).Return(nil).Times(3)
+	gomock.InOrder(
+		mockdb.EXPECT().GetReferral(gomock.Any(), "bob").Return(&referral.Referral{
+			Referrer: "alice",
+			Referee:  "bob",
+			Status:   referral.StatusPending,
+		}, nil),
+		mockdb.EXPECT().RewardReferral(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, r *referral.Referral, bonuses []*order.Bonus) error {
+				assert.Equal(t, referral.StatusRewarded, r.Status)
+				assert.Equal(t, o.Number, r.Order)
+				got := map[string]money.Amount{}
+				for _, b := range bonuses {
+					got[b.Owner] = b.Amount
+				}
+				assert.Equal(t, map[string]money.Amount{"alice": 50000, "bob": 30000}, got)
+				return nil
+			}),
+		mockdb.EXPECT().GetReferral(gomock.Any(), "bob").Return(&referral.Referral{
+			Referrer: "alice",
+			Referee:  "bob",
+			Status:   referral.StatusRewarded,
+		}, nil),
+		mockdb.EXPECT().GetReferral(gomock.Any(), "bob").Return(nil, db.ErrReferralNotFound),
+	)
+	assert.NoError(t, s.Reward(context.Background(), o))
+	assert.NoError(t, s.Reward(context.Background(), o), "referral is rewarded once")
+	assert.NoError(t, s.Reward(context.Background(), o), "user isn't referred")
+}
+
+func TestRewardReferralWithoutAccrual(t *testing.T) {
+	s := newTestServer()
+	s.config.ReferrerReward = 50000
+	s.config.RefereeReward = 30000
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockDatabase(ctrl)
+	s.db = mockdb
+	processedAt := time.Date(2023, time.June, 10, 12, 0, 0, 0, time.UTC)
+	o := &order.Order{
+		Number:      "12345678903",
+		Owner:       "bob",
+		Status:      order.StatusProcessed,
+		ProcessedAt: &processedAt,
+	}
+	mockdb.EXPECT().GetReferral(gomock.Any(), "bob").Return(&referral.Referral{
+		Referrer: "alice",
+		Referee:  "bob",
+		Status:   referral.StatusPending,
+	}, nil)
+	mockdb.EXPECT().RewardReferral(gomock.Any(), gomock.Any(), gomock.Len(2)).Return(nil)
+	assert.NoError(t, s.Reward(context.Background(), o))
+}
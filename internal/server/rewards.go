@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Nexadis/gophmart/internal/campaign"
+	"github.com/Nexadis/gophmart/internal/db"
+	"github.com/Nexadis/gophmart/internal/logger"
+	"github.com/Nexadis/gophmart/internal/order"
+)
+
+// Reward credits bonuses of the owner's tier, of matching campaigns and of referral program for the PROCESSED order.
+// Bonuses of the tier and campaigns are replaced, so the order may be rewarded again after retry or revision,
+// then amounts are recomputed by the tier and campaigns of the first reward.
+func (s *Server) Reward(ctx context.Context, o *order.Order) error {
+	owner := o.Owner
+	processedAt := time.Now()
+	if o.ProcessedAt != nil {
+		processedAt = *o.ProcessedAt
+	}
+	if owner == "" || o.ProcessedAt == nil {
+		// processing time is set by the database
+		saved, err := s.db.GetOrder(ctx, o.Number)
+		if err != nil {
+			return err
+		}
+		owner = saved.Owner
+		if saved.ProcessedAt != nil {
+			processedAt = *saved.ProcessedAt
+		}
+	}
+	if o.Accrual == nil {
+		// referral is rewarded for processed order without accrual too
+		return s.rewardReferral(ctx, owner, o.Number, processedAt)
+	}
+
+	reward, err := s.db.GetReward(ctx, o.Number)
+	first := errors.Is(err, db.ErrRewardNotFound)
+	if first {
+		reward, err = s.firstReward(ctx, o.Number, owner)
+	}
+	if err != nil {
+		return err
+	}
+
+	var bonuses []*order.Bonus
+	if t := s.tiers.Find(reward.Tier); t != nil {
+		if bonus := t.Bonus(*o.Accrual); bonus > 0 {
+			bonuses = append(bonuses, &order.Bonus{
+				Number:    o.Number,
+				Owner:     owner,
+				Source:    SourceTier,
+				Amount:    bonus,
+				CreatedAt: processedAt,
+			})
+		}
+	}
+	if len(reward.Campaigns) > 0 {
+		index, err := s.db.CountProcessed(ctx, owner, processedAt)
+		if err != nil {
+			return err
+		}
+		matched := campaign.Evaluate(reward.Campaigns, campaign.Facts{
+			Number:      o.Number,
+			Owner:       owner,
+			Accrual:     *o.Accrual,
+			ProcessedAt: processedAt,
+			Index:       index,
+			Segment:     reward.Tier,
+		})
+		if first {
+			reward.Campaigns = rewardedBy(reward.Campaigns, matched)
+		}
+		bonuses = append(bonuses, matched...)
+	}
+
+	err = s.db.SetBonuses(ctx, reward, bonuses)
+	if err != nil {
+		return err
+	}
+	for _, b := range bonuses {
+		logger.Logger.Infof("Bonus %s of %s for order %s from %s", b.Amount, owner, o.Number, b.Source)
+	}
+	return s.rewardReferral(ctx, owner, o.Number, processedAt)
+}
+
+// firstReward returns current tier of the owner and active campaigns.
+func (s *Server) firstReward(ctx context.Context, number order.OrderNumber, owner string) (*campaign.Reward, error) {
+	reward := &campaign.Reward{Number: number}
+	if len(s.tiers) > 0 {
+		progress, err := s.tierProgress(ctx, owner)
+		if err != nil {
+			return nil, err
+		}
+		if progress.Tier != nil {
+			reward.Tier = progress.Tier.Name
+		}
+	}
+	campaigns, err := s.db.GetCampaigns(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reward.Campaigns = campaigns
+	return reward, nil
+}
+
+// rewardedBy returns campaigns which gave bonuses.
+func rewardedBy(campaigns []*campaign.Campaign, bonuses []*order.Bonus) []*campaign.Campaign {
+	sources := make(map[string]bool, len(bonuses))
+	for _, b := range bonuses {
+		sources[b.Source] = true
+	}
+	var used []*campaign.Campaign
+	for _, c := range campaigns {
+		if sources[c.Source()] {
+			used = append(used, c)
+		}
+	}
+	return used
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nexadis/gophmart/internal/campaign"
+	"github.com/Nexadis/gophmart/internal/db"
+	"github.com/Nexadis/gophmart/internal/money"
+	"github.com/Nexadis/gophmart/internal/order"
+)
+
+func TestReward(t *testing.T) {
+	s, mockdb := newTierServer(t)
+	accrual := money.Amount(1000)
+	processedAt := time.Date(2023, time.June, 10, 12, 0, 0, 0, time.UTC)
+	number := order.OrderNumber("12345678903")
+	mockdb.EXPECT().GetReward(gomock.Any(), number).Return(nil, db.ErrRewardNotFound)
+	mockdb.EXPECT().GetTier(gomock.Any(), defaultUser.Login).Return("Gold", nil)
+	mockdb.EXPECT().GetAccruedSince(gomock.Any(), defaultUser.Login, gomock.Any()).Return(money.Amount(500000), nil)
+	mockdb.EXPECT().GetCampaigns(gomock.Any()).Return([]*campaign.Campaign{
+		{ID: 1, Name: "First order", FirstOrders: 1, Fixed: 10000},
+		{ID: 2, Name: "Silver double", Segment: "Silver", Multiplier: 200},
+		{ID: 3, Name: "Gold double", Segment: "Gold", Multiplier: 200},
+	}, nil)
+	mockdb.EXPECT().CountProcessed(gomock.Any(), defaultUser.Login, processedAt).Return(1, nil)
+	bonuses := map[string]money.Amount{}
+	mockdb.EXPECT().SetBonuses(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, r *campaign.Reward, added []*order.Bonus) error {
+			assert.Equal(t, number, r.Number)
+			assert.Equal(t, "Gold", r.Tier)
+			var ids []int64
+			for _, c := range r.Campaigns {
+				ids = append(ids, c.ID)
+			}
+			assert.Equal(t, []int64{1, 3}, ids, "only campaigns giving bonuses are kept")
+			for _, b := range added {
+				assert.Equal(t, defaultUser.Login, b.Owner)
+				assert.Equal(t, processedAt, b.CreatedAt)
+				bonuses[b.Source] = b.Amount
+			}
+			return nil
+		})
+	err := s.Reward(context.Background(), &order.Order{
+		Number:      number,
+		Owner:       defaultUser.Login,
+		Status:      order.StatusProcessed,
+		Accrual:     &accrual,
+		ProcessedAt: &processedAt,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]money.Amount{
+		SourceTier:   250,
+		"campaign:1": 10000,
+		"campaign:3": 1000,
+	}, bonuses)
+}
+
+func TestRewardRevision(t *testing.T) {
+	s, mockdb := newTierServer(t)
+	accrual := money.Amount(2000)
+	processedAt := time.Date(2023, time.June, 10, 12, 0, 0, 0, time.UTC)
+	number := order.OrderNumber("12345678903")
+	// campaign 3 was deleted and user went down to Silver since the first reward
+	mockdb.EXPECT().GetReward(gomock.Any(), number).Return(&campaign.Reward{
+		Number: number,
+		Tier:   "Gold",
+		Campaigns: []*campaign.Campaign{
+			{ID: 1, Name: "First order", FirstOrders: 1, Fixed: 10000},
+			{ID: 3, Name: "Gold double", Segment: "Gold", Multiplier: 200},
+		},
+	}, nil)
+	mockdb.EXPECT().CountProcessed(gomock.Any(), defaultUser.Login, processedAt).Return(1, nil)
+	bonuses := map[string]money.Amount{}
+	mockdb.EXPECT().SetBonuses(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, r *campaign.Reward, added []*order.Bonus) error {
+			for _, b := range added {
+				bonuses[b.Source] = b.Amount
+			}
+			return nil
+		})
+	err := s.Reward(context.Background(), &order.Order{
+		Number:      number,
+		Owner:       defaultUser.Login,
+		Status:      order.StatusProcessed,
+		Accrual:     &accrual,
+		ProcessedAt: &processedAt,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]money.Amount{
+		SourceTier:   500,
+		"campaign:1": 10000,
+		"campaign:3": 2000,
+	}, bonuses)
+}
+
+func TestAdminCampaignAdd(t *testing.T) {
+	s, mockdb := newTierServer(t)
+	s.config.AdminToken = "admintoken"
+	mockdb.EXPECT().AddCampaign(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, c *campaign.Campaign) error {
+			assert.Equal(t, "Weekend", c.Name)
+			assert.Equal(t, 200, c.Multiplier)
+			c.ID = 1
+			return nil
+		})
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"name":"Weekend","starts_at":"2023-06-10T00:00:00Z","ends_at":"2023-06-12T00:00:00Z","multiplier":200}`, http.StatusCreated},
+		{`{"name":"Without bonus"}`, http.StatusUnprocessableEntity},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, APIAdmin+APIAdminCampaigns, strings.NewReader(test.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer admintoken")
+		rec := httptest.NewRecorder()
+		s.e.ServeHTTP(rec, req)
+		assert.Equal(t, test.status, rec.Code, test.body)
+	}
+}
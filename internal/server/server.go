@@ -1,9 +1,14 @@
 package server
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/hex"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	echojwt "github.com/labstack/echo-jwt/v4"
@@ -14,51 +19,156 @@ import (
 	"github.com/Nexadis/gophmart/internal/db"
 	"github.com/Nexadis/gophmart/internal/db/pg"
 	"github.com/Nexadis/gophmart/internal/logger"
+	"github.com/Nexadis/gophmart/internal/order"
+	"github.com/Nexadis/gophmart/internal/tier"
 )
 
 type Server struct {
-	e      *echo.Echo
-	config *Config
-	db     db.Database
+	e       *echo.Echo
+	config  *Config
+	db      db.Database
+	accrual *client.Client
+	tiers   tier.Tiers
+	// parser checks all order numbers entering the system.
+	parser order.NumberParser
 }
 
-const secretLen = 32
+const (
+	secretLen       = 32
+	shutdownTimeout = 10 * time.Second
+)
 
 var JwtSecret []byte
 
 func New(config *Config) (*Server, error) {
+	parser, err := config.NumberParser()
+	if err != nil {
+		return nil, err
+	}
+	routes, err := config.Routes()
+	if err != nil {
+		return nil, err
+	}
+	tiers, err := config.ParseTiers()
+	if err != nil {
+		return nil, err
+	}
 	e := echo.New()
 	db := pg.New()
-	err := db.Open(config.DBURI)
+	err = db.Open(config.DBURI)
 	if err != nil {
 		logger.Logger.Infoln(`can't connect to DB`)
 		return nil, err
 	}
-	return &Server{
+	s := &Server{
 		e:      e,
 		config: config,
 		db:     db,
-	}, nil
+		tiers:  tiers,
+		parser: parser,
+	}
+	s.accrual, err = client.New(client.Config{
+		Addr:   config.AccrualSystemAddress,
+		Routes: routes,
+		HTTP: client.HTTPConfig{
+			Timeout:   config.AccrualTimeout,
+			CAFile:    config.AccrualCAFile,
+			CertFile:  config.AccrualCertFile,
+			KeyFile:   config.AccrualKeyFile,
+			BasePath:  config.AccrualBasePath,
+			UserAgent: config.AccrualUserAgent,
+			Debug:     config.AccrualDebug,
+		},
+		Wait:      time.Duration(config.Wait) * time.Second,
+		BatchSize: config.AccrualBatch,
+		Retry: client.RetryConfig{
+			BaseDelay:        config.RetryBaseDelay,
+			MaxDelay:         config.RetryMaxDelay,
+			BreakerThreshold: config.BreakerThreshold,
+			BreakerTimeout:   config.BreakerTimeout,
+		},
+		GiveUp: client.GiveUpConfig{
+			MaxAttempts: config.AccrualMaxAttempts,
+			MaxAge:      config.AccrualMaxAge,
+		},
+		Revision: client.RevisionConfig{
+			Window:   config.AccrualRevisionWindow,
+			Interval: config.AccrualRevisionInterval,
+		},
+		Rewarder: s,
+		Parser:   parser,
+	}, db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
 }
 
+// Run serves API and checks accruals until the server fails or gets SIGINT or SIGTERM.
 func (s *Server) Run() error {
 	prepareServer(s)
-	errors := make(chan error)
-	done := make(chan struct{})
-	client := client.New(s.config.AccrualSystemAddress, s.db, time.Duration(s.config.Wait)*time.Second)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
 	go func() {
-		client.GetAccruals(done, errors)
+		s.accrual.GetAccruals(ctx)
 		wg.Done()
 	}()
-	err := s.e.Start(s.config.RunAddress)
-	close(done)
+	wg.Add(1)
+	go func() {
+		s.listenOrders(ctx)
+		wg.Done()
+	}()
+	if len(s.tiers) > 0 {
+		wg.Add(1)
+		go func() {
+			s.recalcTiers(ctx)
+			wg.Done()
+		}()
+	}
+	if s.config.ExpiryPolicy().Enabled() {
+		wg.Add(1)
+		go func() {
+			s.expirePoints(ctx)
+			wg.Done()
+		}()
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.e.Start(s.config.RunAddress)
+	}()
+	var err error
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+		logger.Logger.Infoln("Shutdown server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err = s.e.Shutdown(shutdownCtx)
+	}
+	stop()
 	wg.Wait()
-	close(errors)
 	return err
 }
 
+// listenOrders passes orders uploaded through other instances to the accrual client.
+func (s *Server) listenOrders(ctx context.Context) {
+	wait := time.Duration(s.config.Wait) * time.Second
+	for {
+		err := s.db.ListenOrders(ctx, s.accrual.Notify)
+		if err != nil {
+			s.accrual.ReportError(client.StageListen, "", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
 func prepareServer(s *Server) {
 	JwtSecret = []byte(s.config.JwtSecret)
 	if s.config.JwtSecret == "" {
@@ -75,13 +185,44 @@ func (s *Server) MountHandlers() {
 	s.e.Use(middleware.Gzip())
 	s.e.POST(APIUserRegister, s.UserRegister)
 	s.e.POST(APIUserLogin, s.UserLogin)
+	s.e.POST(APIInternalAccruals, s.InternalAccruals)
 	r := s.e.Group(APIRestricted)
 	{
 		r.Use(echojwt.JWT(JwtSecret))
+		r.Use(s.idempotent)
 		r.POST(APIUserOrders, s.UserOrdersSave)
+		r.POST(APIUserOrdersBatch, s.UserOrdersBatch)
 		r.GET(APIUserOrders, s.UserOrdersGet)
+		r.GET(APIUserOrder, s.UserOrderGet)
 		r.GET(APIUserBalance, s.UserBalance)
 		r.POST(APIUserBalanceWithdraw, s.UserBalanceWithdraw)
 		r.GET(APIUserWithdrawals, s.UserWithdrawals)
+		r.POST(APIUserBalanceHold, s.UserBalanceHold)
+		r.POST(APIUserHoldCapture, s.UserHoldCapture)
+		r.POST(APIUserHoldVoid, s.UserHoldVoid)
+		r.GET(APIUserTier, s.UserTier)
+		r.POST(APIUserPromo, s.UserPromoRedeem)
+		r.POST(APIUserBalanceTransfer, s.UserBalanceTransfer)
+		r.GET(APIUserTransfers, s.UserTransfers)
+		r.GET(APIUserReferrals, s.UserReferrals)
+	}
+	a := s.e.Group(APIAdmin)
+	{
+		a.Use(middleware.KeyAuth(s.isAdminToken))
+		a.POST(APIAdminOrderRequeue, s.AdminOrderRequeue)
+		a.POST(APIAdminWithdrawRefund, s.AdminWithdrawRefund)
+		a.POST(APIAdminWithdrawCancel, s.AdminWithdrawCancel)
+		a.POST(APIAdminCampaigns, s.AdminCampaignAdd)
+		a.GET(APIAdminCampaigns, s.AdminCampaigns)
+		a.DELETE(APIAdminCampaign, s.AdminCampaignDelete)
+		a.POST(APIAdminPromo, s.AdminPromoGenerate)
+		a.GET(APIAdminAccrualStatus, s.AccrualStatus)
+	}
+}
+
+func (s *Server) isAdminToken(key string, c echo.Context) (bool, error) {
+	if s.config.AdminToken == "" {
+		return false, nil
 	}
+	return subtle.ConstantTimeCompare([]byte(key), []byte(s.config.AdminToken)) == 1, nil
 }
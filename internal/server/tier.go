@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Nexadis/gophmart/internal/db"
+	"github.com/Nexadis/gophmart/internal/logger"
+	"github.com/Nexadis/gophmart/internal/money"
+	"github.com/Nexadis/gophmart/internal/server/auth"
+	"github.com/Nexadis/gophmart/internal/tier"
+)
+
+// SourceTier is source of bonuses given by tier multiplier.
+const SourceTier = `tier`
+
+// UserTier returns tier of the user and progress to the next tier.
+func (s *Server) UserTier(c echo.Context) error {
+	if len(s.tiers) == 0 {
+		return c.String(http.StatusNotFound, "tiers are disabled")
+	}
+	login, err := auth.GetLogin(c)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	progress, err := s.tierProgress(c.Request().Context(), login)
+	if err != nil {
+		logger.Logger.Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, progress)
+}
+
+// recalcTiers assigns tiers of users by their points until ctx is done.
+func (s *Server) recalcTiers(ctx context.Context) {
+	ticker := time.NewTicker(s.config.TierInterval)
+	defer ticker.Stop()
+	for {
+		err := s.recalcTiersOnce(ctx)
+		if err != nil {
+			logger.Logger.Errorf("Can't recalculate tiers: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) recalcTiersOnce(ctx context.Context) error {
+	logins, err := s.db.GetLogins(ctx)
+	if err != nil {
+		return err
+	}
+	for _, login := range logins {
+		assigned, err := s.db.GetTier(ctx, login)
+		if err != nil {
+			return err
+		}
+		points, err := s.tierPoints(ctx, login)
+		if err != nil {
+			return err
+		}
+		t := s.tiers.For(points)
+		if t == nil || t.Name == assigned {
+			continue
+		}
+		err = s.db.SetTier(ctx, login, t.Name)
+		if err != nil {
+			return err
+		}
+		logger.Logger.Infof("Tier of %s is %s instead of %q", login, t.Name, assigned)
+	}
+	return nil
+}
+
+func (s *Server) tierProgress(ctx context.Context, login string) (tier.Progress, error) {
+	assigned, err := s.db.GetTier(ctx, login)
+	if err != nil && !errors.Is(err, db.ErrUserNotFound) {
+		return tier.Progress{}, err
+	}
+	points, err := s.tierPoints(ctx, login)
+	if err != nil {
+		return tier.Progress{}, err
+	}
+	return s.tiers.Progress(assigned, points), nil
+}
+
+// tierPoints returns points accrued or spent by the user in rolling tier window.
+func (s *Server) tierPoints(ctx context.Context, login string) (money.Amount, error) {
+	since := time.Now().Add(-s.config.TierWindow)
+	if s.config.TierBasis == tier.BasisSpent {
+		return s.db.GetWithdrawnSince(ctx, login, since)
+	}
+	return s.db.GetAccruedSince(ctx, login, since)
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nexadis/gophmart/internal/money"
+	"github.com/Nexadis/gophmart/internal/tier"
+	"github.com/Nexadis/gophmart/mocks"
+)
+
+var testTiers = tier.Tiers{
+	{Name: "Bronze", Threshold: 0, Multiplier: 100},
+	{Name: "Silver", Threshold: 100000, Multiplier: 110},
+	{Name: "Gold", Threshold: 500000, Multiplier: 125},
+}
+
+func newTierServer(t *testing.T) (*Server, *mocks.MockDatabase) {
+	s := newTestServer()
+	s.tiers = testTiers
+	s.config.TierWindow = time.Hour
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+	mockdb := mocks.NewMockDatabase(ctrl)
+	s.db = mockdb
+	return s, mockdb
+}
+
+func TestUserTier(t *testing.T) {
+	s, mockdb := newTierServer(t)
+	mockdb.EXPECT().GetTier(gomock.Any(), defaultUser.Login).Return("Silver", nil)
+	mockdb.EXPECT().GetAccruedSince(gomock.Any(), defaultUser.Login, gomock.Any()).Return(money.Amount(200000), nil)
+
+	req := httptest.NewRequest(http.MethodGet, APIRestricted+APIUserTier, nil)
+	rec := httptest.NewRecorder()
+	c := s.e.NewContext(req, rec)
+	setLogin(c, defaultUser.Login)
+	if assert.NoError(t, s.UserTier(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	progress := &tier.Progress{}
+	if assert.NoError(t, json.NewDecoder(rec.Body).Decode(progress)) {
+		assert.Equal(t, "Silver", progress.Tier.Name)
+		assert.Equal(t, "Gold", progress.Next.Name)
+		assert.Equal(t, money.Amount(300000), progress.ToNext)
+	}
+}
+
+func TestUserTierDisabled(t *testing.T) {
+	s := newTestServer()
+	req := httptest.NewRequest(http.MethodGet, APIRestricted+APIUserTier, nil)
+	rec := httptest.NewRecorder()
+	c := s.e.NewContext(req, rec)
+	setLogin(c, defaultUser.Login)
+	if assert.NoError(t, s.UserTier(c)) {
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestRecalcTiers(t *testing.T) {
+	s, mockdb := newTierServer(t)
+	mockdb.EXPECT().GetLogins(gomock.Any()).Return([]string{defaultUser.Login, otherUser.Login}, nil)
+	mockdb.EXPECT().GetTier(gomock.Any(), defaultUser.Login).Return("Bronze", nil)
+	mockdb.EXPECT().GetAccruedSince(gomock.Any(), defaultUser.Login, gomock.Any()).Return(money.Amount(150000), nil)
+	mockdb.EXPECT().SetTier(gomock.Any(), defaultUser.Login, "Silver").Return(nil)
+	mockdb.EXPECT().GetTier(gomock.Any(), otherUser.Login).Return("Bronze", nil)
+	mockdb.EXPECT().GetAccruedSince(gomock.Any(), otherUser.Login, gomock.Any()).Return(money.Amount(100), nil)
+	assert.NoError(t, s.recalcTiersOnce(context.Background()))
+}
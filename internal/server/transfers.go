@@ -0,0 +1,80 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Nexadis/gophmart/internal/db"
+	"github.com/Nexadis/gophmart/internal/logger"
+	"github.com/Nexadis/gophmart/internal/server/auth"
+	"github.com/Nexadis/gophmart/internal/user"
+)
+
+type transferEntry struct {
+	*user.Transfer
+	Direction string `json:"direction"`
+}
+
+// UserBalanceTransfer moves points of the user to other user.
+func (s *Server) UserBalanceTransfer(c echo.Context) error {
+	req := c.Request()
+	login, err := auth.GetLogin(c)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	t := &user.Transfer{}
+	if err := c.Bind(t); err != nil {
+		return c.String(http.StatusBadRequest, InvalidReq)
+	}
+	t.ID = 0
+	t.From = login
+	t.CreatedAt = time.Now()
+	if t.To == "" {
+		return c.String(http.StatusBadRequest, InvalidReq)
+	}
+	if t.To == login {
+		return c.String(http.StatusUnprocessableEntity, user.ErrSelfTransfer.Error())
+	}
+	_, err = s.db.GetUser(req.Context(), t.To)
+	if err != nil {
+		if errors.Is(err, db.ErrUserNotFound) {
+			return c.String(http.StatusNotFound, err.Error())
+		}
+		logger.Logger.Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	err = s.db.AddTransfer(req.Context(), t, s.config.TransferPolicy())
+	if err != nil {
+		logger.Logger.Error(err)
+		return withdrawError(c, err)
+	}
+	logger.Logger.Infof("Transfer %s from %s to %s", t.Sum, t.From, t.To)
+	return c.JSON(http.StatusOK, t)
+}
+
+// UserTransfers returns incoming and outgoing transfers of the user.
+func (s *Server) UserTransfers(c echo.Context) error {
+	login, err := auth.GetLogin(c)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	transfers, err := s.db.GetTransfers(c.Request().Context(), login)
+	if err != nil {
+		logger.Logger.Error(err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	if len(transfers) == 0 {
+		return c.NoContent(http.StatusNoContent)
+	}
+	entries := make([]transferEntry, 0, len(transfers))
+	for _, t := range transfers {
+		entries = append(entries, transferEntry{
+			Transfer:  t,
+			Direction: t.Direction(login),
+		})
+	}
+	return c.JSON(http.StatusOK, entries)
+}
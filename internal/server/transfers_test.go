@@ -0,0 +1,99 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nexadis/gophmart/internal/db"
+	"github.com/Nexadis/gophmart/internal/money"
+	"github.com/Nexadis/gophmart/internal/policy"
+	"github.com/Nexadis/gophmart/internal/user"
+	"github.com/Nexadis/gophmart/mocks"
+)
+
+var testsUserBalanceTransfer = []struct {
+	name      string
+	body      string
+	recipient error
+	err       error
+	status    int
+}{
+	{"Transfer points", `{"to":"bob","sum":5}`, nil, nil, http.StatusOK},
+	{"Not enough balance", `{"to":"bob","sum":5}`, nil, db.ErrNotEnoughBalance, http.StatusPaymentRequired},
+	{"Daily cap exceeded", `{"to":"bob","sum":5}`, nil, &policy.Violation{Code: policy.CodeDailyCap}, http.StatusUnprocessableEntity},
+	{"Unknown recipient", `{"to":"bob","sum":5}`, db.ErrUserNotFound, nil, http.StatusNotFound},
+	{"Self transfer", `{"to":"` + defaultUser.Login + `","sum":5}`, nil, nil, http.StatusUnprocessableEntity},
+	{"No recipient", `{"sum":5}`, nil, nil, http.StatusBadRequest},
+}
+
+func TestUserBalanceTransfer(t *testing.T) {
+	s := newTestServer()
+	s.config.TransferMin = 100
+	s.config.TransferDailyCap = 2000
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockDatabase(ctrl)
+	s.db = mockdb
+	for _, test := range testsUserBalanceTransfer {
+		t.Run(test.name, func(t *testing.T) {
+			tr := &user.Transfer{}
+			assert.NoError(t, json.Unmarshal([]byte(test.body), tr))
+			if tr.To != "" && tr.To != defaultUser.Login {
+				mockdb.EXPECT().GetUser(gomock.Any(), tr.To).Return(&user.User{Login: tr.To}, test.recipient)
+			}
+			if test.recipient == nil && tr.To != "" && tr.To != defaultUser.Login {
+				mockdb.EXPECT().AddTransfer(gomock.Any(), gomock.Any(), s.config.TransferPolicy()).DoAndReturn(
+					func(_ any, tr *user.Transfer, _ policy.Withdraw) error {
+						assert.Equal(t, defaultUser.Login, tr.From)
+						assert.Equal(t, "bob", tr.To)
+						assert.Equal(t, money.Amount(500), tr.Sum)
+						tr.ID = 1
+						return test.err
+					})
+			}
+			req := httptest.NewRequest(http.MethodPost, APIRestricted+APIUserBalanceTransfer, strings.NewReader(test.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := s.e.NewContext(req, rec)
+			setLogin(c, defaultUser.Login)
+			if assert.NoError(t, s.UserBalanceTransfer(c)) {
+				assert.Equal(t, test.status, rec.Code)
+			}
+		})
+	}
+}
+
+func TestUserTransfers(t *testing.T) {
+	s := newTestServer()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockDatabase(ctrl)
+	s.db = mockdb
+	mockdb.EXPECT().GetTransfers(gomock.Any(), defaultUser.Login).Return([]*user.Transfer{
+		{ID: 2, From: "bob", To: defaultUser.Login, Sum: 250},
+		{ID: 1, From: defaultUser.Login, To: "bob", Sum: 500},
+	}, nil)
+	req := httptest.NewRequest(http.MethodGet, APIRestricted+APIUserTransfers, nil)
+	rec := httptest.NewRecorder()
+	c := s.e.NewContext(req, rec)
+	setLogin(c, defaultUser.Login)
+	if assert.NoError(t, s.UserTransfers(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got []struct {
+			ID        int64  `json:"id"`
+			Direction string `json:"direction"`
+		}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+		if assert.Len(t, got, 2) {
+			assert.Equal(t, user.TransferIn, got[0].Direction)
+			assert.Equal(t, user.TransferOut, got[1].Direction)
+		}
+	}
+}
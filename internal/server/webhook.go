@@ -0,0 +1,106 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/Nexadis/gophmart/internal/client"
+	"github.com/Nexadis/gophmart/internal/db"
+	"github.com/Nexadis/gophmart/internal/logger"
+	"github.com/Nexadis/gophmart/internal/order"
+)
+
+const (
+	HeaderSignature = "X-Signature"
+	// HeaderTimestamp is unix seconds when accruals were sent, it is signed with body.
+	HeaderTimestamp = "X-Signature-Timestamp"
+)
+
+const (
+	ResultApplied   = `applied`
+	ResultUnchanged = `unchanged`
+	ResultNotFound  = `not_found`
+	ResultRejected  = `rejected`
+	ResultInvalid   = `invalid`
+)
+
+type accrualResult struct {
+	Order  order.OrderNumber `json:"order"`
+	Result string            `json:"result"`
+}
+
+// InternalAccruals accepts accrual or array of accruals pushed by accrual system.
+// Timestamp and body are signed with HMAC-SHA256 in hex by shared secret, requests with stale timestamp are rejected.
+func (s *Server) InternalAccruals(c echo.Context) error {
+	if s.config.WebhookSecret == "" || s.accrual == nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	req := c.Request()
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	defer req.Body.Close()
+	timestamp := req.Header.Get(HeaderTimestamp)
+	if !client.IsValidSign(timestamp, body, []byte(s.config.WebhookSecret), req.Header.Get(HeaderSignature)) {
+		return c.NoContent(http.StatusUnauthorized)
+	}
+	sentAt, err := client.ParseTimestamp(timestamp, time.Now(), s.config.WebhookTolerance)
+	if err != nil {
+		logger.Logger.Infoln(err)
+		return c.NoContent(http.StatusUnauthorized)
+	}
+	accruals, err := parseAccruals(body)
+	if err != nil {
+		return c.String(http.StatusBadRequest, InvalidReq)
+	}
+	results := make([]accrualResult, 0, len(accruals))
+	for _, a := range accruals {
+		r := accrualResult{
+			Order:  a.Order,
+			Result: ResultApplied,
+		}
+		changed, err := s.accrual.Apply(req.Context(), a, sentAt)
+		switch {
+		case err == nil && !changed:
+			r.Result = ResultUnchanged
+		case err == nil:
+		case errors.Is(err, order.ErrInvalidNum):
+			logger.Logger.Infoln(err)
+			r.Result = ResultInvalid
+		case errors.Is(err, db.ErrOrderNotFound):
+			r.Result = ResultNotFound
+		case errors.Is(err, client.ErrOrderFinal),
+			errors.Is(err, client.ErrUnknownStatus),
+			errors.Is(err, db.ErrStaleRevision),
+			errors.Is(err, order.ErrTransition):
+			r.Result = ResultRejected
+		default:
+			logger.Logger.Error(err)
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+		results = append(results, r)
+	}
+	return c.JSON(http.StatusOK, results)
+}
+
+func parseAccruals(body []byte) ([]client.Accrual, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var accruals []client.Accrual
+		err := json.Unmarshal(body, &accruals)
+		return accruals, err
+	}
+	a := client.Accrual{}
+	err := json.Unmarshal(body, &a)
+	if err != nil {
+		return nil, err
+	}
+	return []client.Accrual{a}, nil
+}
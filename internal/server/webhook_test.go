@@ -0,0 +1,130 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nexadis/gophmart/internal/client"
+	"github.com/Nexadis/gophmart/internal/db"
+	"github.com/Nexadis/gophmart/internal/money"
+	"github.com/Nexadis/gophmart/internal/order"
+	"github.com/Nexadis/gophmart/mocks"
+)
+
+var webhookTestSecret = `webhooksecret`
+
+var testsInternalAccruals = []struct {
+	name    string
+	body    string
+	sign    string
+	age     time.Duration
+	status  int
+	results []accrualResult
+}{
+	{
+		name:   "Single accrual",
+		body:   `{"order":"445084503850","status":"PROCESSED","accrual":500}`,
+		status: http.StatusOK,
+		results: []accrualResult{
+			{"445084503850", ResultApplied},
+		},
+	},
+	{
+		name:   "Batch of accruals",
+		body:   `[{"order":"445084503850","status":"PROCESSED","accrual":500},{"order":"12345678903","status":"PROCESSING"},{"order":"25461716","status":"PROCESSED","accrual":1}]`,
+		status: http.StatusOK,
+		results: []accrualResult{
+			{"445084503850", ResultUnchanged},
+			{"12345678903", ResultNotFound},
+			{"25461716", ResultRejected},
+		},
+	},
+	{
+		name:   "Malformed order number",
+		body:   `{"order":"4450845a3850","status":"PROCESSED","accrual":500}`,
+		status: http.StatusOK,
+		results: []accrualResult{
+			{"4450845a3850", ResultInvalid},
+		},
+	},
+	{
+		name:   "Replayed revision",
+		body:   `{"order":"445084503850","status":"PROCESSED","accrual":300}`,
+		status: http.StatusOK,
+		results: []accrualResult{
+			{"445084503850", ResultRejected},
+		},
+	},
+	{
+		name:   "Stale timestamp",
+		body:   `{"order":"445084503850","status":"PROCESSED","accrual":500}`,
+		age:    time.Hour,
+		status: http.StatusUnauthorized,
+	},
+	{
+		name:   "Invalid signature",
+		body:   `{"order":"445084503850","status":"PROCESSED","accrual":500}`,
+		sign:   "00",
+		status: http.StatusUnauthorized,
+	},
+}
+
+func TestInternalAccruals(t *testing.T) {
+	s := newTestServer()
+	s.config.WebhookSecret = webhookTestSecret
+	s.config.WebhookTolerance = 5 * time.Minute
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockdb := mocks.NewMockDatabase(ctrl)
+	s.db = mockdb
+	s.accrual = client.NewWithProvider(client.Config{}, nil, mockdb)
+	processed := money.Amount(50000)
+	gomock.InOrder(
+		mockdb.EXPECT().GetOrder(gomock.Any(), order.OrderNumber("445084503850")).Return(
+			&order.Order{Number: "445084503850", Status: order.StatusProcessing}, nil),
+		mockdb.EXPECT().AddAccrualRecord(gomock.Any(), gomock.Any()),
+		mockdb.EXPECT().UpdateOrder(gomock.Any(), gomock.Any()),
+		mockdb.EXPECT().GetOrder(gomock.Any(), order.OrderNumber("445084503850")).Return(
+			&order.Order{Number: "445084503850", Status: order.StatusProcessed, Accrual: &processed}, nil),
+		mockdb.EXPECT().GetOrder(gomock.Any(), order.OrderNumber("12345678903")).Return(
+			nil, db.ErrOrderNotFound),
+		mockdb.EXPECT().GetOrder(gomock.Any(), order.OrderNumber("25461716")).Return(
+			&order.Order{Number: "25461716", Status: order.StatusInvalid}, nil),
+		mockdb.EXPECT().AddAccrualRecord(gomock.Any(), gomock.Any()),
+		mockdb.EXPECT().GetOrder(gomock.Any(), order.OrderNumber("445084503850")).Return(
+			&order.Order{Number: "445084503850", Status: order.StatusProcessed, Accrual: &processed}, nil),
+		mockdb.EXPECT().AddAccrualRecord(gomock.Any(), gomock.Any()),
+		mockdb.EXPECT().ReviseAccrual(gomock.Any(), order.OrderNumber("445084503850"), gomock.Any(), gomock.Any(), gomock.Any()).Return(
+			nil, db.ErrStaleRevision),
+	)
+	for _, test := range testsInternalAccruals {
+		t.Run(test.name, func(t *testing.T) {
+			timestamp := strconv.FormatInt(time.Now().Add(-test.age).Unix(), 10)
+			sign := test.sign
+			if sign == "" {
+				sign = client.Sign(timestamp, []byte(test.body), []byte(webhookTestSecret))
+			}
+			req := httptest.NewRequest(http.MethodPost, APIInternalAccruals, strings.NewReader(test.body))
+			req.Header.Set(HeaderSignature, sign)
+			req.Header.Set(HeaderTimestamp, timestamp)
+			rec := httptest.NewRecorder()
+			s.e.ServeHTTP(rec, req)
+			assert.Equal(t, test.status, rec.Code)
+			if test.results == nil {
+				return
+			}
+			var results []accrualResult
+			if assert.NoError(t, json.NewDecoder(rec.Body).Decode(&results)) {
+				assert.Equal(t, test.results, results)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+// Package shortcode generates short codes typed by users like promo and referral codes.
+package shortcode
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+)
+
+// alphabet has no similar looking characters like 0/O and 1/I.
+const alphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+// Random returns code of n random characters of the alphabet.
+func Random(n int) (string, error) {
+	max := big.NewInt(int64(len(alphabet)))
+	var sb strings.Builder
+	sb.Grow(n)
+	for i := 0; i < n; i++ {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(alphabet[idx.Int64()])
+	}
+	return sb.String(), nil
+}
+
+// Normalize makes code entered by user comparable with saved codes.
+func Normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -0,0 +1,21 @@
+package shortcode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandom(t *testing.T) {
+	code, err := Random(12)
+	assert.NoError(t, err)
+	assert.Len(t, code, 12)
+	for _, c := range code {
+		assert.True(t, strings.ContainsRune(alphabet, c), "%q isn't in alphabet", c)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "ABCD2345", Normalize(" abcd2345\n"))
+}
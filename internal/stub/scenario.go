@@ -0,0 +1,85 @@
+package stub
+
+import (
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Nexadis/gophmart/internal/client"
+	"github.com/Nexadis/gophmart/internal/money"
+	"github.com/Nexadis/gophmart/internal/order"
+)
+
+// Scenario describes how the stub answers on orders.
+type Scenario struct {
+	Latency         time.Duration   `yaml:"latency"`
+	TooManyRequests TooManyRequests `yaml:"too_many_requests"`
+	Rules           []Rule          `yaml:"rules"`
+}
+
+// TooManyRequests answers 429 on every N-th request, zero Every disables it.
+type TooManyRequests struct {
+	Every      int           `yaml:"every"`
+	RetryAfter time.Duration `yaml:"retry_after"`
+}
+
+// Rule matches orders by prefix, orders without rule are unknown for the stub.
+type Rule struct {
+	Prefix  string  `yaml:"prefix"`
+	Accrual Accrual `yaml:"accrual"`
+	// Steps is count of requests in REGISTERED and PROCESSING statuses.
+	Steps int `yaml:"steps"`
+	// Final is PROCESSED or INVALID.
+	Final string `yaml:"final"`
+}
+
+// Accrual is Fixed value or random value in [Min, Max] if Fixed is zero.
+type Accrual struct {
+	Fixed money.Amount `yaml:"fixed"`
+	Min   money.Amount `yaml:"min"`
+	Max   money.Amount `yaml:"max"`
+}
+
+func DefaultScenario() *Scenario {
+	return &Scenario{
+		Rules: []Rule{
+			{
+				Accrual: Accrual{Fixed: 50000},
+				Steps:   1,
+				Final:   client.StatusProcessed,
+			},
+		},
+	}
+}
+
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Scenario{}
+	err = yaml.Unmarshal(data, s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Scenario) Rule(number order.OrderNumber) (*Rule, bool) {
+	for i := range s.Rules {
+		if strings.HasPrefix(string(number), s.Rules[i].Prefix) {
+			return &s.Rules[i], true
+		}
+	}
+	return nil, false
+}
+
+func (a Accrual) Amount(r *rand.Rand) money.Amount {
+	if a.Fixed == 0 && a.Max > a.Min {
+		return a.Min + money.Amount(r.Int63n(int64(a.Max-a.Min)+1))
+	}
+	return a.Fixed
+}
@@ -0,0 +1,113 @@
+package stub
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	"github.com/Nexadis/gophmart/internal/client"
+	"github.com/Nexadis/gophmart/internal/logger"
+	"github.com/Nexadis/gophmart/internal/money"
+	"github.com/Nexadis/gophmart/internal/order"
+)
+
+const APIGetAccrual = `/api/orders/:number`
+
+type orderState struct {
+	requests int
+	accrual  money.Amount
+}
+
+// Stub imitates the accrual system for local development.
+type Stub struct {
+	e        *echo.Echo
+	scenario *Scenario
+
+	mu       sync.Mutex
+	rand     *rand.Rand
+	requests int
+	orders   map[order.OrderNumber]*orderState
+}
+
+func New(scenario *Scenario) *Stub {
+	s := &Stub{
+		e:        echo.New(),
+		scenario: scenario,
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		orders:   make(map[order.OrderNumber]*orderState),
+	}
+	s.e.Use(middleware.Logger())
+	s.e.GET(APIGetAccrual, s.GetAccrual)
+	return s
+}
+
+func (s *Stub) Run(addr string) error {
+	return s.e.Start(addr)
+}
+
+func (s *Stub) GetAccrual(c echo.Context) error {
+	time.Sleep(s.scenario.Latency)
+	number := order.OrderNumber(c.Param("number"))
+	if s.tooManyRequests() {
+		retryAfter := int(s.scenario.TooManyRequests.RetryAfter.Seconds())
+		c.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		return c.String(http.StatusTooManyRequests, "No more than N requests per minute allowed")
+	}
+	a, ok := s.accrual(number)
+	if !ok {
+		return c.NoContent(http.StatusNoContent)
+	}
+	logger.Logger.Debugf("Stub accrual for %s: %s", number, a.Status)
+	return c.JSON(http.StatusOK, a)
+}
+
+func (s *Stub) tooManyRequests() bool {
+	every := s.scenario.TooManyRequests.Every
+	if every <= 0 {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	return s.requests%every == 0
+}
+
+// accrual moves order through REGISTERED, PROCESSING and final status,
+// each intermediate status is returned Steps times.
+func (s *Stub) accrual(number order.OrderNumber) (*client.Accrual, bool) {
+	rule, ok := s.scenario.Rule(number)
+	if !ok {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.orders[number]
+	if !ok {
+		state = &orderState{
+			accrual: rule.Accrual.Amount(s.rand),
+		}
+		s.orders[number] = state
+	}
+	state.requests++
+	a := &client.Accrual{
+		Order: number,
+	}
+	switch {
+	case state.requests <= rule.Steps:
+		a.Status = client.StatusRegistered
+	case state.requests <= 2*rule.Steps:
+		a.Status = client.StatusProcessing
+	case rule.Final == client.StatusInvalid:
+		a.Status = client.StatusInvalid
+	default:
+		a.Status = client.StatusProcessed
+		accrual := state.accrual
+		a.Accrual = &accrual
+	}
+	return a, true
+}
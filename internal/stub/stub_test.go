@@ -0,0 +1,66 @@
+package stub
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/gophmart/internal/client"
+	"github.com/Nexadis/gophmart/internal/money"
+)
+
+var testScenario = `
+too_many_requests:
+  every: 4
+  retry_after: 3s
+rules:
+  - prefix: "9"
+    steps: 1
+    final: INVALID
+  - prefix: "1"
+    steps: 1
+    accrual:
+      fixed: 0.29
+`
+
+func get(s *Stub, number string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/orders/"+number, nil)
+	rec := httptest.NewRecorder()
+	s.e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestStub(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scenario.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testScenario), 0o600))
+	scenario, err := LoadScenario(path)
+	require.NoError(t, err)
+	assert.Equal(t, 3*time.Second, scenario.TooManyRequests.RetryAfter)
+	s := New(scenario)
+
+	statuses := []string{client.StatusRegistered, client.StatusProcessing, client.StatusProcessed}
+	for _, status := range statuses {
+		rec := get(s, "18")
+		require.Equal(t, http.StatusOK, rec.Code)
+		a := &client.Accrual{}
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(a))
+		assert.Equal(t, status, a.Status)
+		if status == client.StatusProcessed && assert.NotNil(t, a.Accrual) {
+			assert.Equal(t, money.Amount(29), *a.Accrual)
+		}
+	}
+
+	rec := get(s, "18")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "3", rec.Header().Get("Retry-After"))
+
+	rec = get(s, "26")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
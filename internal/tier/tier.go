@@ -0,0 +1,133 @@
+// Package tier computes loyalty tiers of users from points of rolling period.
+package tier
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Nexadis/gophmart/internal/money"
+)
+
+// Bases of tier points.
+const (
+	BasisAccrued = "accrued"
+	BasisSpent   = "spent"
+)
+
+var ErrInvalid = errors.New(`invalid tiers`)
+
+type Tier struct {
+	Name      string       `json:"name"`
+	Threshold money.Amount `json:"threshold"`
+	// Multiplier is percent of accrual credited to the user, 100 adds nothing.
+	Multiplier int `json:"multiplier"`
+}
+
+// Bonus returns points added on top of accrual, it is rounded down to kopecks.
+func (t Tier) Bonus(accrual money.Amount) money.Amount {
+	if accrual <= 0 || t.Multiplier <= 100 {
+		return 0
+	}
+	bonus := new(big.Int).Mul(big.NewInt(int64(accrual)), big.NewInt(int64(t.Multiplier-100)))
+	bonus.Quo(bonus, big.NewInt(100))
+	if !bonus.IsInt64() {
+		return 0
+	}
+	return money.Amount(bonus.Int64())
+}
+
+// Tiers are sorted by threshold, the first tier has zero threshold.
+type Tiers []Tier
+
+// Parse parses tiers like "Bronze:0:100,Silver:1000:110,Gold:5000:125",
+// where fields are name, threshold in points and multiplier in percents.
+func Parse(s string) (Tiers, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var tiers Tiers
+	for _, item := range strings.Split(s, ",") {
+		fields := strings.Split(strings.TrimSpace(item), ":")
+		if len(fields) != 3 || fields[0] == "" {
+			return nil, fmt.Errorf("%w: %q should be name:threshold:multiplier", ErrInvalid, item)
+		}
+		threshold, err := money.Parse(fields[1])
+		if err != nil || threshold < 0 {
+			return nil, fmt.Errorf("%w: threshold of %q", ErrInvalid, fields[0])
+		}
+		multiplier, err := strconv.Atoi(fields[2])
+		if err != nil || multiplier < 100 {
+			return nil, fmt.Errorf("%w: multiplier of %q should be at least 100", ErrInvalid, fields[0])
+		}
+		if tiers.Find(fields[0]) != nil {
+			return nil, fmt.Errorf("%w: duplicate tier %q", ErrInvalid, fields[0])
+		}
+		tiers = append(tiers, Tier{Name: fields[0], Threshold: threshold, Multiplier: multiplier})
+	}
+	sort.SliceStable(tiers, func(i, j int) bool {
+		return tiers[i].Threshold < tiers[j].Threshold
+	})
+	if tiers[0].Threshold != 0 {
+		return nil, fmt.Errorf("%w: the first tier should have zero threshold", ErrInvalid)
+	}
+	return tiers, nil
+}
+
+// For returns the highest tier reached with points.
+func (t Tiers) For(points money.Amount) *Tier {
+	var found *Tier
+	for i := range t {
+		if t[i].Threshold > points {
+			break
+		}
+		found = &t[i]
+	}
+	return found
+}
+
+func (t Tiers) Find(name string) *Tier {
+	for i := range t {
+		if t[i].Name == name {
+			return &t[i]
+		}
+	}
+	return nil
+}
+
+// Progress is tier of the user and points left to the next tier.
+type Progress struct {
+	Tier   *Tier        `json:"tier"`
+	Points money.Amount `json:"points"`
+	Next   *Tier        `json:"next,omitempty"`
+	ToNext money.Amount `json:"to_next,omitempty"`
+}
+
+// Progress returns progress of the user with assigned tier and current points,
+// tier is taken from points if it isn't assigned yet.
+func (t Tiers) Progress(assigned string, points money.Amount) Progress {
+	p := Progress{
+		Tier:   t.Find(assigned),
+		Points: points,
+	}
+	if p.Tier == nil {
+		p.Tier = t.For(points)
+	}
+	if p.Tier == nil {
+		return p
+	}
+	for i := range t {
+		if t[i].Threshold > p.Tier.Threshold {
+			p.Next = &t[i]
+			p.ToNext = t[i].Threshold - points
+			if p.ToNext < 0 {
+				p.ToNext = 0
+			}
+			break
+		}
+	}
+	return p
+}
@@ -0,0 +1,69 @@
+package tier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nexadis/gophmart/internal/money"
+)
+
+func TestParse(t *testing.T) {
+	tiers, err := Parse("Gold:5000:125,Bronze:0:100,Silver:1000.50:110")
+	if assert.NoError(t, err) {
+		assert.Equal(t, Tiers{
+			{Name: "Bronze", Threshold: 0, Multiplier: 100},
+			{Name: "Silver", Threshold: 100050, Multiplier: 110},
+			{Name: "Gold", Threshold: 500000, Multiplier: 125},
+		}, tiers)
+	}
+	for _, invalid := range []string{
+		"Bronze:0",
+		"Silver:1000:110",
+		"Bronze:0:90",
+		"Bronze:0:100,Bronze:10:110",
+		"Bronze:x:100",
+	} {
+		_, err := Parse(invalid)
+		assert.ErrorIs(t, err, ErrInvalid, invalid)
+	}
+	tiers, err = Parse("")
+	assert.NoError(t, err)
+	assert.Nil(t, tiers)
+}
+
+var tiers = Tiers{
+	{Name: "Bronze", Threshold: 0, Multiplier: 100},
+	{Name: "Silver", Threshold: 100000, Multiplier: 110},
+	{Name: "Gold", Threshold: 500000, Multiplier: 125},
+}
+
+func TestFor(t *testing.T) {
+	assert.Equal(t, "Bronze", tiers.For(99999).Name)
+	assert.Equal(t, "Silver", tiers.For(100000).Name)
+	assert.Equal(t, "Gold", tiers.For(1000000).Name)
+	assert.Nil(t, Tiers{}.For(100))
+}
+
+func TestBonus(t *testing.T) {
+	assert.Equal(t, money.Amount(0), tiers[0].Bonus(1000))
+	assert.Equal(t, money.Amount(100), tiers[1].Bonus(1000))
+	assert.Equal(t, money.Amount(3), tiers[1].Bonus(39))
+	assert.Equal(t, money.Amount(250), tiers[2].Bonus(1000))
+	assert.Equal(t, money.Amount(0), tiers[2].Bonus(-1000))
+}
+
+func TestProgress(t *testing.T) {
+	p := tiers.Progress("", 30000)
+	assert.Equal(t, "Bronze", p.Tier.Name)
+	assert.Equal(t, "Silver", p.Next.Name)
+	assert.Equal(t, money.Amount(70000), p.ToNext)
+
+	p = tiers.Progress("Silver", 600000)
+	assert.Equal(t, "Silver", p.Tier.Name)
+	assert.Equal(t, "Gold", p.Next.Name)
+	assert.Equal(t, money.Amount(0), p.ToNext)
+
+	p = tiers.Progress("Gold", 600000)
+	assert.Nil(t, p.Next)
+}
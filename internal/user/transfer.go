@@ -0,0 +1,33 @@
+package user
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Nexadis/gophmart/internal/money"
+)
+
+// Directions of transfer for the user.
+const (
+	TransferIn  = "in"
+	TransferOut = "out"
+)
+
+var ErrSelfTransfer = errors.New(`can't transfer points to yourself`)
+
+// Transfer moves points from one user to another.
+type Transfer struct {
+	ID        int64        `json:"id"`
+	From      string       `json:"from"`
+	To        string       `json:"to"`
+	Sum       money.Amount `json:"sum"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// Direction returns whether the transfer is incoming or outgoing for the user.
+func (t *Transfer) Direction(login string) string {
+	if t.From == login {
+		return TransferOut
+	}
+	return TransferIn
+}
@@ -10,6 +10,8 @@ type User struct {
 	Login    string `json:"login"`
 	Password string `json:"password"`
 	HashPass string `json:"-"`
+	// ReferralCode is code of the referrer sent at registration.
+	ReferralCode string `json:"referral_code,omitempty"`
 }
 
 func (u *User) HashPassword() (string, error) {
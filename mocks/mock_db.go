@@ -7,8 +7,16 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
+	campaign "github.com/Nexadis/gophmart/internal/campaign"
+	expiry "github.com/Nexadis/gophmart/internal/expiry"
+	idempotency "github.com/Nexadis/gophmart/internal/idempotency"
+	money "github.com/Nexadis/gophmart/internal/money"
 	order "github.com/Nexadis/gophmart/internal/order"
+	policy "github.com/Nexadis/gophmart/internal/policy"
+	promo "github.com/Nexadis/gophmart/internal/promo"
+	referral "github.com/Nexadis/gophmart/internal/referral"
 	user "github.com/Nexadis/gophmart/internal/user"
 	gomock "github.com/golang/mock/gomock"
 )
@@ -50,6 +58,36 @@ func (mr *MockUserStoreMockRecorder) AddUser(ctx, user interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockUserStore)(nil).AddUser), ctx, user)
 }
 
+// GetLogins mocks base method.
+func (m *MockUserStore) GetLogins(ctx context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLogins", ctx)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLogins indicates an expected call of GetLogins.
+func (mr *MockUserStoreMockRecorder) GetLogins(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLogins", reflect.TypeOf((*MockUserStore)(nil).GetLogins), ctx)
+}
+
+// GetTier mocks base method.
+func (m *MockUserStore) GetTier(ctx context.Context, login string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTier", ctx, login)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTier indicates an expected call of GetTier.
+func (mr *MockUserStoreMockRecorder) GetTier(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTier", reflect.TypeOf((*MockUserStore)(nil).GetTier), ctx, login)
+}
+
 // GetUser mocks base method.
 func (m *MockUserStore) GetUser(ctx context.Context, login string) (*user.User, error) {
 	m.ctrl.T.Helper()
@@ -65,6 +103,20 @@ func (mr *MockUserStoreMockRecorder) GetUser(ctx, login interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockUserStore)(nil).GetUser), ctx, login)
 }
 
+// SetTier mocks base method.
+func (m *MockUserStore) SetTier(ctx context.Context, login, tier string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTier", ctx, login, tier)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTier indicates an expected call of SetTier.
+func (mr *MockUserStoreMockRecorder) SetTier(ctx, login, tier interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTier", reflect.TypeOf((*MockUserStore)(nil).SetTier), ctx, login, tier)
+}
+
 // MockOrdersStore is a mock of OrdersStore interface.
 type MockOrdersStore struct {
 	ctrl     *gomock.Controller
@@ -88,6 +140,20 @@ func (m *MockOrdersStore) EXPECT() *MockOrdersStoreMockRecorder {
 	return m.recorder
 }
 
+// AddAccrualRecord mocks base method.
+func (m *MockOrdersStore) AddAccrualRecord(ctx context.Context, r *order.AccrualRecord) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddAccrualRecord", ctx, r)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddAccrualRecord indicates an expected call of AddAccrualRecord.
+func (mr *MockOrdersStoreMockRecorder) AddAccrualRecord(ctx, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAccrualRecord", reflect.TypeOf((*MockOrdersStore)(nil).AddAccrualRecord), ctx, r)
+}
+
 // AddOrder mocks base method.
 func (m *MockOrdersStore) AddOrder(ctx context.Context, o *order.Order) error {
 	m.ctrl.T.Helper()
@@ -102,11 +168,41 @@ func (mr *MockOrdersStoreMockRecorder) AddOrder(ctx, o interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOrder", reflect.TypeOf((*MockOrdersStore)(nil).AddOrder), ctx, o)
 }
 
+// AddOrders mocks base method.
+func (m *MockOrdersStore) AddOrders(ctx context.Context, orders []*order.Order) ([]error, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddOrders", ctx, orders)
+	ret0, _ := ret[0].([]error)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddOrders indicates an expected call of AddOrders.
+func (mr *MockOrdersStoreMockRecorder) AddOrders(ctx, orders interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOrders", reflect.TypeOf((*MockOrdersStore)(nil).AddOrders), ctx, orders)
+}
+
+// CountProcessed mocks base method.
+func (m *MockOrdersStore) CountProcessed(ctx context.Context, owner string, until time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountProcessed", ctx, owner, until)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountProcessed indicates an expected call of CountProcessed.
+func (mr *MockOrdersStoreMockRecorder) CountProcessed(ctx, owner, until interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountProcessed", reflect.TypeOf((*MockOrdersStore)(nil).CountProcessed), ctx, owner, until)
+}
+
 // GetAccruals mocks base method.
-func (m *MockOrdersStore) GetAccruals(ctx context.Context, owner string) (int64, error) {
+func (m *MockOrdersStore) GetAccruals(ctx context.Context, owner string) (money.Amount, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetAccruals", ctx, owner)
-	ret0, _ := ret[0].(int64)
+	ret0, _ := ret[0].(money.Amount)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -117,6 +213,36 @@ func (mr *MockOrdersStoreMockRecorder) GetAccruals(ctx, owner interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccruals", reflect.TypeOf((*MockOrdersStore)(nil).GetAccruals), ctx, owner)
 }
 
+// GetAccruedSince mocks base method.
+func (m *MockOrdersStore) GetAccruedSince(ctx context.Context, owner string, since time.Time) (money.Amount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccruedSince", ctx, owner, since)
+	ret0, _ := ret[0].(money.Amount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccruedSince indicates an expected call of GetAccruedSince.
+func (mr *MockOrdersStoreMockRecorder) GetAccruedSince(ctx, owner, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccruedSince", reflect.TypeOf((*MockOrdersStore)(nil).GetAccruedSince), ctx, owner, since)
+}
+
+// GetDueOrders mocks base method.
+func (m *MockOrdersStore) GetDueOrders(ctx context.Context, limit int, revision time.Duration) ([]*order.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDueOrders", ctx, limit, revision)
+	ret0, _ := ret[0].([]*order.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDueOrders indicates an expected call of GetDueOrders.
+func (mr *MockOrdersStoreMockRecorder) GetDueOrders(ctx, limit, revision interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDueOrders", reflect.TypeOf((*MockOrdersStore)(nil).GetDueOrders), ctx, limit, revision)
+}
+
 // GetOrder mocks base method.
 func (m *MockOrdersStore) GetOrder(ctx context.Context, number order.OrderNumber) (*order.Order, error) {
 	m.ctrl.T.Helper()
@@ -147,6 +273,36 @@ func (mr *MockOrdersStoreMockRecorder) GetOrders(ctx, owner interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrders", reflect.TypeOf((*MockOrdersStore)(nil).GetOrders), ctx, owner)
 }
 
+// GetRewardPending mocks base method.
+func (m *MockOrdersStore) GetRewardPending(ctx context.Context, limit int) ([]*order.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRewardPending", ctx, limit)
+	ret0, _ := ret[0].([]*order.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRewardPending indicates an expected call of GetRewardPending.
+func (mr *MockOrdersStoreMockRecorder) GetRewardPending(ctx, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRewardPending", reflect.TypeOf((*MockOrdersStore)(nil).GetRewardPending), ctx, limit)
+}
+
+// GetStatusHistory mocks base method.
+func (m *MockOrdersStore) GetStatusHistory(ctx context.Context, number order.OrderNumber) ([]*order.StatusChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStatusHistory", ctx, number)
+	ret0, _ := ret[0].([]*order.StatusChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStatusHistory indicates an expected call of GetStatusHistory.
+func (mr *MockOrdersStoreMockRecorder) GetStatusHistory(ctx, number interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatusHistory", reflect.TypeOf((*MockOrdersStore)(nil).GetStatusHistory), ctx, number)
+}
+
 // GetWithStatus mocks base method.
 func (m *MockOrdersStore) GetWithStatus(ctx context.Context, s order.Status) ([]order.OrderNumber, error) {
 	m.ctrl.T.Helper()
@@ -162,6 +318,63 @@ func (mr *MockOrdersStoreMockRecorder) GetWithStatus(ctx, s interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithStatus", reflect.TypeOf((*MockOrdersStore)(nil).GetWithStatus), ctx, s)
 }
 
+// RequeueOrder mocks base method.
+func (m *MockOrdersStore) RequeueOrder(ctx context.Context, number order.OrderNumber) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequeueOrder", ctx, number)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RequeueOrder indicates an expected call of RequeueOrder.
+func (mr *MockOrdersStoreMockRecorder) RequeueOrder(ctx, number interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequeueOrder", reflect.TypeOf((*MockOrdersStore)(nil).RequeueOrder), ctx, number)
+}
+
+// ReviseAccrual mocks base method.
+func (m *MockOrdersStore) ReviseAccrual(ctx context.Context, number order.OrderNumber, accrual *money.Amount, asOf, next time.Time) (*order.Adjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReviseAccrual", ctx, number, accrual, asOf, next)
+	ret0, _ := ret[0].(*order.Adjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReviseAccrual indicates an expected call of ReviseAccrual.
+func (mr *MockOrdersStoreMockRecorder) ReviseAccrual(ctx, number, accrual, asOf, next interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReviseAccrual", reflect.TypeOf((*MockOrdersStore)(nil).ReviseAccrual), ctx, number, accrual, asOf, next)
+}
+
+// ScheduleOrder mocks base method.
+func (m *MockOrdersStore) ScheduleOrder(ctx context.Context, number order.OrderNumber, attempts int, next time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScheduleOrder", ctx, number, attempts, next)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ScheduleOrder indicates an expected call of ScheduleOrder.
+func (mr *MockOrdersStoreMockRecorder) ScheduleOrder(ctx, number, attempts, next interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScheduleOrder", reflect.TypeOf((*MockOrdersStore)(nil).ScheduleOrder), ctx, number, attempts, next)
+}
+
+// SetRewarded mocks base method.
+func (m *MockOrdersStore) SetRewarded(ctx context.Context, number order.OrderNumber, accrual *money.Amount) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRewarded", ctx, number, accrual)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetRewarded indicates an expected call of SetRewarded.
+func (mr *MockOrdersStoreMockRecorder) SetRewarded(ctx, number, accrual interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRewarded", reflect.TypeOf((*MockOrdersStore)(nil).SetRewarded), ctx, number, accrual)
+}
+
 // UpdateOrder mocks base method.
 func (m *MockOrdersStore) UpdateOrder(ctx context.Context, o *order.Order) error {
 	m.ctrl.T.Helper()
@@ -176,6 +389,43 @@ func (mr *MockOrdersStoreMockRecorder) UpdateOrder(ctx, o interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrder", reflect.TypeOf((*MockOrdersStore)(nil).UpdateOrder), ctx, o)
 }
 
+// MockOrdersNotifier is a mock of OrdersNotifier interface.
+type MockOrdersNotifier struct {
+	ctrl     *gomock.Controller
+	recorder *MockOrdersNotifierMockRecorder
+}
+
+// MockOrdersNotifierMockRecorder is the mock recorder for MockOrdersNotifier.
+type MockOrdersNotifierMockRecorder struct {
+	mock *MockOrdersNotifier
+}
+
+// NewMockOrdersNotifier creates a new mock instance.
+func NewMockOrdersNotifier(ctrl *gomock.Controller) *MockOrdersNotifier {
+	mock := &MockOrdersNotifier{ctrl: ctrl}
+	mock.recorder = &MockOrdersNotifierMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockOrdersNotifier) EXPECT() *MockOrdersNotifierMockRecorder {
+	return m.recorder
+}
+
+// ListenOrders mocks base method.
+func (m *MockOrdersNotifier) ListenOrders(ctx context.Context, handle func(order.OrderNumber)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListenOrders", ctx, handle)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ListenOrders indicates an expected call of ListenOrders.
+func (mr *MockOrdersNotifierMockRecorder) ListenOrders(ctx, handle interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListenOrders", reflect.TypeOf((*MockOrdersNotifier)(nil).ListenOrders), ctx, handle)
+}
+
 // MockWithdrawalsStore is a mock of WithdrawalsStore interface.
 type MockWithdrawalsStore struct {
 	ctrl     *gomock.Controller
@@ -200,17 +450,17 @@ func (m *MockWithdrawalsStore) EXPECT() *MockWithdrawalsStoreMockRecorder {
 }
 
 // AddWithdrawal mocks base method.
-func (m *MockWithdrawalsStore) AddWithdrawal(ctx context.Context, wd *order.Withdraw) error {
+func (m *MockWithdrawalsStore) AddWithdrawal(ctx context.Context, wd *order.Withdraw, p policy.Withdraw) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddWithdrawal", ctx, wd)
+	ret := m.ctrl.Call(m, "AddWithdrawal", ctx, wd, p)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AddWithdrawal indicates an expected call of AddWithdrawal.
-func (mr *MockWithdrawalsStoreMockRecorder) AddWithdrawal(ctx, wd interface{}) *gomock.Call {
+func (mr *MockWithdrawalsStoreMockRecorder) AddWithdrawal(ctx, wd, p interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWithdrawal", reflect.TypeOf((*MockWithdrawalsStore)(nil).AddWithdrawal), ctx, wd)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWithdrawal", reflect.TypeOf((*MockWithdrawalsStore)(nil).AddWithdrawal), ctx, wd, p)
 }
 
 // GetWithdrawals mocks base method.
@@ -229,10 +479,10 @@ func (mr *MockWithdrawalsStoreMockRecorder) GetWithdrawals(ctx, owner interface{
 }
 
 // GetWithdrawn mocks base method.
-func (m *MockWithdrawalsStore) GetWithdrawn(ctx context.Context, owner string) (int64, error) {
+func (m *MockWithdrawalsStore) GetWithdrawn(ctx context.Context, owner string) (money.Amount, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetWithdrawn", ctx, owner)
-	ret0, _ := ret[0].(int64)
+	ret0, _ := ret[0].(money.Amount)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -243,190 +493,1452 @@ func (mr *MockWithdrawalsStoreMockRecorder) GetWithdrawn(ctx, owner interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawn", reflect.TypeOf((*MockWithdrawalsStore)(nil).GetWithdrawn), ctx, owner)
 }
 
-// MockDatabase is a mock of Database interface.
-type MockDatabase struct {
+// GetWithdrawnSince mocks base method.
+func (m *MockWithdrawalsStore) GetWithdrawnSince(ctx context.Context, owner string, since time.Time) (money.Amount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWithdrawnSince", ctx, owner, since)
+	ret0, _ := ret[0].(money.Amount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWithdrawnSince indicates an expected call of GetWithdrawnSince.
+func (mr *MockWithdrawalsStoreMockRecorder) GetWithdrawnSince(ctx, owner, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawnSince", reflect.TypeOf((*MockWithdrawalsStore)(nil).GetWithdrawnSince), ctx, owner, since)
+}
+
+// RefundWithdrawal mocks base method.
+func (m *MockWithdrawalsStore) RefundWithdrawal(ctx context.Context, number order.OrderNumber, sum *money.Amount, cancel bool) (*order.Withdraw, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefundWithdrawal", ctx, number, sum, cancel)
+	ret0, _ := ret[0].(*order.Withdraw)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefundWithdrawal indicates an expected call of RefundWithdrawal.
+func (mr *MockWithdrawalsStoreMockRecorder) RefundWithdrawal(ctx, number, sum, cancel interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefundWithdrawal", reflect.TypeOf((*MockWithdrawalsStore)(nil).RefundWithdrawal), ctx, number, sum, cancel)
+}
+
+// MockBonusesStore is a mock of BonusesStore interface.
+type MockBonusesStore struct {
 	ctrl     *gomock.Controller
-	recorder *MockDatabaseMockRecorder
+	recorder *MockBonusesStoreMockRecorder
 }
 
-// MockDatabaseMockRecorder is the mock recorder for MockDatabase.
-type MockDatabaseMockRecorder struct {
-	mock *MockDatabase
+// MockBonusesStoreMockRecorder is the mock recorder for MockBonusesStore.
+type MockBonusesStoreMockRecorder struct {
+	mock *MockBonusesStore
 }
 
-// NewMockDatabase creates a new mock instance.
-func NewMockDatabase(ctrl *gomock.Controller) *MockDatabase {
-	mock := &MockDatabase{ctrl: ctrl}
-	mock.recorder = &MockDatabaseMockRecorder{mock}
+// NewMockBonusesStore creates a new mock instance.
+func NewMockBonusesStore(ctrl *gomock.Controller) *MockBonusesStore {
+	mock := &MockBonusesStore{ctrl: ctrl}
+	mock.recorder = &MockBonusesStoreMockRecorder{mock}
 	return mock
 }
 
 // EXPECT returns an object that allows the caller to indicate expected use.
-func (m *MockDatabase) EXPECT() *MockDatabaseMockRecorder {
+func (m *MockBonusesStore) EXPECT() *MockBonusesStoreMockRecorder {
 	return m.recorder
 }
 
-// AddOrder mocks base method.
-func (m *MockDatabase) AddOrder(ctx context.Context, o *order.Order) error {
+// GetBonuses mocks base method.
+func (m *MockBonusesStore) GetBonuses(ctx context.Context, owner string) (money.Amount, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddOrder", ctx, o)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "GetBonuses", ctx, owner)
+	ret0, _ := ret[0].(money.Amount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// AddOrder indicates an expected call of AddOrder.
-func (mr *MockDatabaseMockRecorder) AddOrder(ctx, o interface{}) *gomock.Call {
+// GetBonuses indicates an expected call of GetBonuses.
+func (mr *MockBonusesStoreMockRecorder) GetBonuses(ctx, owner interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOrder", reflect.TypeOf((*MockDatabase)(nil).AddOrder), ctx, o)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBonuses", reflect.TypeOf((*MockBonusesStore)(nil).GetBonuses), ctx, owner)
 }
 
-// AddUser mocks base method.
-func (m *MockDatabase) AddUser(ctx context.Context, user *user.User) error {
+// SetBonuses mocks base method.
+func (m *MockBonusesStore) SetBonuses(ctx context.Context, r *campaign.Reward, bonuses []*order.Bonus) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddUser", ctx, user)
+	ret := m.ctrl.Call(m, "SetBonuses", ctx, r, bonuses)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// AddUser indicates an expected call of AddUser.
-func (mr *MockDatabaseMockRecorder) AddUser(ctx, user interface{}) *gomock.Call {
+// SetBonuses indicates an expected call of SetBonuses.
+func (mr *MockBonusesStoreMockRecorder) SetBonuses(ctx, r, bonuses interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockDatabase)(nil).AddUser), ctx, user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBonuses", reflect.TypeOf((*MockBonusesStore)(nil).SetBonuses), ctx, r, bonuses)
 }
 
-// AddWithdrawal mocks base method.
-func (m *MockDatabase) AddWithdrawal(ctx context.Context, wd *order.Withdraw) error {
+// MockCampaignsStore is a mock of CampaignsStore interface.
+type MockCampaignsStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockCampaignsStoreMockRecorder
+}
+
+// MockCampaignsStoreMockRecorder is the mock recorder for MockCampaignsStore.
+type MockCampaignsStoreMockRecorder struct {
+	mock *MockCampaignsStore
+}
+
+// NewMockCampaignsStore creates a new mock instance.
+func NewMockCampaignsStore(ctrl *gomock.Controller) *MockCampaignsStore {
+	mock := &MockCampaignsStore{ctrl: ctrl}
+	mock.recorder = &MockCampaignsStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCampaignsStore) EXPECT() *MockCampaignsStoreMockRecorder {
+	return m.recorder
+}
+
+// AddCampaign mocks base method.
+func (m *MockCampaignsStore) AddCampaign(ctx context.Context, c *campaign.Campaign) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddWithdrawal", ctx, wd)
+	ret := m.ctrl.Call(m, "AddCampaign", ctx, c)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// AddWithdrawal indicates an expected call of AddWithdrawal.
-func (mr *MockDatabaseMockRecorder) AddWithdrawal(ctx, wd interface{}) *gomock.Call {
+// AddCampaign indicates an expected call of AddCampaign.
+func (mr *MockCampaignsStoreMockRecorder) AddCampaign(ctx, c interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWithdrawal", reflect.TypeOf((*MockDatabase)(nil).AddWithdrawal), ctx, wd)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddCampaign", reflect.TypeOf((*MockCampaignsStore)(nil).AddCampaign), ctx, c)
 }
 
-// Close mocks base method.
-func (m *MockDatabase) Close() error {
+// DeleteCampaign mocks base method.
+func (m *MockCampaignsStore) DeleteCampaign(ctx context.Context, id int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Close")
+	ret := m.ctrl.Call(m, "DeleteCampaign", ctx, id)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// Close indicates an expected call of Close.
-func (mr *MockDatabaseMockRecorder) Close() *gomock.Call {
+// DeleteCampaign indicates an expected call of DeleteCampaign.
+func (mr *MockCampaignsStoreMockRecorder) DeleteCampaign(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockDatabase)(nil).Close))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCampaign", reflect.TypeOf((*MockCampaignsStore)(nil).DeleteCampaign), ctx, id)
 }
 
-// GetAccruals mocks base method.
-func (m *MockDatabase) GetAccruals(ctx context.Context, owner string) (int64, error) {
+// GetCampaigns mocks base method.
+func (m *MockCampaignsStore) GetCampaigns(ctx context.Context) ([]*campaign.Campaign, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAccruals", ctx, owner)
-	ret0, _ := ret[0].(int64)
+	ret := m.ctrl.Call(m, "GetCampaigns", ctx)
+	ret0, _ := ret[0].([]*campaign.Campaign)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetAccruals indicates an expected call of GetAccruals.
-func (mr *MockDatabaseMockRecorder) GetAccruals(ctx, owner interface{}) *gomock.Call {
+// GetCampaigns indicates an expected call of GetCampaigns.
+func (mr *MockCampaignsStoreMockRecorder) GetCampaigns(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccruals", reflect.TypeOf((*MockDatabase)(nil).GetAccruals), ctx, owner)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCampaigns", reflect.TypeOf((*MockCampaignsStore)(nil).GetCampaigns), ctx)
 }
 
-// GetOrder mocks base method.
-func (m *MockDatabase) GetOrder(ctx context.Context, number order.OrderNumber) (*order.Order, error) {
+// GetReward mocks base method.
+func (m *MockCampaignsStore) GetReward(ctx context.Context, number order.OrderNumber) (*campaign.Reward, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOrder", ctx, number)
-	ret0, _ := ret[0].(*order.Order)
+	ret := m.ctrl.Call(m, "GetReward", ctx, number)
+	ret0, _ := ret[0].(*campaign.Reward)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetOrder indicates an expected call of GetOrder.
-func (mr *MockDatabaseMockRecorder) GetOrder(ctx, number interface{}) *gomock.Call {
+// GetReward indicates an expected call of GetReward.
+func (mr *MockCampaignsStoreMockRecorder) GetReward(ctx, number interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrder", reflect.TypeOf((*MockDatabase)(nil).GetOrder), ctx, number)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReward", reflect.TypeOf((*MockCampaignsStore)(nil).GetReward), ctx, number)
 }
 
-// GetOrders mocks base method.
-func (m *MockDatabase) GetOrders(ctx context.Context, owner string) ([]*order.Order, error) {
-	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetOrders", ctx, owner)
-	ret0, _ := ret[0].([]*order.Order)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+// MockPromoStore is a mock of PromoStore interface.
+type MockPromoStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockPromoStoreMockRecorder
 }
 
-// GetOrders indicates an expected call of GetOrders.
-func (mr *MockDatabaseMockRecorder) GetOrders(ctx, owner interface{}) *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrders", reflect.TypeOf((*MockDatabase)(nil).GetOrders), ctx, owner)
+// MockPromoStoreMockRecorder is the mock recorder for MockPromoStore.
+type MockPromoStoreMockRecorder struct {
+	mock *MockPromoStore
 }
 
-// GetUser mocks base method.
-func (m *MockDatabase) GetUser(ctx context.Context, login string) (*user.User, error) {
-	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUser", ctx, login)
-	ret0, _ := ret[0].(*user.User)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+// NewMockPromoStore creates a new mock instance.
+func NewMockPromoStore(ctrl *gomock.Controller) *MockPromoStore {
+	mock := &MockPromoStore{ctrl: ctrl}
+	mock.recorder = &MockPromoStoreMockRecorder{mock}
+	return mock
 }
 
-// GetUser indicates an expected call of GetUser.
-func (mr *MockDatabaseMockRecorder) GetUser(ctx, login interface{}) *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockDatabase)(nil).GetUser), ctx, login)
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPromoStore) EXPECT() *MockPromoStoreMockRecorder {
+	return m.recorder
 }
 
-// GetWithStatus mocks base method.
-func (m *MockDatabase) GetWithStatus(ctx context.Context, s order.Status) ([]order.OrderNumber, error) {
+// AddPromoCodes mocks base method.
+func (m *MockPromoStore) AddPromoCodes(ctx context.Context, codes []*promo.Code) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetWithStatus", ctx, s)
-	ret0, _ := ret[0].([]order.OrderNumber)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "AddPromoCodes", ctx, codes)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// GetWithStatus indicates an expected call of GetWithStatus.
-func (mr *MockDatabaseMockRecorder) GetWithStatus(ctx, s interface{}) *gomock.Call {
+// AddPromoCodes indicates an expected call of AddPromoCodes.
+func (mr *MockPromoStoreMockRecorder) AddPromoCodes(ctx, codes interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithStatus", reflect.TypeOf((*MockDatabase)(nil).GetWithStatus), ctx, s)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPromoCodes", reflect.TypeOf((*MockPromoStore)(nil).AddPromoCodes), ctx, codes)
 }
 
-// GetWithdrawals mocks base method.
-func (m *MockDatabase) GetWithdrawals(ctx context.Context, owner string) ([]*order.Withdraw, error) {
+// GetRedeemed mocks base method.
+func (m *MockPromoStore) GetRedeemed(ctx context.Context, owner string) (money.Amount, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetWithdrawals", ctx, owner)
-	ret0, _ := ret[0].([]*order.Withdraw)
+	ret := m.ctrl.Call(m, "GetRedeemed", ctx, owner)
+	ret0, _ := ret[0].(money.Amount)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetWithdrawals indicates an expected call of GetWithdrawals.
-func (mr *MockDatabaseMockRecorder) GetWithdrawals(ctx, owner interface{}) *gomock.Call {
+// GetRedeemed indicates an expected call of GetRedeemed.
+func (mr *MockPromoStoreMockRecorder) GetRedeemed(ctx, owner interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawals", reflect.TypeOf((*MockDatabase)(nil).GetWithdrawals), ctx, owner)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRedeemed", reflect.TypeOf((*MockPromoStore)(nil).GetRedeemed), ctx, owner)
 }
 
-// GetWithdrawn mocks base method.
-func (m *MockDatabase) GetWithdrawn(ctx context.Context, owner string) (int64, error) {
+// RedeemPromo mocks base method.
+func (m *MockPromoStore) RedeemPromo(ctx context.Context, code, owner string, now time.Time) (*promo.Redemption, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetWithdrawn", ctx, owner)
-	ret0, _ := ret[0].(int64)
+	ret := m.ctrl.Call(m, "RedeemPromo", ctx, code, owner, now)
+	ret0, _ := ret[0].(*promo.Redemption)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetWithdrawn indicates an expected call of GetWithdrawn.
+// RedeemPromo indicates an expected call of RedeemPromo.
+func (mr *MockPromoStoreMockRecorder) RedeemPromo(ctx, code, owner, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RedeemPromo", reflect.TypeOf((*MockPromoStore)(nil).RedeemPromo), ctx, code, owner, now)
+}
+
+// MockReferralsStore is a mock of ReferralsStore interface.
+type MockReferralsStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockReferralsStoreMockRecorder
+}
+
+// MockReferralsStoreMockRecorder is the mock recorder for MockReferralsStore.
+type MockReferralsStoreMockRecorder struct {
+	mock *MockReferralsStore
+}
+
+// NewMockReferralsStore creates a new mock instance.
+func NewMockReferralsStore(ctrl *gomock.Controller) *MockReferralsStore {
+	mock := &MockReferralsStore{ctrl: ctrl}
+	mock.recorder = &MockReferralsStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockReferralsStore) EXPECT() *MockReferralsStoreMockRecorder {
+	return m.recorder
+}
+
+// AddReferral mocks base method.
+func (m *MockReferralsStore) AddReferral(ctx context.Context, r *referral.Referral, max int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddReferral", ctx, r, max)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddReferral indicates an expected call of AddReferral.
+func (mr *MockReferralsStoreMockRecorder) AddReferral(ctx, r, max interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddReferral", reflect.TypeOf((*MockReferralsStore)(nil).AddReferral), ctx, r, max)
+}
+
+// CountReferrals mocks base method.
+func (m *MockReferralsStore) CountReferrals(ctx context.Context, referrer string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountReferrals", ctx, referrer)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountReferrals indicates an expected call of CountReferrals.
+func (mr *MockReferralsStoreMockRecorder) CountReferrals(ctx, referrer interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountReferrals", reflect.TypeOf((*MockReferralsStore)(nil).CountReferrals), ctx, referrer)
+}
+
+// GetReferral mocks base method.
+func (m *MockReferralsStore) GetReferral(ctx context.Context, referee string) (*referral.Referral, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReferral", ctx, referee)
+	ret0, _ := ret[0].(*referral.Referral)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReferral indicates an expected call of GetReferral.
+func (mr *MockReferralsStoreMockRecorder) GetReferral(ctx, referee interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReferral", reflect.TypeOf((*MockReferralsStore)(nil).GetReferral), ctx, referee)
+}
+
+// GetReferrals mocks base method.
+func (m *MockReferralsStore) GetReferrals(ctx context.Context, referrer string) ([]*referral.Referral, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReferrals", ctx, referrer)
+	ret0, _ := ret[0].([]*referral.Referral)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReferrals indicates an expected call of GetReferrals.
+func (mr *MockReferralsStoreMockRecorder) GetReferrals(ctx, referrer interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReferrals", reflect.TypeOf((*MockReferralsStore)(nil).GetReferrals), ctx, referrer)
+}
+
+// GetReferrer mocks base method.
+func (m *MockReferralsStore) GetReferrer(ctx context.Context, code string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReferrer", ctx, code)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReferrer indicates an expected call of GetReferrer.
+func (mr *MockReferralsStoreMockRecorder) GetReferrer(ctx, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReferrer", reflect.TypeOf((*MockReferralsStore)(nil).GetReferrer), ctx, code)
+}
+
+// RewardReferral mocks base method.
+func (m *MockReferralsStore) RewardReferral(ctx context.Context, r *referral.Referral, bonuses []*order.Bonus) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RewardReferral", ctx, r, bonuses)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RewardReferral indicates an expected call of RewardReferral.
+func (mr *MockReferralsStoreMockRecorder) RewardReferral(ctx, r, bonuses interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RewardReferral", reflect.TypeOf((*MockReferralsStore)(nil).RewardReferral), ctx, r, bonuses)
+}
+
+// SetReferralCode mocks base method.
+func (m *MockReferralsStore) SetReferralCode(ctx context.Context, login, code string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetReferralCode", ctx, login, code)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetReferralCode indicates an expected call of SetReferralCode.
+func (mr *MockReferralsStoreMockRecorder) SetReferralCode(ctx, login, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetReferralCode", reflect.TypeOf((*MockReferralsStore)(nil).SetReferralCode), ctx, login, code)
+}
+
+// MockTransfersStore is a mock of TransfersStore interface.
+type MockTransfersStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockTransfersStoreMockRecorder
+}
+
+// MockTransfersStoreMockRecorder is the mock recorder for MockTransfersStore.
+type MockTransfersStoreMockRecorder struct {
+	mock *MockTransfersStore
+}
+
+// NewMockTransfersStore creates a new mock instance.
+func NewMockTransfersStore(ctrl *gomock.Controller) *MockTransfersStore {
+	mock := &MockTransfersStore{ctrl: ctrl}
+	mock.recorder = &MockTransfersStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTransfersStore) EXPECT() *MockTransfersStoreMockRecorder {
+	return m.recorder
+}
+
+// AddTransfer mocks base method.
+func (m *MockTransfersStore) AddTransfer(ctx context.Context, t *user.Transfer, p policy.Withdraw) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddTransfer", ctx, t, p)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddTransfer indicates an expected call of AddTransfer.
+func (mr *MockTransfersStoreMockRecorder) AddTransfer(ctx, t, p interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTransfer", reflect.TypeOf((*MockTransfersStore)(nil).AddTransfer), ctx, t, p)
+}
+
+// GetTransferred mocks base method.
+func (m *MockTransfersStore) GetTransferred(ctx context.Context, login string) (money.Amount, money.Amount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferred", ctx, login)
+	ret0, _ := ret[0].(money.Amount)
+	ret1, _ := ret[1].(money.Amount)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetTransferred indicates an expected call of GetTransferred.
+func (mr *MockTransfersStoreMockRecorder) GetTransferred(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferred", reflect.TypeOf((*MockTransfersStore)(nil).GetTransferred), ctx, login)
+}
+
+// GetTransferredSince mocks base method.
+func (m *MockTransfersStore) GetTransferredSince(ctx context.Context, login string, since time.Time) (money.Amount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferredSince", ctx, login, since)
+	ret0, _ := ret[0].(money.Amount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransferredSince indicates an expected call of GetTransferredSince.
+func (mr *MockTransfersStoreMockRecorder) GetTransferredSince(ctx, login, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferredSince", reflect.TypeOf((*MockTransfersStore)(nil).GetTransferredSince), ctx, login, since)
+}
+
+// GetTransfers mocks base method.
+func (m *MockTransfersStore) GetTransfers(ctx context.Context, login string) ([]*user.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransfers", ctx, login)
+	ret0, _ := ret[0].([]*user.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransfers indicates an expected call of GetTransfers.
+func (mr *MockTransfersStoreMockRecorder) GetTransfers(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransfers", reflect.TypeOf((*MockTransfersStore)(nil).GetTransfers), ctx, login)
+}
+
+// MockHoldsStore is a mock of HoldsStore interface.
+type MockHoldsStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockHoldsStoreMockRecorder
+}
+
+// MockHoldsStoreMockRecorder is the mock recorder for MockHoldsStore.
+type MockHoldsStoreMockRecorder struct {
+	mock *MockHoldsStore
+}
+
+// NewMockHoldsStore creates a new mock instance.
+func NewMockHoldsStore(ctrl *gomock.Controller) *MockHoldsStore {
+	mock := &MockHoldsStore{ctrl: ctrl}
+	mock.recorder = &MockHoldsStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHoldsStore) EXPECT() *MockHoldsStoreMockRecorder {
+	return m.recorder
+}
+
+// AddHold mocks base method.
+func (m *MockHoldsStore) AddHold(ctx context.Context, h *order.Hold, p policy.Withdraw) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddHold", ctx, h, p)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddHold indicates an expected call of AddHold.
+func (mr *MockHoldsStoreMockRecorder) AddHold(ctx, h, p interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddHold", reflect.TypeOf((*MockHoldsStore)(nil).AddHold), ctx, h, p)
+}
+
+// CaptureHold mocks base method.
+func (m *MockHoldsStore) CaptureHold(ctx context.Context, owner string, number order.OrderNumber, now time.Time, p policy.Withdraw) (*order.Withdraw, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CaptureHold", ctx, owner, number, now, p)
+	ret0, _ := ret[0].(*order.Withdraw)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CaptureHold indicates an expected call of CaptureHold.
+func (mr *MockHoldsStoreMockRecorder) CaptureHold(ctx, owner, number, now, p interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CaptureHold", reflect.TypeOf((*MockHoldsStore)(nil).CaptureHold), ctx, owner, number, now, p)
+}
+
+// GetHeld mocks base method.
+func (m *MockHoldsStore) GetHeld(ctx context.Context, owner string, now time.Time) (money.Amount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHeld", ctx, owner, now)
+	ret0, _ := ret[0].(money.Amount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetHeld indicates an expected call of GetHeld.
+func (mr *MockHoldsStoreMockRecorder) GetHeld(ctx, owner, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHeld", reflect.TypeOf((*MockHoldsStore)(nil).GetHeld), ctx, owner, now)
+}
+
+// VoidHold mocks base method.
+func (m *MockHoldsStore) VoidHold(ctx context.Context, owner string, number order.OrderNumber, now time.Time) (*order.Hold, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VoidHold", ctx, owner, number, now)
+	ret0, _ := ret[0].(*order.Hold)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VoidHold indicates an expected call of VoidHold.
+func (mr *MockHoldsStoreMockRecorder) VoidHold(ctx, owner, number, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VoidHold", reflect.TypeOf((*MockHoldsStore)(nil).VoidHold), ctx, owner, number, now)
+}
+
+// MockExpirationsStore is a mock of ExpirationsStore interface.
+type MockExpirationsStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockExpirationsStoreMockRecorder
+}
+
+// MockExpirationsStoreMockRecorder is the mock recorder for MockExpirationsStore.
+type MockExpirationsStoreMockRecorder struct {
+	mock *MockExpirationsStore
+}
+
+// NewMockExpirationsStore creates a new mock instance.
+func NewMockExpirationsStore(ctrl *gomock.Controller) *MockExpirationsStore {
+	mock := &MockExpirationsStore{ctrl: ctrl}
+	mock.recorder = &MockExpirationsStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockExpirationsStore) EXPECT() *MockExpirationsStoreMockRecorder {
+	return m.recorder
+}
+
+// ExpirePoints mocks base method.
+func (m *MockExpirationsStore) ExpirePoints(ctx context.Context, owner string, p expiry.Policy, now time.Time) ([]*expiry.Expiration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpirePoints", ctx, owner, p, now)
+	ret0, _ := ret[0].([]*expiry.Expiration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpirePoints indicates an expected call of ExpirePoints.
+func (mr *MockExpirationsStoreMockRecorder) ExpirePoints(ctx, owner, p, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpirePoints", reflect.TypeOf((*MockExpirationsStore)(nil).ExpirePoints), ctx, owner, p, now)
+}
+
+// GetDebits mocks base method.
+func (m *MockExpirationsStore) GetDebits(ctx context.Context, owner string, now time.Time) ([]expiry.Debit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDebits", ctx, owner, now)
+	ret0, _ := ret[0].([]expiry.Debit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDebits indicates an expected call of GetDebits.
+func (mr *MockExpirationsStoreMockRecorder) GetDebits(ctx, owner, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDebits", reflect.TypeOf((*MockExpirationsStore)(nil).GetDebits), ctx, owner, now)
+}
+
+// GetExpirations mocks base method.
+func (m *MockExpirationsStore) GetExpirations(ctx context.Context, owner string) ([]*expiry.Expiration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExpirations", ctx, owner)
+	ret0, _ := ret[0].([]*expiry.Expiration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExpirations indicates an expected call of GetExpirations.
+func (mr *MockExpirationsStoreMockRecorder) GetExpirations(ctx, owner interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExpirations", reflect.TypeOf((*MockExpirationsStore)(nil).GetExpirations), ctx, owner)
+}
+
+// GetExpired mocks base method.
+func (m *MockExpirationsStore) GetExpired(ctx context.Context, owner string) (money.Amount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExpired", ctx, owner)
+	ret0, _ := ret[0].(money.Amount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExpired indicates an expected call of GetExpired.
+func (mr *MockExpirationsStoreMockRecorder) GetExpired(ctx, owner interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExpired", reflect.TypeOf((*MockExpirationsStore)(nil).GetExpired), ctx, owner)
+}
+
+// GetExpiringOwners mocks base method.
+func (m *MockExpirationsStore) GetExpiringOwners(ctx context.Context, processedBefore time.Time) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExpiringOwners", ctx, processedBefore)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExpiringOwners indicates an expected call of GetExpiringOwners.
+func (mr *MockExpirationsStoreMockRecorder) GetExpiringOwners(ctx, processedBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExpiringOwners", reflect.TypeOf((*MockExpirationsStore)(nil).GetExpiringOwners), ctx, processedBefore)
+}
+
+// GetLots mocks base method.
+func (m *MockExpirationsStore) GetLots(ctx context.Context, owner string) ([]expiry.Lot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLots", ctx, owner)
+	ret0, _ := ret[0].([]expiry.Lot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLots indicates an expected call of GetLots.
+func (mr *MockExpirationsStoreMockRecorder) GetLots(ctx, owner interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLots", reflect.TypeOf((*MockExpirationsStore)(nil).GetLots), ctx, owner)
+}
+
+// MockIdempotencyStore is a mock of IdempotencyStore interface.
+type MockIdempotencyStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockIdempotencyStoreMockRecorder
+}
+
+// MockIdempotencyStoreMockRecorder is the mock recorder for MockIdempotencyStore.
+type MockIdempotencyStoreMockRecorder struct {
+	mock *MockIdempotencyStore
+}
+
+// NewMockIdempotencyStore creates a new mock instance.
+func NewMockIdempotencyStore(ctrl *gomock.Controller) *MockIdempotencyStore {
+	mock := &MockIdempotencyStore{ctrl: ctrl}
+	mock.recorder = &MockIdempotencyStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIdempotencyStore) EXPECT() *MockIdempotencyStoreMockRecorder {
+	return m.recorder
+}
+
+// BeginIdempotent mocks base method.
+func (m *MockIdempotencyStore) BeginIdempotent(ctx context.Context, r *idempotency.Record, ttl time.Duration) (*idempotency.Record, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeginIdempotent", ctx, r, ttl)
+	ret0, _ := ret[0].(*idempotency.Record)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BeginIdempotent indicates an expected call of BeginIdempotent.
+func (mr *MockIdempotencyStoreMockRecorder) BeginIdempotent(ctx, r, ttl interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginIdempotent", reflect.TypeOf((*MockIdempotencyStore)(nil).BeginIdempotent), ctx, r, ttl)
+}
+
+// DeleteIdempotent mocks base method.
+func (m *MockIdempotencyStore) DeleteIdempotent(ctx context.Context, owner, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIdempotent", ctx, owner, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteIdempotent indicates an expected call of DeleteIdempotent.
+func (mr *MockIdempotencyStoreMockRecorder) DeleteIdempotent(ctx, owner, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIdempotent", reflect.TypeOf((*MockIdempotencyStore)(nil).DeleteIdempotent), ctx, owner, key)
+}
+
+// SaveIdempotent mocks base method.
+func (m *MockIdempotencyStore) SaveIdempotent(ctx context.Context, r *idempotency.Record) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveIdempotent", ctx, r)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveIdempotent indicates an expected call of SaveIdempotent.
+func (mr *MockIdempotencyStoreMockRecorder) SaveIdempotent(ctx, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveIdempotent", reflect.TypeOf((*MockIdempotencyStore)(nil).SaveIdempotent), ctx, r)
+}
+
+// MockDatabase is a mock of Database interface.
+type MockDatabase struct {
+	ctrl     *gomock.Controller
+	recorder *MockDatabaseMockRecorder
+}
+
+// MockDatabaseMockRecorder is the mock recorder for MockDatabase.
+type MockDatabaseMockRecorder struct {
+	mock *MockDatabase
+}
+
+// NewMockDatabase creates a new mock instance.
+func NewMockDatabase(ctrl *gomock.Controller) *MockDatabase {
+	mock := &MockDatabase{ctrl: ctrl}
+	mock.recorder = &MockDatabaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDatabase) EXPECT() *MockDatabaseMockRecorder {
+	return m.recorder
+}
+
+// AddAccrualRecord mocks base method.
+func (m *MockDatabase) AddAccrualRecord(ctx context.Context, r *order.AccrualRecord) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddAccrualRecord", ctx, r)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddAccrualRecord indicates an expected call of AddAccrualRecord.
+func (mr *MockDatabaseMockRecorder) AddAccrualRecord(ctx, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAccrualRecord", reflect.TypeOf((*MockDatabase)(nil).AddAccrualRecord), ctx, r)
+}
+
+// AddCampaign mocks base method.
+func (m *MockDatabase) AddCampaign(ctx context.Context, c *campaign.Campaign) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddCampaign", ctx, c)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddCampaign indicates an expected call of AddCampaign.
+func (mr *MockDatabaseMockRecorder) AddCampaign(ctx, c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddCampaign", reflect.TypeOf((*MockDatabase)(nil).AddCampaign), ctx, c)
+}
+
+// AddHold mocks base method.
+func (m *MockDatabase) AddHold(ctx context.Context, h *order.Hold, p policy.Withdraw) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddHold", ctx, h, p)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddHold indicates an expected call of AddHold.
+func (mr *MockDatabaseMockRecorder) AddHold(ctx, h, p interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddHold", reflect.TypeOf((*MockDatabase)(nil).AddHold), ctx, h, p)
+}
+
+// AddOrder mocks base method.
+func (m *MockDatabase) AddOrder(ctx context.Context, o *order.Order) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddOrder", ctx, o)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddOrder indicates an expected call of AddOrder.
+func (mr *MockDatabaseMockRecorder) AddOrder(ctx, o interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOrder", reflect.TypeOf((*MockDatabase)(nil).AddOrder), ctx, o)
+}
+
+// AddOrders mocks base method.
+func (m *MockDatabase) AddOrders(ctx context.Context, orders []*order.Order) ([]error, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddOrders", ctx, orders)
+	ret0, _ := ret[0].([]error)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddOrders indicates an expected call of AddOrders.
+func (mr *MockDatabaseMockRecorder) AddOrders(ctx, orders interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOrders", reflect.TypeOf((*MockDatabase)(nil).AddOrders), ctx, orders)
+}
+
+// AddPromoCodes mocks base method.
+func (m *MockDatabase) AddPromoCodes(ctx context.Context, codes []*promo.Code) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddPromoCodes", ctx, codes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddPromoCodes indicates an expected call of AddPromoCodes.
+func (mr *MockDatabaseMockRecorder) AddPromoCodes(ctx, codes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPromoCodes", reflect.TypeOf((*MockDatabase)(nil).AddPromoCodes), ctx, codes)
+}
+
+// AddReferral mocks base method.
+func (m *MockDatabase) AddReferral(ctx context.Context, r *referral.Referral, max int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddReferral", ctx, r, max)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddReferral indicates an expected call of AddReferral.
+func (mr *MockDatabaseMockRecorder) AddReferral(ctx, r, max interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddReferral", reflect.TypeOf((*MockDatabase)(nil).AddReferral), ctx, r, max)
+}
+
+// AddTransfer mocks base method.
+func (m *MockDatabase) AddTransfer(ctx context.Context, t *user.Transfer, p policy.Withdraw) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddTransfer", ctx, t, p)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddTransfer indicates an expected call of AddTransfer.
+func (mr *MockDatabaseMockRecorder) AddTransfer(ctx, t, p interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTransfer", reflect.TypeOf((*MockDatabase)(nil).AddTransfer), ctx, t, p)
+}
+
+// AddUser mocks base method.
+func (m *MockDatabase) AddUser(ctx context.Context, user *user.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddUser", ctx, user)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddUser indicates an expected call of AddUser.
+func (mr *MockDatabaseMockRecorder) AddUser(ctx, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockDatabase)(nil).AddUser), ctx, user)
+}
+
+// AddWithdrawal mocks base method.
+func (m *MockDatabase) AddWithdrawal(ctx context.Context, wd *order.Withdraw, p policy.Withdraw) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddWithdrawal", ctx, wd, p)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddWithdrawal indicates an expected call of AddWithdrawal.
+func (mr *MockDatabaseMockRecorder) AddWithdrawal(ctx, wd, p interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWithdrawal", reflect.TypeOf((*MockDatabase)(nil).AddWithdrawal), ctx, wd, p)
+}
+
+// BeginIdempotent mocks base method.
+func (m *MockDatabase) BeginIdempotent(ctx context.Context, r *idempotency.Record, ttl time.Duration) (*idempotency.Record, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeginIdempotent", ctx, r, ttl)
+	ret0, _ := ret[0].(*idempotency.Record)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BeginIdempotent indicates an expected call of BeginIdempotent.
+func (mr *MockDatabaseMockRecorder) BeginIdempotent(ctx, r, ttl interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginIdempotent", reflect.TypeOf((*MockDatabase)(nil).BeginIdempotent), ctx, r, ttl)
+}
+
+// CaptureHold mocks base method.
+func (m *MockDatabase) CaptureHold(ctx context.Context, owner string, number order.OrderNumber, now time.Time, p policy.Withdraw) (*order.Withdraw, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CaptureHold", ctx, owner, number, now, p)
+	ret0, _ := ret[0].(*order.Withdraw)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CaptureHold indicates an expected call of CaptureHold.
+func (mr *MockDatabaseMockRecorder) CaptureHold(ctx, owner, number, now, p interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CaptureHold", reflect.TypeOf((*MockDatabase)(nil).CaptureHold), ctx, owner, number, now, p)
+}
+
+// Close mocks base method.
+func (m *MockDatabase) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockDatabaseMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockDatabase)(nil).Close))
+}
+
+// CountProcessed mocks base method.
+func (m *MockDatabase) CountProcessed(ctx context.Context, owner string, until time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountProcessed", ctx, owner, until)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountProcessed indicates an expected call of CountProcessed.
+func (mr *MockDatabaseMockRecorder) CountProcessed(ctx, owner, until interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountProcessed", reflect.TypeOf((*MockDatabase)(nil).CountProcessed), ctx, owner, until)
+}
+
+// CountReferrals mocks base method.
+func (m *MockDatabase) CountReferrals(ctx context.Context, referrer string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountReferrals", ctx, referrer)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountReferrals indicates an expected call of CountReferrals.
+func (mr *MockDatabaseMockRecorder) CountReferrals(ctx, referrer interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountReferrals", reflect.TypeOf((*MockDatabase)(nil).CountReferrals), ctx, referrer)
+}
+
+// DeleteCampaign mocks base method.
+func (m *MockDatabase) DeleteCampaign(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteCampaign", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteCampaign indicates an expected call of DeleteCampaign.
+func (mr *MockDatabaseMockRecorder) DeleteCampaign(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCampaign", reflect.TypeOf((*MockDatabase)(nil).DeleteCampaign), ctx, id)
+}
+
+// DeleteIdempotent mocks base method.
+func (m *MockDatabase) DeleteIdempotent(ctx context.Context, owner, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIdempotent", ctx, owner, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteIdempotent indicates an expected call of DeleteIdempotent.
+func (mr *MockDatabaseMockRecorder) DeleteIdempotent(ctx, owner, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIdempotent", reflect.TypeOf((*MockDatabase)(nil).DeleteIdempotent), ctx, owner, key)
+}
+
+// ExpirePoints mocks base method.
+func (m *MockDatabase) ExpirePoints(ctx context.Context, owner string, p expiry.Policy, now time.Time) ([]*expiry.Expiration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpirePoints", ctx, owner, p, now)
+	ret0, _ := ret[0].([]*expiry.Expiration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpirePoints indicates an expected call of ExpirePoints.
+func (mr *MockDatabaseMockRecorder) ExpirePoints(ctx, owner, p, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpirePoints", reflect.TypeOf((*MockDatabase)(nil).ExpirePoints), ctx, owner, p, now)
+}
+
+// GetAccruals mocks base method.
+func (m *MockDatabase) GetAccruals(ctx context.Context, owner string) (money.Amount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccruals", ctx, owner)
+	ret0, _ := ret[0].(money.Amount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccruals indicates an expected call of GetAccruals.
+func (mr *MockDatabaseMockRecorder) GetAccruals(ctx, owner interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccruals", reflect.TypeOf((*MockDatabase)(nil).GetAccruals), ctx, owner)
+}
+
+// GetAccruedSince mocks base method.
+func (m *MockDatabase) GetAccruedSince(ctx context.Context, owner string, since time.Time) (money.Amount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccruedSince", ctx, owner, since)
+	ret0, _ := ret[0].(money.Amount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccruedSince indicates an expected call of GetAccruedSince.
+func (mr *MockDatabaseMockRecorder) GetAccruedSince(ctx, owner, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccruedSince", reflect.TypeOf((*MockDatabase)(nil).GetAccruedSince), ctx, owner, since)
+}
+
+// GetBonuses mocks base method.
+func (m *MockDatabase) GetBonuses(ctx context.Context, owner string) (money.Amount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBonuses", ctx, owner)
+	ret0, _ := ret[0].(money.Amount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBonuses indicates an expected call of GetBonuses.
+func (mr *MockDatabaseMockRecorder) GetBonuses(ctx, owner interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBonuses", reflect.TypeOf((*MockDatabase)(nil).GetBonuses), ctx, owner)
+}
+
+// GetCampaigns mocks base method.
+func (m *MockDatabase) GetCampaigns(ctx context.Context) ([]*campaign.Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCampaigns", ctx)
+	ret0, _ := ret[0].([]*campaign.Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCampaigns indicates an expected call of GetCampaigns.
+func (mr *MockDatabaseMockRecorder) GetCampaigns(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCampaigns", reflect.TypeOf((*MockDatabase)(nil).GetCampaigns), ctx)
+}
+
+// GetDebits mocks base method.
+func (m *MockDatabase) GetDebits(ctx context.Context, owner string, now time.Time) ([]expiry.Debit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDebits", ctx, owner, now)
+	ret0, _ := ret[0].([]expiry.Debit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDebits indicates an expected call of GetDebits.
+func (mr *MockDatabaseMockRecorder) GetDebits(ctx, owner, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDebits", reflect.TypeOf((*MockDatabase)(nil).GetDebits), ctx, owner, now)
+}
+
+// GetDueOrders mocks base method.
+func (m *MockDatabase) GetDueOrders(ctx context.Context, limit int, revision time.Duration) ([]*order.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDueOrders", ctx, limit, revision)
+	ret0, _ := ret[0].([]*order.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDueOrders indicates an expected call of GetDueOrders.
+func (mr *MockDatabaseMockRecorder) GetDueOrders(ctx, limit, revision interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDueOrders", reflect.TypeOf((*MockDatabase)(nil).GetDueOrders), ctx, limit, revision)
+}
+
+// GetExpirations mocks base method.
+func (m *MockDatabase) GetExpirations(ctx context.Context, owner string) ([]*expiry.Expiration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExpirations", ctx, owner)
+	ret0, _ := ret[0].([]*expiry.Expiration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExpirations indicates an expected call of GetExpirations.
+func (mr *MockDatabaseMockRecorder) GetExpirations(ctx, owner interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExpirations", reflect.TypeOf((*MockDatabase)(nil).GetExpirations), ctx, owner)
+}
+
+// GetExpired mocks base method.
+func (m *MockDatabase) GetExpired(ctx context.Context, owner string) (money.Amount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExpired", ctx, owner)
+	ret0, _ := ret[0].(money.Amount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExpired indicates an expected call of GetExpired.
+func (mr *MockDatabaseMockRecorder) GetExpired(ctx, owner interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExpired", reflect.TypeOf((*MockDatabase)(nil).GetExpired), ctx, owner)
+}
+
+// GetExpiringOwners mocks base method.
+func (m *MockDatabase) GetExpiringOwners(ctx context.Context, processedBefore time.Time) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExpiringOwners", ctx, processedBefore)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExpiringOwners indicates an expected call of GetExpiringOwners.
+func (mr *MockDatabaseMockRecorder) GetExpiringOwners(ctx, processedBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExpiringOwners", reflect.TypeOf((*MockDatabase)(nil).GetExpiringOwners), ctx, processedBefore)
+}
+
+// GetHeld mocks base method.
+func (m *MockDatabase) GetHeld(ctx context.Context, owner string, now time.Time) (money.Amount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHeld", ctx, owner, now)
+	ret0, _ := ret[0].(money.Amount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetHeld indicates an expected call of GetHeld.
+func (mr *MockDatabaseMockRecorder) GetHeld(ctx, owner, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHeld", reflect.TypeOf((*MockDatabase)(nil).GetHeld), ctx, owner, now)
+}
+
+// GetLogins mocks base method.
+func (m *MockDatabase) GetLogins(ctx context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLogins", ctx)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLogins indicates an expected call of GetLogins.
+func (mr *MockDatabaseMockRecorder) GetLogins(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLogins", reflect.TypeOf((*MockDatabase)(nil).GetLogins), ctx)
+}
+
+// GetLots mocks base method.
+func (m *MockDatabase) GetLots(ctx context.Context, owner string) ([]expiry.Lot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLots", ctx, owner)
+	ret0, _ := ret[0].([]expiry.Lot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLots indicates an expected call of GetLots.
+func (mr *MockDatabaseMockRecorder) GetLots(ctx, owner interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLots", reflect.TypeOf((*MockDatabase)(nil).GetLots), ctx, owner)
+}
+
+// GetOrder mocks base method.
+func (m *MockDatabase) GetOrder(ctx context.Context, number order.OrderNumber) (*order.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrder", ctx, number)
+	ret0, _ := ret[0].(*order.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrder indicates an expected call of GetOrder.
+func (mr *MockDatabaseMockRecorder) GetOrder(ctx, number interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrder", reflect.TypeOf((*MockDatabase)(nil).GetOrder), ctx, number)
+}
+
+// GetOrders mocks base method.
+func (m *MockDatabase) GetOrders(ctx context.Context, owner string) ([]*order.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrders", ctx, owner)
+	ret0, _ := ret[0].([]*order.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrders indicates an expected call of GetOrders.
+func (mr *MockDatabaseMockRecorder) GetOrders(ctx, owner interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrders", reflect.TypeOf((*MockDatabase)(nil).GetOrders), ctx, owner)
+}
+
+// GetRedeemed mocks base method.
+func (m *MockDatabase) GetRedeemed(ctx context.Context, owner string) (money.Amount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRedeemed", ctx, owner)
+	ret0, _ := ret[0].(money.Amount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRedeemed indicates an expected call of GetRedeemed.
+func (mr *MockDatabaseMockRecorder) GetRedeemed(ctx, owner interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRedeemed", reflect.TypeOf((*MockDatabase)(nil).GetRedeemed), ctx, owner)
+}
+
+// GetReferral mocks base method.
+func (m *MockDatabase) GetReferral(ctx context.Context, referee string) (*referral.Referral, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReferral", ctx, referee)
+	ret0, _ := ret[0].(*referral.Referral)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReferral indicates an expected call of GetReferral.
+func (mr *MockDatabaseMockRecorder) GetReferral(ctx, referee interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReferral", reflect.TypeOf((*MockDatabase)(nil).GetReferral), ctx, referee)
+}
+
+// GetReferrals mocks base method.
+func (m *MockDatabase) GetReferrals(ctx context.Context, referrer string) ([]*referral.Referral, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReferrals", ctx, referrer)
+	ret0, _ := ret[0].([]*referral.Referral)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReferrals indicates an expected call of GetReferrals.
+func (mr *MockDatabaseMockRecorder) GetReferrals(ctx, referrer interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReferrals", reflect.TypeOf((*MockDatabase)(nil).GetReferrals), ctx, referrer)
+}
+
+// GetReferrer mocks base method.
+func (m *MockDatabase) GetReferrer(ctx context.Context, code string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReferrer", ctx, code)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReferrer indicates an expected call of GetReferrer.
+func (mr *MockDatabaseMockRecorder) GetReferrer(ctx, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReferrer", reflect.TypeOf((*MockDatabase)(nil).GetReferrer), ctx, code)
+}
+
+// GetReward mocks base method.
+func (m *MockDatabase) GetReward(ctx context.Context, number order.OrderNumber) (*campaign.Reward, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReward", ctx, number)
+	ret0, _ := ret[0].(*campaign.Reward)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReward indicates an expected call of GetReward.
+func (mr *MockDatabaseMockRecorder) GetReward(ctx, number interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReward", reflect.TypeOf((*MockDatabase)(nil).GetReward), ctx, number)
+}
+
+// GetRewardPending mocks base method.
+func (m *MockDatabase) GetRewardPending(ctx context.Context, limit int) ([]*order.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRewardPending", ctx, limit)
+	ret0, _ := ret[0].([]*order.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRewardPending indicates an expected call of GetRewardPending.
+func (mr *MockDatabaseMockRecorder) GetRewardPending(ctx, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRewardPending", reflect.TypeOf((*MockDatabase)(nil).GetRewardPending), ctx, limit)
+}
+
+// GetStatusHistory mocks base method.
+func (m *MockDatabase) GetStatusHistory(ctx context.Context, number order.OrderNumber) ([]*order.StatusChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStatusHistory", ctx, number)
+	ret0, _ := ret[0].([]*order.StatusChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStatusHistory indicates an expected call of GetStatusHistory.
+func (mr *MockDatabaseMockRecorder) GetStatusHistory(ctx, number interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatusHistory", reflect.TypeOf((*MockDatabase)(nil).GetStatusHistory), ctx, number)
+}
+
+// GetTier mocks base method.
+func (m *MockDatabase) GetTier(ctx context.Context, login string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTier", ctx, login)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTier indicates an expected call of GetTier.
+func (mr *MockDatabaseMockRecorder) GetTier(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTier", reflect.TypeOf((*MockDatabase)(nil).GetTier), ctx, login)
+}
+
+// GetTransferred mocks base method.
+func (m *MockDatabase) GetTransferred(ctx context.Context, login string) (money.Amount, money.Amount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferred", ctx, login)
+	ret0, _ := ret[0].(money.Amount)
+	ret1, _ := ret[1].(money.Amount)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetTransferred indicates an expected call of GetTransferred.
+func (mr *MockDatabaseMockRecorder) GetTransferred(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferred", reflect.TypeOf((*MockDatabase)(nil).GetTransferred), ctx, login)
+}
+
+// GetTransferredSince mocks base method.
+func (m *MockDatabase) GetTransferredSince(ctx context.Context, login string, since time.Time) (money.Amount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferredSince", ctx, login, since)
+	ret0, _ := ret[0].(money.Amount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransferredSince indicates an expected call of GetTransferredSince.
+func (mr *MockDatabaseMockRecorder) GetTransferredSince(ctx, login, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferredSince", reflect.TypeOf((*MockDatabase)(nil).GetTransferredSince), ctx, login, since)
+}
+
+// GetTransfers mocks base method.
+func (m *MockDatabase) GetTransfers(ctx context.Context, login string) ([]*user.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransfers", ctx, login)
+	ret0, _ := ret[0].([]*user.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransfers indicates an expected call of GetTransfers.
+func (mr *MockDatabaseMockRecorder) GetTransfers(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransfers", reflect.TypeOf((*MockDatabase)(nil).GetTransfers), ctx, login)
+}
+
+// GetUser mocks base method.
+func (m *MockDatabase) GetUser(ctx context.Context, login string) (*user.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", ctx, login)
+	ret0, _ := ret[0].(*user.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockDatabaseMockRecorder) GetUser(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockDatabase)(nil).GetUser), ctx, login)
+}
+
+// GetWithStatus mocks base method.
+func (m *MockDatabase) GetWithStatus(ctx context.Context, s order.Status) ([]order.OrderNumber, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWithStatus", ctx, s)
+	ret0, _ := ret[0].([]order.OrderNumber)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWithStatus indicates an expected call of GetWithStatus.
+func (mr *MockDatabaseMockRecorder) GetWithStatus(ctx, s interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithStatus", reflect.TypeOf((*MockDatabase)(nil).GetWithStatus), ctx, s)
+}
+
+// GetWithdrawals mocks base method.
+func (m *MockDatabase) GetWithdrawals(ctx context.Context, owner string) ([]*order.Withdraw, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWithdrawals", ctx, owner)
+	ret0, _ := ret[0].([]*order.Withdraw)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWithdrawals indicates an expected call of GetWithdrawals.
+func (mr *MockDatabaseMockRecorder) GetWithdrawals(ctx, owner interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawals", reflect.TypeOf((*MockDatabase)(nil).GetWithdrawals), ctx, owner)
+}
+
+// GetWithdrawn mocks base method.
+func (m *MockDatabase) GetWithdrawn(ctx context.Context, owner string) (money.Amount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWithdrawn", ctx, owner)
+	ret0, _ := ret[0].(money.Amount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWithdrawn indicates an expected call of GetWithdrawn.
 func (mr *MockDatabaseMockRecorder) GetWithdrawn(ctx, owner interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawn", reflect.TypeOf((*MockDatabase)(nil).GetWithdrawn), ctx, owner)
 }
 
+// GetWithdrawnSince mocks base method.
+func (m *MockDatabase) GetWithdrawnSince(ctx context.Context, owner string, since time.Time) (money.Amount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWithdrawnSince", ctx, owner, since)
+	ret0, _ := ret[0].(money.Amount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWithdrawnSince indicates an expected call of GetWithdrawnSince.
+func (mr *MockDatabaseMockRecorder) GetWithdrawnSince(ctx, owner, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawnSince", reflect.TypeOf((*MockDatabase)(nil).GetWithdrawnSince), ctx, owner, since)
+}
+
+// ListenOrders mocks base method.
+func (m *MockDatabase) ListenOrders(ctx context.Context, handle func(order.OrderNumber)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListenOrders", ctx, handle)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ListenOrders indicates an expected call of ListenOrders.
+func (mr *MockDatabaseMockRecorder) ListenOrders(ctx, handle interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListenOrders", reflect.TypeOf((*MockDatabase)(nil).ListenOrders), ctx, handle)
+}
+
 // Open mocks base method.
 func (m *MockDatabase) Open(Addr string) error {
 	m.ctrl.T.Helper()
@@ -441,6 +1953,164 @@ func (mr *MockDatabaseMockRecorder) Open(Addr interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Open", reflect.TypeOf((*MockDatabase)(nil).Open), Addr)
 }
 
+// RedeemPromo mocks base method.
+func (m *MockDatabase) RedeemPromo(ctx context.Context, code, owner string, now time.Time) (*promo.Redemption, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RedeemPromo", ctx, code, owner, now)
+	ret0, _ := ret[0].(*promo.Redemption)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RedeemPromo indicates an expected call of RedeemPromo.
+func (mr *MockDatabaseMockRecorder) RedeemPromo(ctx, code, owner, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RedeemPromo", reflect.TypeOf((*MockDatabase)(nil).RedeemPromo), ctx, code, owner, now)
+}
+
+// RefundWithdrawal mocks base method.
+func (m *MockDatabase) RefundWithdrawal(ctx context.Context, number order.OrderNumber, sum *money.Amount, cancel bool) (*order.Withdraw, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefundWithdrawal", ctx, number, sum, cancel)
+	ret0, _ := ret[0].(*order.Withdraw)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefundWithdrawal indicates an expected call of RefundWithdrawal.
+func (mr *MockDatabaseMockRecorder) RefundWithdrawal(ctx, number, sum, cancel interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefundWithdrawal", reflect.TypeOf((*MockDatabase)(nil).RefundWithdrawal), ctx, number, sum, cancel)
+}
+
+// RequeueOrder mocks base method.
+func (m *MockDatabase) RequeueOrder(ctx context.Context, number order.OrderNumber) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequeueOrder", ctx, number)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RequeueOrder indicates an expected call of RequeueOrder.
+func (mr *MockDatabaseMockRecorder) RequeueOrder(ctx, number interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequeueOrder", reflect.TypeOf((*MockDatabase)(nil).RequeueOrder), ctx, number)
+}
+
+// ReviseAccrual mocks base method.
+func (m *MockDatabase) ReviseAccrual(ctx context.Context, number order.OrderNumber, accrual *money.Amount, asOf, next time.Time) (*order.Adjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReviseAccrual", ctx, number, accrual, asOf, next)
+	ret0, _ := ret[0].(*order.Adjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReviseAccrual indicates an expected call of ReviseAccrual.
+func (mr *MockDatabaseMockRecorder) ReviseAccrual(ctx, number, accrual, asOf, next interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReviseAccrual", reflect.TypeOf((*MockDatabase)(nil).ReviseAccrual), ctx, number, accrual, asOf, next)
+}
+
+// RewardReferral mocks base method.
+func (m *MockDatabase) RewardReferral(ctx context.Context, r *referral.Referral, bonuses []*order.Bonus) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RewardReferral", ctx, r, bonuses)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RewardReferral indicates an expected call of RewardReferral.
+func (mr *MockDatabaseMockRecorder) RewardReferral(ctx, r, bonuses interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RewardReferral", reflect.TypeOf((*MockDatabase)(nil).RewardReferral), ctx, r, bonuses)
+}
+
+// SaveIdempotent mocks base method.
+func (m *MockDatabase) SaveIdempotent(ctx context.Context, r *idempotency.Record) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveIdempotent", ctx, r)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveIdempotent indicates an expected call of SaveIdempotent.
+func (mr *MockDatabaseMockRecorder) SaveIdempotent(ctx, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveIdempotent", reflect.TypeOf((*MockDatabase)(nil).SaveIdempotent), ctx, r)
+}
+
+// ScheduleOrder mocks base method.
+func (m *MockDatabase) ScheduleOrder(ctx context.Context, number order.OrderNumber, attempts int, next time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScheduleOrder", ctx, number, attempts, next)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ScheduleOrder indicates an expected call of ScheduleOrder.
+func (mr *MockDatabaseMockRecorder) ScheduleOrder(ctx, number, attempts, next interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScheduleOrder", reflect.TypeOf((*MockDatabase)(nil).ScheduleOrder), ctx, number, attempts, next)
+}
+
+// SetBonuses mocks base method.
+func (m *MockDatabase) SetBonuses(ctx context.Context, r *campaign.Reward, bonuses []*order.Bonus) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetBonuses", ctx, r, bonuses)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetBonuses indicates an expected call of SetBonuses.
+func (mr *MockDatabaseMockRecorder) SetBonuses(ctx, r, bonuses interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBonuses", reflect.TypeOf((*MockDatabase)(nil).SetBonuses), ctx, r, bonuses)
+}
+
+// SetReferralCode mocks base method.
+func (m *MockDatabase) SetReferralCode(ctx context.Context, login, code string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetReferralCode", ctx, login, code)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetReferralCode indicates an expected call of SetReferralCode.
+func (mr *MockDatabaseMockRecorder) SetReferralCode(ctx, login, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetReferralCode", reflect.TypeOf((*MockDatabase)(nil).SetReferralCode), ctx, login, code)
+}
+
+// SetRewarded mocks base method.
+func (m *MockDatabase) SetRewarded(ctx context.Context, number order.OrderNumber, accrual *money.Amount) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRewarded", ctx, number, accrual)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetRewarded indicates an expected call of SetRewarded.
+func (mr *MockDatabaseMockRecorder) SetRewarded(ctx, number, accrual interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRewarded", reflect.TypeOf((*MockDatabase)(nil).SetRewarded), ctx, number, accrual)
+}
+
+// SetTier mocks base method.
+func (m *MockDatabase) SetTier(ctx context.Context, login, tier string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTier", ctx, login, tier)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTier indicates an expected call of SetTier.
+func (mr *MockDatabaseMockRecorder) SetTier(ctx, login, tier interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTier", reflect.TypeOf((*MockDatabase)(nil).SetTier), ctx, login, tier)
+}
+
 // UpdateOrder mocks base method.
 func (m *MockDatabase) UpdateOrder(ctx context.Context, o *order.Order) error {
 	m.ctrl.T.Helper()
@@ -454,3 +2124,18 @@ func (mr *MockDatabaseMockRecorder) UpdateOrder(ctx, o interface{}) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrder", reflect.TypeOf((*MockDatabase)(nil).UpdateOrder), ctx, o)
 }
+
+// VoidHold mocks base method.
+func (m *MockDatabase) VoidHold(ctx context.Context, owner string, number order.OrderNumber, now time.Time) (*order.Hold, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VoidHold", ctx, owner, number, now)
+	ret0, _ := ret[0].(*order.Hold)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VoidHold indicates an expected call of VoidHold.
+func (mr *MockDatabaseMockRecorder) VoidHold(ctx, owner, number, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VoidHold", reflect.TypeOf((*MockDatabase)(nil).VoidHold), ctx, owner, number, now)
+}